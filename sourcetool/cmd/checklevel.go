@@ -10,8 +10,8 @@ import (
 	"os"
 
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/policy"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 
 	"github.com/spf13/cobra"
 )
@@ -41,22 +41,22 @@ func doCheckLevel(commit, owner, repo, branch, outputVsa, outputUnsignedVsa stri
 		log.Fatal("Must set commit, owner, repo, and branch flags.")
 	}
 
-	gh_connection := gh_control.NewGhConnection(owner, repo, gh_control.BranchToFullRef(branch)).WithAuthToken(githubToken)
+	platform := getPlatform(owner, repo, source_control.BranchToFullRef(branch))
 	ctx := context.Background()
 
-	controlStatus, err := gh_connection.GetBranchControls(ctx, commit, gh_connection.GetFullRef())
+	controlStatus, err := platform.GetBranchControls(ctx, commit, platform.GetFullRef())
 	if err != nil {
 		log.Fatal(err)
 	}
 	pe := policy.NewPolicyEvaluator()
 	pe.UseLocalPolicy = checkLevelProvArgs.useLocalPolicy
-	verifiedLevels, policyPath, err := pe.EvaluateControl(ctx, gh_connection, controlStatus)
+	verifiedLevels, policyPath, err := pe.EvaluateControl(ctx, platform, controlStatus)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Print(verifiedLevels)
 
-	unsignedVsa, err := attest.CreateUnsignedSourceVsa(gh_connection.GetRepoUri(), gh_connection.GetFullRef(), commit, verifiedLevels, policyPath)
+	unsignedVsa, err := attest.CreateUnsignedSourceVsa(platform.GetRepoUri(), platform.GetFullRef(), commit, verifiedLevels, policyPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -5,13 +5,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/policy"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/spf13/cobra"
@@ -21,6 +23,7 @@ type CheckLevelProvArgs struct {
 	prevBundlePath       string
 	commit               string
 	prevCommit           string
+	before               string
 	owner                string
 	repo                 string
 	branch               string
@@ -45,81 +48,102 @@ var (
 )
 
 func doCheckLevelProv(checkLevelProvArgs CheckLevelProvArgs) {
-	gh_connection :=
-		gh_control.NewGhConnection(checkLevelProvArgs.owner, checkLevelProvArgs.repo, gh_control.BranchToFullRef(checkLevelProvArgs.branch)).WithAuthToken(githubToken)
+	platform := getPlatform(checkLevelProvArgs.owner, checkLevelProvArgs.repo, source_control.BranchToFullRef(checkLevelProvArgs.branch))
 	ctx := context.Background()
 
+	commits := []string{checkLevelProvArgs.commit}
 	prevCommit := checkLevelProvArgs.prevCommit
 	var err error
+	if before := checkLevelProvArgs.before; before != "" && !source_control.IsZeroCommit(before) {
+		if prevCommit != "" {
+			log.Fatal("only one of --prev_commit and --before may be set")
+		}
+		// Cover every commit the push added, not just the new tip.
+		pushed, err := source_control.PushedCommits(ctx, platform, before, checkLevelProvArgs.commit)
+		if errors.Is(err, source_control.ErrBeforeNotFound) {
+			// e.g. a force push. The new tip's provenance records the break in the history.
+			log.Printf("%v, only creating provenance for %s", err, checkLevelProvArgs.commit)
+		} else if err != nil {
+			log.Fatal(err)
+		} else {
+			commits, prevCommit = pushed, before
+		}
+	}
 	if prevCommit == "" {
-		prevCommit, err = gh_connection.GetPriorCommit(ctx, checkLevelProvArgs.commit)
+		prevCommit, err = source_control.PriorCommit(ctx, platform, checkLevelProvArgs.commit)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	pa := attest.NewProvenanceAttestor(gh_connection, getVerifier())
-	prov, err := pa.CreateSourceProvenance(ctx, checkLevelProvArgs.prevBundlePath, checkLevelProvArgs.commit, prevCommit, gh_connection.GetFullRef())
+	pa := attest.NewProvenanceAttestor(platform, getVerifier())
+	provs, err := pa.CreatePushProvenance(ctx, checkLevelProvArgs.prevBundlePath, commits, prevCommit, checkLevelProvArgs.before, platform.GetFullRef())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// check p against policy
-	pe := policy.NewPolicyEvaluator()
-	pe.UseLocalPolicy = checkLevelProvArgs.useLocalPolicy
-	verifiedLevels, policyPath, err := pe.EvaluateSourceProv(ctx, gh_connection, prov)
-	if err != nil {
-		log.Fatal(err)
+	var out *os.File
+	if checkLevelProvArgs.outputUnsignedBundle != "" {
+		out, err = os.OpenFile(checkLevelProvArgs.outputUnsignedBundle, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	} else if checkLevelProvArgs.outputSignedBundle != "" {
+		out, err = os.OpenFile(checkLevelProvArgs.outputSignedBundle, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	}
-
-	// create vsa
-	unsignedVsa, err := attest.CreateUnsignedSourceVsa(gh_connection.GetRepoUri(), gh_connection.GetFullRef(), checkLevelProvArgs.commit, verifiedLevels, policyPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	unsignedProv, err := protojson.Marshal(prov)
-	if err != nil {
-		log.Fatal(err)
+	if out != nil {
+		defer out.Close()
 	}
 
-	// Store both the unsigned provenance and vsa
-	if checkLevelProvArgs.outputUnsignedBundle != "" {
-		f, err := os.OpenFile(checkLevelProvArgs.outputUnsignedBundle, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	pe := policy.NewPolicyEvaluator()
+	pe.UseLocalPolicy = checkLevelProvArgs.useLocalPolicy
+	var verifiedLevels slsa_types.SourceVerifiedLevels
+	for i, prov := range provs {
+		// check p against policy
+		var policyPath string
+		verifiedLevels, policyPath, err = pe.EvaluateSourceProv(ctx, platform, prov)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer f.Close()
 
-		f.WriteString(string(unsignedProv))
-		f.WriteString("\n")
-		f.WriteString(unsignedVsa)
-		f.WriteString("\n")
-	} else if checkLevelProvArgs.outputSignedBundle != "" {
-		f, err := os.OpenFile(checkLevelProvArgs.outputSignedBundle, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+		// create vsa
+		unsignedVsa, err := attest.CreateUnsignedSourceVsa(platform.GetRepoUri(), platform.GetFullRef(), commits[i], verifiedLevels, policyPath)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer f.Close()
 
-		signedProv, err := attest.Sign(string(unsignedProv))
+		unsignedProv, err := protojson.Marshal(prov)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		signedVsa, err := attest.Sign(unsignedVsa)
-		if err != nil {
-			log.Fatal(err)
+		// Store both the unsigned provenance and vsa
+		if checkLevelProvArgs.outputUnsignedBundle != "" {
+			out.WriteString(string(unsignedProv))
+			out.WriteString("\n")
+			out.WriteString(unsignedVsa)
+			out.WriteString("\n")
+		} else if checkLevelProvArgs.outputSignedBundle != "" {
+			signedProv, err := attest.Sign(string(unsignedProv))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			signedVsa, err := attest.Sign(unsignedVsa)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			out.WriteString(signedProv)
+			out.WriteString("\n")
+			out.WriteString(signedVsa)
+			out.WriteString("\n")
+		} else {
+			log.Printf("unsigned prov: %s\n", unsignedProv)
+			log.Printf("unsigned vsa: %s\n", unsignedVsa)
 		}
-
-		f.WriteString(signedProv)
-		f.WriteString("\n")
-		f.WriteString(signedVsa)
-		f.WriteString("\n")
-	} else {
-		log.Printf("unsigned prov: %s\n", unsignedProv)
-		log.Printf("unsigned vsa: %s\n", unsignedVsa)
 	}
+	// The levels of the new tip.
 	fmt.Print(verifiedLevels)
 }
 
@@ -129,6 +153,7 @@ func init() {
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.prevBundlePath, "prev_bundle_path", "", "Path to the file with the attestations for the previous commit (as an in-toto bundle).")
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.commit, "commit", "", "The commit to check.")
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.prevCommit, "prev_commit", "", "The commit to check.")
+	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.before, "before", "", "The commit the branch was at before the push, to create provenance for every commit the push added and not just --commit.")
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.owner, "owner", "", "The GitHub repository owner - required.")
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.repo, "repo", "", "The GitHub repository name - required.")
 	checklevelprovCmd.Flags().StringVar(&checkLevelProvArgs.branch, "branch", "", "The branch within the repository - required.")
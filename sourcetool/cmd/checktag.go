@@ -9,8 +9,8 @@ import (
 	"os"
 
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/policy"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"github.com/spf13/cobra"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -38,14 +38,13 @@ var (
 )
 
 func doCheckTag(args CheckTagArgs) {
-	gh_connection :=
-		gh_control.NewGhConnection(args.owner, args.repo, gh_control.TagToFullRef(args.tagName)).WithAuthToken(githubToken)
+	platform := getPlatform(args.owner, args.repo, source_control.TagToFullRef(args.tagName))
 	ctx := context.Background()
 	verifier := getVerifier()
 
 	// Create tag provenance.
-	pa := attest.NewProvenanceAttestor(gh_connection, verifier)
-	prov, err := pa.CreateTagProvenance(ctx, args.commit, gh_control.TagToFullRef(args.tagName), args.actor)
+	pa := attest.NewProvenanceAttestor(platform, verifier)
+	prov, err := pa.CreateTagProvenance(ctx, args.commit, source_control.TagToFullRef(args.tagName), args.actor)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -53,13 +52,13 @@ func doCheckTag(args CheckTagArgs) {
 	// check p against policy
 	pe := policy.NewPolicyEvaluator()
 	pe.UseLocalPolicy = args.useLocalPolicy
-	verifiedLevels, policyPath, err := pe.EvaluateTagProv(ctx, gh_connection, prov)
+	verifiedLevels, policyPath, err := pe.EvaluateTagProv(ctx, platform, prov)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// create vsa
-	unsignedVsa, err := attest.CreateUnsignedSourceVsa(gh_connection.GetRepoUri(), gh_connection.GetFullRef(), args.commit, verifiedLevels, policyPath)
+	unsignedVsa, err := attest.CreateUnsignedSourceVsa(platform.GetRepoUri(), platform.GetFullRef(), args.commit, verifiedLevels, policyPath)
 	if err != nil {
 		log.Fatal(err)
 	}
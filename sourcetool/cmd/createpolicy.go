@@ -8,8 +8,8 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/policy"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 
 	"github.com/spf13/cobra"
 )
@@ -35,9 +35,9 @@ var (
 )
 
 func doCreatePolicy(policyRepoPath, owner, repo, branch string) {
-	gh_connection := gh_control.NewGhConnection(owner, repo, gh_control.BranchToFullRef(branch)).WithAuthToken(githubToken)
+	platform := getPlatform(owner, repo, source_control.BranchToFullRef(branch))
 	ctx := context.Background()
-	outpath, err := policy.CreateLocalPolicy(ctx, gh_connection, policyRepoPath)
+	outpath, err := policy.CreateLocalPolicy(ctx, platform, policyRepoPath)
 	if err != nil {
 		log.Fatal(err)
 	}
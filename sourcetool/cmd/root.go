@@ -4,16 +4,46 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/google/go-github/v69/github"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/bitbucket_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gerrit_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gitea_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gitlab_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/local_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"github.com/spf13/cobra"
 )
 
 var (
-	githubToken    string
-	expectedIssuer string
-	expectedSan    string
+	githubToken         string
+	githubAppId         string
+	githubAppPrivateKey string
+	githubUrl           string
+	githubUploadUrl     string
+	caBundle            string
+	cacheDir            string
+	policyRepo          string
+	activityLookback    time.Duration
+	gitlabToken         string
+	giteaToken          string
+	gerritUser          string
+	gerritPassword      string
+	bitbucketToken      string
+	localPath           string
+	platformName        string
+	platformUrl         string
+	expectedIssuer      string
+	expectedSan         string
 
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
@@ -42,6 +72,94 @@ func getVerifier() attest.Verifier {
 	return attest.NewBndVerifier(options)
 }
 
+// Gets a connection to owner/repo on the platform selected with --platform.
+func getPlatform(owner, repo, ref string) source_control.SourceControlPlatform {
+	httpClient, err := source_control.NewHttpClient(caBundle)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Policies are always hosted on GitHub (or GitHub Enterprise Server).
+	// Its API is rate limited so we cache what we can and wait out the limits.
+	ghHttpClient := &http.Client{
+		Transport: gh_control.NewCachingTransport(httpClient.Transport, cacheDir),
+		Timeout:   httpClient.Timeout}
+	var gh_connection *gh_control.GitHubConnection
+	if githubUrl != "" {
+		gh_connection, err = gh_control.NewGhEnterpriseConnection(githubUrl, githubUploadUrl, owner, repo, ref, ghHttpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		gh_connection = gh_control.NewGhConnectionWithClient(owner, repo, ref, github.NewClient(ghHttpClient))
+	}
+	gh_connection = gh_connection.WithActivityLookback(activityLookback)
+	if githubAppId != "" {
+		key, err := gh_control.LoadAppPrivateKey(githubAppPrivateKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gh_connection = gh_connection.WithAppAuth(githubAppId, key)
+	} else {
+		gh_connection = gh_connection.WithAuthToken(githubToken)
+	}
+	if policyRepo != "" {
+		policyOwner, policyName, found := strings.Cut(policyRepo, "/")
+		if !found {
+			log.Fatalf("--policy_repo must be owner/repo, got %s", policyRepo)
+		}
+		gh_connection = gh_connection.WithPolicyRepo(policyOwner, policyName)
+	}
+
+	switch platformName {
+	case "github":
+		return gh_connection
+	case "gitlab":
+		glc, err := gitlab_control.NewGitLabConnectionWithClient(platformUrl, fmt.Sprintf("%s/%s", owner, repo), ref, gh_connection, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return glc.WithAuthToken(gitlabToken).WithActivityLookback(activityLookback)
+	case "gitea", "forgejo":
+		gc, err := gitea_control.NewGiteaConnectionWithClient(platformUrl, owner, repo, ref, gh_connection, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return gc.WithAuthToken(giteaToken)
+	case "gerrit":
+		gc, err := gerrit_control.NewGerritConnectionWithClient(platformUrl, fmt.Sprintf("%s/%s", owner, repo), ref, gh_connection, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return gc.WithAuth(gerritUser, gerritPassword)
+	case "bitbucket", "bitbucket-dc":
+		flavor := bitbucket_control.Cloud
+		if platformName == "bitbucket-dc" {
+			flavor = bitbucket_control.DataCenter
+		}
+		bc, err := bitbucket_control.NewBitbucketConnectionWithClient(flavor, platformUrl, owner, repo, ref, gh_connection, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return bc.WithAuthToken(bitbucketToken)
+	case "local":
+		// Everything comes from the clone, which is identified by its origin remote rather than owner/repo.
+		// It can't tell which controls the forge enforces, so only verifying commits works.
+		lc, err := local_control.NewLocalConnection(localPath, "", ref, gh_connection)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return lc
+	}
+	log.Fatalf("unsupported platform %s", platformName)
+	return nil
+}
+
+// Reports if --owner and --repo identify the repo, a local clone is identified by its origin remote.
+func needsOwnerAndRepo() bool {
+	return platformName != "local"
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -57,7 +175,32 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&githubToken, "github_token", "", "the github token to use for auth")
+	rootCmd.PersistentFlags().StringVar(&githubAppId, "github_app_id", "", "Authenticate as this GitHub App (app or client ID) instead of with --github_token.")
+	rootCmd.PersistentFlags().StringVar(&githubAppPrivateKey, "github_app_private_key", "", "Path to the private key (PEM) of the app set with --github_app_id.")
+	rootCmd.PersistentFlags().StringVar(&githubUrl, "github_url", "", "The URL of the GitHub Enterprise Server instance (or its API), if not github.com.")
+	rootCmd.PersistentFlags().StringVar(&githubUploadUrl, "github_upload_url", "", "The upload URL of the GitHub Enterprise Server instance, if not the same as --github_url.")
+	rootCmd.PersistentFlags().StringVar(&caBundle, "ca_bundle", "", "Path to a PEM file of additional CA certificates to trust, for self-hosted instances.")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache_dir", defaultCacheDir(), "Where to cache GitHub API responses, empty to disable caching.")
+	rootCmd.PersistentFlags().DurationVar(&activityLookback, "activity_lookback", gh_control.DefaultActivityLookback, "How far back to look for the GitHub activity (or GitLab push event) that pushed a commit.")
+	rootCmd.PersistentFlags().StringVar(&policyRepo, "policy_repo", "", "The GitHub repo (owner/repo) to fetch policies from, if not the default.")
+	rootCmd.PersistentFlags().StringVar(&gitlabToken, "gitlab_token", "", "the gitlab token to use for auth")
+	rootCmd.PersistentFlags().StringVar(&giteaToken, "gitea_token", "", "the gitea/forgejo token to use for auth")
+	rootCmd.PersistentFlags().StringVar(&gerritUser, "gerrit_user", "", "the gerrit username to use for auth")
+	rootCmd.PersistentFlags().StringVar(&gerritPassword, "gerrit_password", "", "the gerrit http password to use for auth")
+	rootCmd.PersistentFlags().StringVar(&bitbucketToken, "bitbucket_token", "", "the bitbucket access token to use for auth")
+	rootCmd.PersistentFlags().StringVar(&localPath, "local_path", ".", "The path to the clone to use with --platform local.")
+	rootCmd.PersistentFlags().StringVar(&platformName, "platform", "github", "The platform hosting the repository: github, gitlab, gitea (also forgejo), gerrit, bitbucket (Cloud), bitbucket-dc (Data Center), or local (a local clone, only supports verifycommit).")
+	rootCmd.PersistentFlags().StringVar(&platformUrl, "platform_url", "", "The base URL of the platform, if not the public instance.")
 	rootCmd.PersistentFlags().StringVar(&expectedIssuer, "expected_issuer", "", "The expected issuer of attestations.")
 	rootCmd.PersistentFlags().StringVar(&expectedSan, "expected_san", "", "The expect san of attestations.")
 
 }
+
+// Returns the per-user cache dir for GitHub responses, or "" if there isn't one.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sourcetool", "github")
+}
@@ -9,7 +9,7 @@ import (
 	"log"
 
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"github.com/spf13/cobra"
 )
 
@@ -30,19 +30,22 @@ var (
 )
 
 func doVerifyCommit(commit, owner, repo, branch string) {
-	if commit == "" || owner == "" || repo == "" || branch == "" {
-		log.Fatal("Must set commit, owner, repo, and branch flags.")
+	if commit == "" || branch == "" {
+		log.Fatal("Must set commit and branch flags.")
+	}
+	if needsOwnerAndRepo() && (owner == "" || repo == "") {
+		log.Fatal("Must set owner and repo flags.")
 	}
 
-	gh_connection := gh_control.NewGhConnection(owner, repo, gh_control.BranchToFullRef(branch)).WithAuthToken(githubToken)
+	platform := getPlatform(owner, repo, source_control.BranchToFullRef(branch))
 	ctx := context.Background()
 
-	_, vsaPred, err := attest.GetVsa(ctx, gh_connection, getVerifier(), commit, gh_connection.GetFullRef())
+	_, vsaPred, err := attest.GetVsa(ctx, platform, getVerifier(), commit, platform.GetFullRef())
 	if err != nil {
 		log.Fatal(err)
 	}
 	if vsaPred == nil {
-		fmt.Printf("FAILED: no VSA matching commit '%s' on branch '%s' found in github.com/%s/%s\n", commit, branch, owner, repo)
+		fmt.Printf("FAILED: no VSA matching commit '%s' on branch '%s' found in %s\n", commit, branch, platform.GetRepoUri())
 		return
 	}
 
@@ -52,8 +55,8 @@ func doVerifyCommit(commit, owner, repo, branch string) {
 func init() {
 	rootCmd.AddCommand(verifycommitCmd)
 
-	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.owner, "owner", "", "The GitHub repository owner - required.")
-	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.repo, "repo", "", "The GitHub repository name - required.")
+	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.owner, "owner", "", "The GitHub repository owner - required unless --platform local.")
+	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.repo, "repo", "", "The GitHub repository name - required unless --platform local.")
 	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.branch, "branch", "", "The branch within the repository - required.")
 	verifycommitCmd.Flags().StringVar(&verifyCommitArgs.commit, "commit", "", "The commit to check - required.")
 
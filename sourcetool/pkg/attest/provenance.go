@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,8 +16,8 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 )
 
 const SourceProvPredicateType = "https://github.com/slsa-framework/slsa-source-poc/source-provenance/v1-draft"
@@ -26,16 +27,30 @@ const TagProvPredicateType = "https://github.com/slsa-framework/slsa-source-poc/
 // The git commit this corresponds to is encoded in the surrounding statement.
 type SourceProvenancePred struct {
 	// The commit preceding 'Commit' in the current context.
-	PrevCommit   string    `json:"prev_commit"`
+	PrevCommit string `json:"prev_commit"`
+	// All the parents of the commit. For a merge commit the first is the commit it was
+	// merged into and the rest are the commits merged in.
+	Parents      []string  `json:"parents,omitempty"`
 	RepoUri      string    `json:"repo_uri"`
 	ActivityType string    `json:"activity_type"`
 	Actor        string    `json:"actor"`
 	Branch       string    `json:"branch"`
 	CreatedOn    time.Time `json:"created_on"`
-	// TODO: get the author of the PR (if this was from a PR).
 
 	// The controls enabled at the time this commit was pushed.
 	Controls slsa_types.Controls `json:"controls"`
+	// The times the rules enforcing controls were bypassed since the previous provenance.
+	// Controls that were bypassed are only enforced since the bypass.
+	Bypasses []slsa_types.ControlBypass `json:"bypasses,omitempty"`
+	// The times the branch's history was broken (e.g. force pushed) since the previous
+	// provenance, including by this push. Controls are only enforced since the last of them.
+	Discontinuities []slsa_types.Discontinuity `json:"discontinuities,omitempty"`
+	// The results of the checks (e.g. CI) that ran against this commit, or the changes it merged.
+	Checks []slsa_types.CheckResult `json:"checks,omitempty"`
+	// The signature on this commit, if it's signed.
+	Signature *slsa_types.CommitSignature `json:"signature,omitempty"`
+	// How the pull request this commit merged was reviewed, if it was a pr_merge.
+	Review *slsa_types.ReviewEvidence `json:"review,omitempty"`
 }
 
 // Summary of a summary
@@ -55,12 +70,12 @@ type TagProvenancePred struct {
 }
 
 type ProvenanceAttestor struct {
-	verifier      Verifier
-	gh_connection *gh_control.GitHubConnection
+	verifier Verifier
+	platform source_control.SourceControlPlatform
 }
 
-func NewProvenanceAttestor(gh_connection *gh_control.GitHubConnection, verifier Verifier) *ProvenanceAttestor {
-	return &ProvenanceAttestor{verifier: verifier, gh_connection: gh_connection}
+func NewProvenanceAttestor(platform source_control.SourceControlPlatform, verifier Verifier) *ProvenanceAttestor {
+	return &ProvenanceAttestor{verifier: verifier, platform: platform}
 }
 
 func GetSourceProvPred(statement *spb.Statement) (*SourceProvenancePred, error) {
@@ -144,33 +159,44 @@ func addPredToStatement(provPred any, predicateType, commit string) (*spb.Statem
 	return &statementPb, nil
 }
 
-// Create provenance for the current commit without any context from the previous provenance (if any).
-func (pa ProvenanceAttestor) createCurrentProvenance(ctx context.Context, commit, prevCommit, ref string) (*spb.Statement, error) {
-	controlStatus, err := pa.gh_connection.GetBranchControls(ctx, commit, ref)
-	if err != nil {
-		return nil, err
-	}
-
+// Create provenance for 'commit', pushed with 'controlStatus', without any context from the
+// previous provenance (if any).
+func (pa ProvenanceAttestor) createCurrentProvenance(ctx context.Context, controlStatus *source_control.ControlStatus, review *slsa_types.ReviewEvidence, commit, prevCommit, ref string) (*SourceProvenancePred, error) {
 	curTime := time.Now()
 
 	var curProvPred SourceProvenancePred
+	var err error
 	curProvPred.PrevCommit = prevCommit
-	curProvPred.RepoUri = pa.gh_connection.GetRepoUri()
+	curProvPred.RepoUri = pa.platform.GetRepoUri()
 	curProvPred.Actor = controlStatus.ActorLogin
 	curProvPred.ActivityType = controlStatus.ActivityType
 	curProvPred.Branch = ref
 	curProvPred.CreatedOn = curTime
-	curProvPred.Controls = controlStatus.Controls
+	// Copied since each commit's start times are updated separately.
+	curProvPred.Controls = slices.Clone(controlStatus.Controls)
+	curProvPred.Review = review
+	curProvPred.Parents, err = pa.platform.GetParents(ctx, commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get parents of %s: %w", commit, err)
+	}
+	curProvPred.Checks, err = pa.getCheckResults(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+	curProvPred.Signature, err = pa.getCommitSignature(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
 
 	// At the very least provenance is available starting now. :)
 	curProvPred.Controls.AddControl(&slsa_types.Control{Name: slsa_types.ProvenanceAvailable, Since: curTime})
 
-	return addPredToStatement(&curProvPred, SourceProvPredicateType, commit)
+	return &curProvPred, nil
 }
 
 // Gets provenance for the commit from git notes.
 func (pa ProvenanceAttestor) GetProvenance(ctx context.Context, commit, ref string) (*spb.Statement, *SourceProvenancePred, error) {
-	notes, err := pa.gh_connection.GetNotesForCommit(ctx, commit)
+	notes, err := pa.platform.GetNotesForCommit(ctx, commit)
 	if notes == "" {
 		log.Printf("didn't find notes for commit %s", commit)
 		return nil, nil, nil
@@ -206,7 +232,7 @@ func (pa ProvenanceAttestor) getProvFromReader(reader *BundleReader, commit, ref
 		if err != nil {
 			return nil, nil, err
 		}
-		if ref == gh_control.AnyReference || prevProdPred.Branch == ref {
+		if ref == source_control.AnyReference || prevProdPred.Branch == ref {
 			// Should be good!
 			return stmt, prevProdPred, nil
 		} else {
@@ -231,32 +257,229 @@ func (pa ProvenanceAttestor) getPrevProvenance(ctx context.Context, prevAttPath,
 	return pa.GetProvenance(ctx, prevCommit, ref)
 }
 
+// Gets the times the rules enforcing controls on 'ref' were bypassed between 'from' and 'to'.
+// Returns false if the platform can't tell, in which case they may have been.
+func (pa ProvenanceAttestor) getBypasses(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.ControlBypass, bool, error) {
+	detector, ok := pa.platform.(source_control.BypassDetector)
+	if !ok {
+		return nil, false, nil
+	}
+	bypasses, err := detector.GetControlBypasses(ctx, ref, from, to)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not check for bypassed controls: %w", err)
+	}
+	return bypasses, true, nil
+}
+
+// Reports whether the platform carries start times forward to 'curProvPred' without checking
+// for bypasses or breaks in the history. Only if force pushes are blocked, since otherwise
+// the history could have been replaced without the platform's rules being bypassed.
+func (pa ProvenanceAttestor) carryForwardUnchecked(curProvPred *SourceProvenancePred) bool {
+	decider, ok := pa.platform.(source_control.UncheckedCarryForward)
+	if !ok || !decider.CarryForwardUnchecked() {
+		return false
+	}
+	return curProvPred.Controls.GetControl(slsa_types.ContinuityEnforced) != nil
+}
+
+// Gets the results of the checks that ran against 'commit', if the platform can tell.
+func (pa ProvenanceAttestor) getCheckResults(ctx context.Context, commit string) ([]slsa_types.CheckResult, error) {
+	provider, ok := pa.platform.(source_control.CheckResultsProvider)
+	if !ok {
+		return nil, nil
+	}
+	results, err := provider.GetCheckResults(ctx, commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get check results: %w", err)
+	}
+	return results, nil
+}
+
+// Gets the signature on 'commit', if the platform can verify it.
+func (pa ProvenanceAttestor) getCommitSignature(ctx context.Context, commit string) (*slsa_types.CommitSignature, error) {
+	verifier, ok := pa.platform.(source_control.CommitSignatureVerifier)
+	if !ok {
+		return nil, nil
+	}
+	signature, err := verifier.GetCommitSignature(ctx, commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit signature: %w", err)
+	}
+	return signature, nil
+}
+
+// Gets the times the history of 'ref' was broken between 'from' and 'to'.
+// Returns false if the platform can't tell, in which case it may have been.
+func (pa ProvenanceAttestor) getDiscontinuities(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.Discontinuity, bool, error) {
+	detector, ok := pa.platform.(source_control.DiscontinuityDetector)
+	if !ok {
+		return nil, false, nil
+	}
+	discontinuities, err := detector.GetDiscontinuities(ctx, ref, from, to)
+	if errors.Is(err, source_control.ErrHistoryIncomplete) {
+		log.Printf("cannot check for breaks in the history of %s since %v: %v", ref, from, err)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("could not check for breaks in the history of %s: %w", ref, err)
+	}
+	return discontinuities, true, nil
+}
+
+// Gets how the pull request merged as 'commit' was reviewed, if the platform can tell.
+func (pa ProvenanceAttestor) getReviewEvidence(ctx context.Context, commit string) (*slsa_types.ReviewEvidence, error) {
+	provider, ok := pa.platform.(source_control.ReviewEvidenceProvider)
+	if !ok {
+		return nil, nil
+	}
+	review, err := provider.GetReviewEvidence(ctx, commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get review evidence: %w", err)
+	}
+	return review, nil
+}
+
+// Returns the most recent bypass of 'control', nil if it wasn't bypassed.
+func lastBypass(bypasses []slsa_types.ControlBypass, control string) *slsa_types.ControlBypass {
+	var last *slsa_types.ControlBypass
+	for i, bypass := range bypasses {
+		if bypass.Control == control && (last == nil || bypass.Time.After(last.Time)) {
+			last = &bypasses[i]
+		}
+	}
+	return last
+}
+
 func (pa ProvenanceAttestor) CreateSourceProvenance(ctx context.Context, prevAttPath, commit, prevCommit, ref string) (*spb.Statement, error) {
+	stmts, err := pa.CreatePushProvenance(ctx, prevAttPath, []string{commit}, prevCommit, "", ref)
+	if err != nil {
+		return nil, err
+	}
+	return stmts[0], nil
+}
+
+// Creates provenance for each of 'commits', the commits a single push added to 'ref' in order
+// (oldest first) following first parents from 'prevCommit'. They were all pushed with the
+// controls, actor and review of the last of them, the new tip of 'ref'.
+// 'before' is the commit 'ref' was at before the push, "" to ask the platform. If it isn't
+// 'prevCommit' (e.g. after a force push) the provenance records the break in the history.
+func (pa ProvenanceAttestor) CreatePushProvenance(ctx context.Context, prevAttPath string, commits []string, prevCommit, before, ref string) ([]*spb.Statement, error) {
 	// Source provenance is based on
-	// 1. The current control situation (we assume 'commit' has _just_ occurred).
+	// 1. The current control situation (we assume the push has _just_ occurred).
 	// 2. How long the properties have been enforced according to the previous provenance.
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits to create provenance for")
+	}
+	tip := commits[len(commits)-1]
 
-	curProv, err := pa.createCurrentProvenance(ctx, commit, prevCommit, ref)
+	controlStatus, err := pa.platform.GetBranchControls(ctx, tip, ref)
 	if err != nil {
 		return nil, err
 	}
+	if before == "" {
+		before = controlStatus.BeforeCommit
+	}
+	var review *slsa_types.ReviewEvidence
+	if controlStatus.ActivityType == "pr_merge" {
+		review, err = pa.getReviewEvidence(ctx, tip)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	prevProvStmt, prevProvPred, err := pa.getPrevProvenance(ctx, prevAttPath, prevCommit, ref)
+	_, prevProvPred, err := pa.getPrevProvenance(ctx, prevAttPath, prevCommit, ref)
 	if err != nil {
 		return nil, err
 	}
 
-	// No prior provenance found, so we just go with current.
-	if prevProvStmt == nil || prevProvPred == nil {
-		log.Printf("No previous provenance found, have to bootstrap\n")
-		return curProv, nil
+	var stmts []*spb.Statement
+	for i, commit := range commits {
+		curProvPred, err := pa.createCurrentProvenance(ctx, controlStatus, review, commit, prevCommit, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			// Pushed together, so the controls have been enforced as long as on the commit before.
+			curProvPred.Controls = slices.Clone(prevProvPred.Controls)
+		} else if prevProvPred == nil {
+			// No prior provenance found, so we just go with current.
+			log.Printf("No previous provenance found, have to bootstrap\n")
+			curProvPred.Discontinuities, _, err = pa.getDiscontinuities(ctx, ref, controlStatus.CommitPushTime, curProvPred.CreatedOn)
+			if err != nil {
+				return nil, err
+			}
+			if last := lastDiscontinuity(curProvPred.Discontinuities); last != nil {
+				log.Printf("history of %s was broken by %s (%s) at %v", ref, last.Actor, last.Type, last.Time)
+				restartControls(curProvPred, last.Time)
+			}
+		} else {
+			// The previous provenance was created right after the previous commit was pushed.
+			var bypassesChecked bool
+			curProvPred.Bypasses, bypassesChecked, err = pa.getBypasses(ctx, ref, prevProvPred.CreatedOn, curProvPred.CreatedOn)
+			if err != nil {
+				return nil, err
+			}
+			var discontinuitiesChecked bool
+			curProvPred.Discontinuities, discontinuitiesChecked, err = pa.getDiscontinuities(ctx, ref, prevProvPred.CreatedOn, curProvPred.CreatedOn)
+			if err != nil {
+				return nil, err
+			}
+			// The previous provenance only vouches for the history it was created for.
+			if before != "" && !source_control.IsZeroCommit(before) && before != prevCommit {
+				curProvPred.Discontinuities = append(curProvPred.Discontinuities, slsa_types.Discontinuity{
+					Type:   slsa_types.DiscontinuityUnexpectedPrevCommit,
+					Actor:  controlStatus.ActorLogin,
+					Time:   controlStatus.CommitPushTime,
+					Before: before,
+					After:  tip,
+				})
+			}
+
+			if last := lastDiscontinuity(curProvPred.Discontinuities); last != nil {
+				log.Printf("history of %s was broken by %s (%s) at %v, not carrying forward previous start times", ref, last.Actor, last.Type, last.Time)
+				restartControls(curProvPred, last.Time)
+			} else if before == "" {
+				log.Printf("cannot tell which commit the push to %s started from, not carrying forward previous start times", ref)
+			} else if (!bypassesChecked || !discontinuitiesChecked) && !pa.carryForwardUnchecked(curProvPred) {
+				log.Printf("cannot tell if the rules protecting %s were bypassed or its history was broken, not carrying forward previous start times", ref)
+			} else {
+				carryForward(prevProvPred, curProvPred)
+			}
+		}
+
+		stmt, err := addPredToStatement(curProvPred, SourceProvPredicateType, commit)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		prevCommit, prevProvPred = commit, curProvPred
 	}
+	return stmts, nil
+}
 
-	curProvPred, err := GetSourceProvPred(curProv)
-	if err != nil {
-		return nil, err
+// Returns the most recent discontinuity, nil if there weren't any.
+func lastDiscontinuity(discontinuities []slsa_types.Discontinuity) *slsa_types.Discontinuity {
+	var last *slsa_types.Discontinuity
+	for i, discontinuity := range discontinuities {
+		if last == nil || discontinuity.Time.After(last.Time) {
+			last = &discontinuities[i]
+		}
 	}
+	return last
+}
 
+// Makes each control in 'curProvPred' start no earlier than 'since', whatever the platform
+// says, since the branch's history before then may have been replaced.
+func restartControls(curProvPred *SourceProvenancePred, since time.Time) {
+	for i := range curProvPred.Controls {
+		curProvPred.Controls[i].Since = slsa_types.LaterTime(curProvPred.Controls[i].Since, since)
+	}
+}
+
+// Updates the start time of each control in 'curProvPred' to the oldest from 'prevProvPred',
+// if the control was enforced continuously since then according to 'curProvPred.Bypasses'.
+func carryForward(prevProvPred, curProvPred *SourceProvenancePred) {
 	// There was prior provenance, so update the Since field for each property
 	// to the oldest encountered.
 	for i, curControl := range curProvPred.Controls {
@@ -265,12 +488,22 @@ func (pa ProvenanceAttestor) CreateSourceProvenance(ctx context.Context, prevAtt
 		if prevControl == nil {
 			continue
 		}
+		// Whatever the control enforces now that it didn't before is only enforced from now.
+		if !curControl.CoveredBy(*prevControl) {
+			log.Printf("%s enforces more than it did in the previous provenance, not carrying forward its previous start time", curControl.Name)
+			continue
+		}
+		// The control wasn't enforced continuously if it was bypassed.
+		if bypass := lastBypass(curProvPred.Bypasses, curControl.Name); bypass != nil {
+			log.Printf("%s was bypassed by %s at %v, not carrying forward its previous start time", curControl.Name, bypass.Actor, bypass.Time)
+			curControl.Since = slsa_types.LaterTime(curControl.Since, bypass.Time)
+			curProvPred.Controls[i] = curControl
+			continue
+		}
 		curControl.Since = slsa_types.EarlierTime(curControl.Since, prevControl.Since)
 		// Update the value.
 		curProvPred.Controls[i] = curControl
 	}
-
-	return addPredToStatement(curProvPred, SourceProvPredicateType, commit)
 }
 
 func (pa ProvenanceAttestor) CreateTagProvenance(ctx context.Context, commit, ref, actor string) (*spb.Statement, error) {
@@ -278,7 +511,7 @@ func (pa ProvenanceAttestor) CreateTagProvenance(ctx context.Context, commit, re
 	// 2. Get a VSA associated with this commit, if any.
 	// 3. Record the levels and branches covered by that VSA in the provenance.
 
-	controlStatus, err := pa.gh_connection.GetTagControls(ctx, commit, ref)
+	controlStatus, err := pa.platform.GetTagControls(ctx, commit, ref)
 	if err != nil {
 		return nil, err
 	}
@@ -286,7 +519,7 @@ func (pa ProvenanceAttestor) CreateTagProvenance(ctx context.Context, commit, re
 	// Find the most recent VSA for this commit. Any reference is OK.
 	// TODO: in the future get all of them.
 	// TODO: we should actually verify this vsa: https://github.com/slsa-framework/slsa-source-poc/issues/148
-	vsaStatement, vsaPred, err := GetVsa(ctx, pa.gh_connection, pa.verifier, commit, gh_control.AnyReference)
+	vsaStatement, vsaPred, err := GetVsa(ctx, pa.platform, pa.verifier, commit, source_control.AnyReference)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching VSA when creating tag provenance %w", err)
 	}
@@ -303,7 +536,7 @@ func (pa ProvenanceAttestor) CreateTagProvenance(ctx context.Context, commit, re
 	}
 
 	curProvPred := TagProvenancePred{
-		RepoUri:   pa.gh_connection.GetRepoUri(),
+		RepoUri:   pa.platform.GetRepoUri(),
 		Actor:     actor,
 		Tag:       ref,
 		CreatedOn: curTime,
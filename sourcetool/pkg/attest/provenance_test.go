@@ -10,7 +10,9 @@ import (
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var rulesetOldTime = time.Now().Add(-time.Hour)
@@ -79,7 +81,7 @@ func newMockedGitHubClient(rulesetResponse *github.RepositoryRuleset, notesConte
 // Helper to create a test GH Branch connection with no client.
 func newTestGhConnection(owner, repo, branch string, rulesetResponse *github.RepositoryRuleset, notesContent *github.RepositoryContent) *gh_control.GitHubConnection {
 	return gh_control.NewGhConnectionWithClient(
-		owner, repo, gh_control.BranchToFullRef(branch),
+		owner, repo, source_control.BranchToFullRef(branch),
 		newMockedGitHubClient(rulesetResponse, notesContent))
 }
 
@@ -174,3 +176,688 @@ func TestCreateTagProvenance(t *testing.T) {
 
 	assertTagProvPredsEqual(t, *tagPred, expectedPred)
 }
+
+func newTestSourceProvNote(t *testing.T, commit string, pred SourceProvenancePred) string {
+	stmt, err := addPredToStatement(&pred, SourceProvPredicateType, commit)
+	if err != nil {
+		t.Fatalf("failure creating test provenance: %v", err)
+	}
+	note, err := protojson.Marshal(stmt)
+	if err != nil {
+		t.Fatalf("failure marshaling test provenance: %v", err)
+	}
+	return string(note)
+}
+
+func TestCreateSourceProvenance(t *testing.T) {
+	platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	platform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		BeforeCommit:   "prev123",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+		},
+	}
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch: "refs/heads/main",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+			{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+		},
+	})
+	checks := []slsa_types.CheckResult{{Name: "build", Conclusion: "success", App: "github-actions", AppId: 15368}}
+	platform.CheckResults = map[string][]slsa_types.CheckResult{"abc123": checks}
+	// A merge commit.
+	platform.Parents["abc123"] = []string{"prev123", "feature456"}
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+
+	if !DoesSubjectIncludeCommit(stmt, "abc123") {
+		t.Errorf("statement subject %v does not match expected %v", stmt.Subject, "abc123")
+	}
+
+	provPred, err := GetSourceProvPred(stmt)
+	if err != nil {
+		t.Fatalf("error getting source prov %v", err)
+	}
+
+	if provPred.RepoUri != "https://example.com/owner/repo" {
+		t.Errorf("RepoUri %v does not match expected value %v", provPred.RepoUri, "https://example.com/owner/repo")
+	}
+	if provPred.PrevCommit != "prev123" {
+		t.Errorf("PrevCommit %v does not match expected value %v", provPred.PrevCommit, "prev123")
+	}
+	if provPred.Actor != "the-pusher" {
+		t.Errorf("Actor %v does not match expected value %v", provPred.Actor, "the-pusher")
+	}
+	if !reflect.DeepEqual(provPred.Parents, []string{"prev123", "feature456"}) {
+		t.Errorf("Parents %v does not match expected value %v", provPred.Parents, []string{"prev123", "feature456"})
+	}
+	if !reflect.DeepEqual(provPred.Checks, checks) {
+		t.Errorf("Checks %v does not match expected value %v", provPred.Checks, checks)
+	}
+
+	expectedControls := slsa_types.Controls{
+		{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+		{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+	}
+	if len(provPred.Controls) != len(expectedControls) {
+		t.Fatalf("Controls %v does not match expected value %v", provPred.Controls, expectedControls)
+	}
+	for ci := range expectedControls {
+		if provPred.Controls[ci].Name != expectedControls[ci].Name ||
+			!timesEqualWithinMargin(provPred.Controls[ci].Since, expectedControls[ci].Since, time.Second) {
+			t.Errorf("control at [%d] %v does not match expected %v", ci, provPred.Controls[ci], expectedControls[ci])
+		}
+	}
+}
+
+func TestCreateSourceProvenanceReview(t *testing.T) {
+	review := &slsa_types.ReviewEvidence{
+		PullRequest: "https://github.com/owner/repo/pull/7",
+		Author:      "author",
+		Approvals:   []slsa_types.Approval{{Reviewer: "reviewer", Commit: "head123", Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	tests := []struct {
+		name         string
+		activityType string
+		expected     *slsa_types.ReviewEvidence
+	}{
+		{name: "pr_merge", activityType: "pr_merge", expected: review},
+		{name: "push", activityType: "push"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+			platform.ControlStatus = &source_control.ControlStatus{CommitPushTime: time.Now(), ActivityType: tt.activityType}
+			platform.Reviews = map[string]*slsa_types.ReviewEvidence{"abc123": review}
+
+			pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+			stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("error creating source prov %v", err)
+			}
+			provPred, err := GetSourceProvPred(stmt)
+			if err != nil {
+				t.Fatalf("error getting source prov %v", err)
+			}
+			if !reflect.DeepEqual(provPred.Review, tt.expected) {
+				t.Errorf("Review %+v does not match expected value %+v", provPred.Review, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCreateSourceProvenance_MoreChecksRequired(t *testing.T) {
+	build := slsa_types.StatusCheck{Context: "build"}
+	lint := slsa_types.StatusCheck{Context: "lint"}
+	platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	platform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		BeforeCommit:   "prev123",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+			{Name: slsa_types.RequiredStatusChecks, Since: rulesetOldTime, RequiredChecks: []slsa_types.StatusCheck{build, lint}},
+		},
+	}
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch: "refs/heads/main",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+			{Name: slsa_types.RequiredStatusChecks, Since: prevSince, RequiredChecks: []slsa_types.StatusCheck{build}},
+		},
+	})
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+	provPred, err := GetSourceProvPred(stmt)
+	if err != nil {
+		t.Fatalf("error getting source prov %v", err)
+	}
+
+	// 'lint' is newly required, so the checks haven't all been required since the previous provenance.
+	statusChecks := provPred.Controls.GetControl(slsa_types.RequiredStatusChecks)
+	if statusChecks == nil || !timesEqualWithinMargin(statusChecks.Since, rulesetOldTime, time.Second) {
+		t.Errorf("status checks control %v, want it since %v", statusChecks, rulesetOldTime)
+	}
+	continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+	if continuity == nil || !timesEqualWithinMargin(continuity.Since, prevSince, time.Second) {
+		t.Errorf("continuity control %v, want it since %v", continuity, prevSince)
+	}
+}
+
+func TestCreateSourceProvenance_FewerBypassActors(t *testing.T) {
+	admin := slsa_types.BypassActor{ActorType: "OrganizationAdmin", BypassMode: "always"}
+	expunger := slsa_types.BypassActor{ActorType: "Team", ActorId: 7, BypassMode: "always"}
+	platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	platform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		BeforeCommit:   "prev123",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime, BypassActors: []slsa_types.BypassActor{expunger}},
+			{Name: slsa_types.ReviewEnforced, Since: rulesetOldTime, BypassActors: []slsa_types.BypassActor{admin, expunger}},
+		},
+	}
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch: "refs/heads/main",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: prevSince, BypassActors: []slsa_types.BypassActor{admin, expunger}},
+			{Name: slsa_types.ReviewEnforced, Since: prevSince, BypassActors: []slsa_types.BypassActor{expunger}},
+		},
+	})
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+	provPred, err := GetSourceProvPred(stmt)
+	if err != nil {
+		t.Fatalf("error getting source prov %v", err)
+	}
+
+	// Admins could bypass continuity until now, so it's only been enforced without them since now.
+	continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+	if continuity == nil || !timesEqualWithinMargin(continuity.Since, rulesetOldTime, time.Second) {
+		t.Errorf("continuity control %v, want it since %v", continuity, rulesetOldTime)
+	}
+	review := provPred.Controls.GetControl(slsa_types.ReviewEnforced)
+	if review == nil || !timesEqualWithinMargin(review.Since, prevSince, time.Second) {
+		t.Errorf("review control %v, want it since %v", review, prevSince)
+	}
+}
+
+func TestCreateSourceProvenance_Bypassed(t *testing.T) {
+	platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	platform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		BeforeCommit:   "prev123",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+			{Name: slsa_types.ReviewEnforced, Since: rulesetOldTime},
+		},
+	}
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	prevCreatedOn := time.Now().Add(-30 * time.Minute)
+	platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch:    "refs/heads/main",
+		CreatedOn: prevCreatedOn,
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+			{Name: slsa_types.ReviewEnforced, Since: prevSince},
+			{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+		},
+	})
+	bypassTime := prevCreatedOn.Add(10 * time.Minute)
+	platform.Bypasses = []slsa_types.ControlBypass{
+		// Before the previous commit, already accounted for.
+		{Control: slsa_types.ContinuityEnforced, Actor: "admin", Commit: "older", Time: prevCreatedOn.Add(-time.Minute)},
+		{Control: slsa_types.ReviewEnforced, Actor: "admin", Commit: "between", Time: bypassTime},
+	}
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+	provPred, err := GetSourceProvPred(stmt)
+	if err != nil {
+		t.Fatalf("error getting source prov %v", err)
+	}
+
+	expectedControls := slsa_types.Controls{
+		{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+		{Name: slsa_types.ReviewEnforced, Since: bypassTime},
+		{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+	}
+	if len(provPred.Controls) != len(expectedControls) {
+		t.Fatalf("Controls %v does not match expected value %v", provPred.Controls, expectedControls)
+	}
+	for ci := range expectedControls {
+		if provPred.Controls[ci].Name != expectedControls[ci].Name ||
+			!timesEqualWithinMargin(provPred.Controls[ci].Since, expectedControls[ci].Since, time.Second) {
+			t.Errorf("control at [%d] %v does not match expected %v", ci, provPred.Controls[ci], expectedControls[ci])
+		}
+	}
+	if len(provPred.Bypasses) != 1 || provPred.Bypasses[0].Commit != "between" {
+		t.Errorf("Bypasses %v, want only the bypass since the previous provenance", provPred.Bypasses)
+	}
+}
+
+func TestCreateSourceProvenance_BypassesUnknown(t *testing.T) {
+	mockPlatform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	mockPlatform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+		},
+	}
+	mockPlatform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch:    "refs/heads/main",
+		CreatedOn: time.Now().Add(-30 * time.Minute),
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime.Add(-time.Hour)},
+		},
+	})
+	// Only the methods every platform has, so it can't tell if the rules were bypassed.
+	platform := struct {
+		source_control.SourceControlPlatform
+	}{mockPlatform}
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+	provPred, err := GetSourceProvPred(stmt)
+	if err != nil {
+		t.Fatalf("error getting source prov %v", err)
+	}
+
+	continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+	if continuity == nil || !timesEqualWithinMargin(continuity.Since, rulesetOldTime, time.Second) {
+		t.Errorf("continuity control %v, want it since %v", continuity, rulesetOldTime)
+	}
+}
+
+func TestCreateSourceProvenance_DiscontinuitiesUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the platform can tell if the history was broken.
+		detectsDiscontinuities bool
+		beforeCommit           string
+	}{
+		{
+			name:         "platform can't detect discontinuities",
+			beforeCommit: "prev123",
+		},
+		{
+			name:                   "push start unknown",
+			detectsDiscontinuities: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPlatform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+			mockPlatform.ControlStatus = &source_control.ControlStatus{
+				CommitPushTime: time.Now(),
+				ActorLogin:     "the-pusher",
+				ActivityType:   "push",
+				BeforeCommit:   tt.beforeCommit,
+				Controls: slsa_types.Controls{
+					{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+				},
+			}
+			mockPlatform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+				Branch:    "refs/heads/main",
+				CreatedOn: time.Now().Add(-30 * time.Minute),
+				Controls: slsa_types.Controls{
+					{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime.Add(-time.Hour)},
+				},
+			})
+			var platform source_control.SourceControlPlatform = mockPlatform
+			if !tt.detectsDiscontinuities {
+				platform = struct {
+					source_control.SourceControlPlatform
+					source_control.BypassDetector
+				}{mockPlatform, mockPlatform}
+			}
+
+			pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+			stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("error creating source prov %v", err)
+			}
+			provPred, err := GetSourceProvPred(stmt)
+			if err != nil {
+				t.Fatalf("error getting source prov %v", err)
+			}
+
+			continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+			if continuity == nil || !timesEqualWithinMargin(continuity.Since, rulesetOldTime, time.Second) {
+				t.Errorf("continuity control %v, want it since %v", continuity, rulesetOldTime)
+			}
+		})
+	}
+}
+
+func TestCreateSourceProvenance_HistoryIncomplete(t *testing.T) {
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	tests := []struct {
+		name string
+		// Whether there's provenance for the previous commit.
+		prevProv bool
+		// How long ago the platform's record of the branch starts.
+		historyAge    time.Duration
+		expectedSince time.Time
+	}{
+		{
+			name:          "history covers the previous provenance",
+			prevProv:      true,
+			historyAge:    time.Hour,
+			expectedSince: prevSince,
+		},
+		{
+			name:          "previous provenance older than the history",
+			prevProv:      true,
+			historyAge:    10 * time.Minute,
+			expectedSince: rulesetOldTime,
+		},
+		{
+			// Even the push is older than the history.
+			name:          "no previous provenance",
+			historyAge:    30 * time.Second,
+			expectedSince: rulesetOldTime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+			platform.ControlStatus = &source_control.ControlStatus{
+				CommitPushTime: now.Add(-time.Minute),
+				ActorLogin:     "the-pusher",
+				ActivityType:   "push",
+				BeforeCommit:   "prev123",
+				Controls: slsa_types.Controls{
+					{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+				},
+			}
+			platform.HistoryStart = now.Add(-tt.historyAge)
+			if tt.prevProv {
+				platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+					Branch:    "refs/heads/main",
+					CreatedOn: now.Add(-30 * time.Minute),
+					Controls: slsa_types.Controls{
+						{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+					},
+				})
+			}
+
+			pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+			stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("error creating source prov %v", err)
+			}
+			provPred, err := GetSourceProvPred(stmt)
+			if err != nil {
+				t.Fatalf("error getting source prov %v", err)
+			}
+
+			continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+			if continuity == nil || !timesEqualWithinMargin(continuity.Since, tt.expectedSince, time.Second) {
+				t.Errorf("continuity control %v, want it since %v", continuity, tt.expectedSince)
+			}
+		})
+	}
+}
+
+// A platform that can't tell if its rules were bypassed or the history was broken, but
+// carries start times forward anyway.
+type uncheckedPlatform struct {
+	source_control.SourceControlPlatform
+}
+
+func (uncheckedPlatform) CarryForwardUnchecked() bool {
+	return true
+}
+
+func TestCreateSourceProvenance_CarryForwardUnchecked(t *testing.T) {
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	tests := []struct {
+		name          string
+		controls      slsa_types.Controls
+		beforeCommit  string
+		expectedSince time.Time
+	}{
+		{
+			name:          "force pushes blocked",
+			controls:      slsa_types.Controls{{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime}, {Name: slsa_types.ReviewEnforced, Since: rulesetOldTime}},
+			beforeCommit:  "prev123",
+			expectedSince: prevSince,
+		},
+		{
+			name:          "force pushes allowed",
+			controls:      slsa_types.Controls{{Name: slsa_types.ReviewEnforced, Since: rulesetOldTime}},
+			beforeCommit:  "prev123",
+			expectedSince: rulesetOldTime,
+		},
+		{
+			name:          "push start unknown",
+			controls:      slsa_types.Controls{{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime}, {Name: slsa_types.ReviewEnforced, Since: rulesetOldTime}},
+			expectedSince: rulesetOldTime,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPlatform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+			mockPlatform.ControlStatus = &source_control.ControlStatus{
+				CommitPushTime: time.Now(),
+				ActorLogin:     "the-pusher",
+				ActivityType:   "push",
+				BeforeCommit:   tt.beforeCommit,
+				Controls:       tt.controls,
+			}
+			mockPlatform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+				Branch:    "refs/heads/main",
+				CreatedOn: time.Now().Add(-30 * time.Minute),
+				Controls: slsa_types.Controls{
+					{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+					{Name: slsa_types.ReviewEnforced, Since: prevSince},
+				},
+			})
+
+			pa := NewProvenanceAttestor(uncheckedPlatform{mockPlatform}, testsupport.NewMockVerifier())
+			stmt, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("error creating source prov %v", err)
+			}
+			provPred, err := GetSourceProvPred(stmt)
+			if err != nil {
+				t.Fatalf("error getting source prov %v", err)
+			}
+
+			review := provPred.Controls.GetControl(slsa_types.ReviewEnforced)
+			if review == nil || !timesEqualWithinMargin(review.Since, tt.expectedSince, time.Second) {
+				t.Errorf("review control %v, want it since %v", review, tt.expectedSince)
+			}
+		})
+	}
+}
+
+func TestCreatePushProvenance(t *testing.T) {
+	platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+	platform.ControlStatus = &source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		ActorLogin:     "the-pusher",
+		ActivityType:   "push",
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+			{Name: slsa_types.ReviewEnforced, Since: rulesetOldTime},
+		},
+	}
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	prevCreatedOn := time.Now().Add(-30 * time.Minute)
+	platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+		Branch:    "refs/heads/main",
+		CreatedOn: prevCreatedOn,
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+			{Name: slsa_types.ReviewEnforced, Since: prevSince},
+			{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+		},
+	})
+	bypassTime := prevCreatedOn.Add(10 * time.Minute)
+	platform.Bypasses = []slsa_types.ControlBypass{
+		{Control: slsa_types.ReviewEnforced, Actor: "admin", Commit: "c3", Time: bypassTime},
+	}
+	commits := []string{"c1", "c2", "c3"}
+
+	pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+	stmts, err := pa.CreatePushProvenance(context.Background(), "", commits, "prev123", "prev123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("error creating source prov %v", err)
+	}
+	if len(stmts) != len(commits) {
+		t.Fatalf("got %d statements, want %d", len(stmts), len(commits))
+	}
+
+	expectedControls := slsa_types.Controls{
+		{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+		{Name: slsa_types.ReviewEnforced, Since: bypassTime},
+		{Name: slsa_types.ProvenanceAvailable, Since: prevSince},
+	}
+	prevCommit := "prev123"
+	for i, stmt := range stmts {
+		if !DoesSubjectIncludeCommit(stmt, commits[i]) {
+			t.Errorf("statement subject %v does not match expected %v", stmt.Subject, commits[i])
+		}
+		provPred, err := GetSourceProvPred(stmt)
+		if err != nil {
+			t.Fatalf("error getting source prov %v", err)
+		}
+		if provPred.PrevCommit != prevCommit {
+			t.Errorf("PrevCommit %v does not match expected value %v", provPred.PrevCommit, prevCommit)
+		}
+		prevCommit = commits[i]
+
+		if len(provPred.Controls) != len(expectedControls) {
+			t.Fatalf("Controls %v does not match expected value %v", provPred.Controls, expectedControls)
+		}
+		for ci := range expectedControls {
+			if provPred.Controls[ci].Name != expectedControls[ci].Name ||
+				!timesEqualWithinMargin(provPred.Controls[ci].Since, expectedControls[ci].Since, time.Second) {
+				t.Errorf("commit %s control at [%d] %v does not match expected %v", commits[i], ci, provPred.Controls[ci], expectedControls[ci])
+			}
+		}
+		// The bypass happened before the push, so it's recorded for the first commit.
+		if (i == 0) != (len(provPred.Bypasses) == 1) {
+			t.Errorf("commit %s Bypasses %v, want them only on the first commit", commits[i], provPred.Bypasses)
+		}
+	}
+}
+
+func TestCreateSourceProvenance_Discontinuity(t *testing.T) {
+	prevSince := rulesetOldTime.Add(-time.Hour)
+	prevCreatedOn := time.Now().Add(-30 * time.Minute)
+	forcePushTime := prevCreatedOn.Add(10 * time.Minute)
+	pushTime := prevCreatedOn.Add(20 * time.Minute)
+
+	tests := []struct {
+		name         string
+		beforeCommit string
+		// The commit the branch was at before the push according to the caller.
+		before                  string
+		noPrevProvenance        bool
+		discontinuities         []slsa_types.Discontinuity
+		expectedDiscontinuities []string
+		expectedSince           time.Time
+	}{
+		{
+			name:          "continuous",
+			beforeCommit:  "prev123",
+			expectedSince: prevSince,
+		},
+		{
+			name:         "force pushed since the previous provenance",
+			beforeCommit: "prev123",
+			discontinuities: []slsa_types.Discontinuity{
+				// Before the previous commit, already accounted for.
+				{Type: slsa_types.DiscontinuityForcePush, Actor: "admin", Time: prevCreatedOn.Add(-time.Minute)},
+				{Type: slsa_types.DiscontinuityBranchDeletion, Actor: "admin", Time: forcePushTime},
+			},
+			expectedDiscontinuities: []string{slsa_types.DiscontinuityBranchDeletion},
+			expectedSince:           forcePushTime,
+		},
+		{
+			name:             "force pushed without previous provenance",
+			noPrevProvenance: true,
+			discontinuities: []slsa_types.Discontinuity{
+				{Type: slsa_types.DiscontinuityForcePush, Actor: "the-pusher", Time: pushTime},
+			},
+			expectedDiscontinuities: []string{slsa_types.DiscontinuityForcePush},
+			expectedSince:           pushTime,
+		},
+		{
+			name:                    "previous commit wasn't the tip",
+			beforeCommit:            "other456",
+			expectedDiscontinuities: []string{slsa_types.DiscontinuityUnexpectedPrevCommit},
+			expectedSince:           pushTime,
+		},
+		{
+			name:                    "caller says the previous commit wasn't the tip",
+			before:                  "other456",
+			expectedDiscontinuities: []string{slsa_types.DiscontinuityUnexpectedPrevCommit},
+			expectedSince:           pushTime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platform := testsupport.NewMockPlatform("https://example.com/owner/repo", "refs/heads/main")
+			platform.ControlStatus = &source_control.ControlStatus{
+				CommitPushTime: pushTime,
+				ActorLogin:     "the-pusher",
+				ActivityType:   "push",
+				BeforeCommit:   tt.beforeCommit,
+				Controls: slsa_types.Controls{
+					{Name: slsa_types.ContinuityEnforced, Since: rulesetOldTime},
+				},
+			}
+			if !tt.noPrevProvenance {
+				platform.Notes["prev123"] = newTestSourceProvNote(t, "prev123", SourceProvenancePred{
+					Branch:    "refs/heads/main",
+					CreatedOn: prevCreatedOn,
+					Controls: slsa_types.Controls{
+						{Name: slsa_types.ContinuityEnforced, Since: prevSince},
+					},
+				})
+			}
+			platform.Discontinuities = tt.discontinuities
+
+			pa := NewProvenanceAttestor(platform, testsupport.NewMockVerifier())
+			stmts, err := pa.CreatePushProvenance(context.Background(), "", []string{"abc123"}, "prev123", tt.before, "refs/heads/main")
+			if err != nil {
+				t.Fatalf("error creating source prov %v", err)
+			}
+			provPred, err := GetSourceProvPred(stmts[0])
+			if err != nil {
+				t.Fatalf("error getting source prov %v", err)
+			}
+
+			var gotDiscontinuities []string
+			for _, discontinuity := range provPred.Discontinuities {
+				gotDiscontinuities = append(gotDiscontinuities, discontinuity.Type)
+			}
+			if !reflect.DeepEqual(gotDiscontinuities, tt.expectedDiscontinuities) {
+				t.Errorf("Discontinuities %v, want %v", gotDiscontinuities, tt.expectedDiscontinuities)
+			}
+			continuity := provPred.Controls.GetControl(slsa_types.ContinuityEnforced)
+			if continuity == nil || !timesEqualWithinMargin(continuity.Since, tt.expectedSince, time.Second) {
+				t.Errorf("continuity control %v, want it since %v", continuity, tt.expectedSince)
+			}
+		})
+	}
+}
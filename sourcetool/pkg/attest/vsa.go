@@ -9,8 +9,8 @@ import (
 
 	vpb "github.com/in-toto/attestation/go/predicates/vsa/v1"
 	spb "github.com/in-toto/attestation/go/v1"
-	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -67,8 +67,8 @@ func CreateUnsignedSourceVsa(repoUri, ref, commit string, verifiedLevels slsa_ty
 }
 
 // Gets provenance for the commit from git notes.
-func GetVsa(ctx context.Context, ghc *gh_control.GitHubConnection, verifier Verifier, commit, ref string) (*spb.Statement, *vpb.VerificationSummary, error) {
-	notes, err := ghc.GetNotesForCommit(ctx, commit)
+func GetVsa(ctx context.Context, platform source_control.SourceControlPlatform, verifier Verifier, commit, ref string) (*spb.Statement, *vpb.VerificationSummary, error) {
+	notes, err := platform.GetNotesForCommit(ctx, commit)
 	if notes == "" {
 		log.Printf("didn't find notes for commit %s", commit)
 		return nil, nil, nil
@@ -120,7 +120,7 @@ func MatchesTypeCommitAndRef(predicateType, commit, targetRef string) StatementM
 			return false
 		}
 		for _, ref := range refs {
-			if targetRef == gh_control.AnyReference || ref == targetRef {
+			if targetRef == source_control.AnyReference || ref == targetRef {
 				log.Printf("statement \n%v\n matches commit '%s' on ref '%s'", StatementToString(statement), commit, targetRef)
 				return true
 			}
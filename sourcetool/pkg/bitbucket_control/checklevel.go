@@ -0,0 +1,157 @@
+package bitbucket_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.UncheckedCarryForward = (*BitbucketConnection)(nil)
+
+// A pull request that was merged, regardless of flavor.
+type mergedPullRequest struct {
+	id       int
+	mergedBy string
+	mergedAt time.Time
+}
+
+// What Bitbucket enforces for a ref, regardless of flavor.
+type refRestrictions struct {
+	noRewrites             bool
+	noDeletes              bool
+	noDirectPushes         bool
+	requiredApprovals      int
+	resetApprovalsOnChange bool
+	mergeChecksEnforced    bool
+}
+
+// Finds the pull request that was merged as 'commit' into 'ref', nil if there isn't one.
+func (bc *BitbucketConnection) findMergedPullRequest(ctx context.Context, commit, ref string) (*mergedPullRequest, error) {
+	if bc.flavor == DataCenter {
+		return bc.dcMergedPullRequest(ctx, commit, ref)
+	}
+	return bc.cloudMergedPullRequest(ctx, commit, ref)
+}
+
+// Gets the restrictions that apply to the branch 'ref'.
+func (bc *BitbucketConnection) branchRestrictions(ctx context.Context, ref string) (*refRestrictions, error) {
+	if bc.flavor == DataCenter {
+		return bc.dcRestrictions(ctx, func(m *dcMatcher) bool { return m.matches(ref) })
+	}
+	return bc.cloudRestrictions(ctx, ref)
+}
+
+// Computes the continuity control returning nil if it's not enabled.
+// History may not be rewritten and the branch may not be deleted.
+func computeContinuityControl(restrictions *refRestrictions, pushTime time.Time) *slsa_types.Control {
+	if !restrictions.noRewrites || !restrictions.noDeletes {
+		log.Printf("branch restrictions (%+v) allow rewriting history or deletion, cannot be L2+", *restrictions)
+		return nil
+	}
+	// Bitbucket doesn't tell us when the restrictions were added, so we can only
+	// vouch for them as of this push.
+	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime}
+}
+
+// Computes the review control returning nil if it's not enabled.
+// Changes must go through a pull request, which needs approval, and approvals
+// must be reset when the pull request changes.
+func computeReviewControl(restrictions *refRestrictions, pushTime time.Time) *slsa_types.Control {
+	if !restrictions.noDirectPushes ||
+		!restrictions.mergeChecksEnforced ||
+		restrictions.requiredApprovals <= 0 ||
+		!restrictions.resetApprovalsOnChange {
+		return nil
+	}
+	return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime}
+}
+
+// Computes the immutable tags control returning nil if it's not enabled.
+// Bitbucket Cloud can't restrict tags, Data Center needs a restriction covering
+// every tag that prevents rewriting and deleting them.
+func (bc *BitbucketConnection) computeImmutableTagsControl(ctx context.Context, activityTime time.Time) (*slsa_types.Control, error) {
+	if bc.flavor != DataCenter {
+		return nil, nil
+	}
+	restrictions, err := bc.dcRestrictions(ctx, matchesAllTags)
+	if err != nil {
+		return nil, err
+	}
+	if !restrictions.noRewrites || !restrictions.noDeletes {
+		return nil, nil
+	}
+	return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: activityTime}, nil
+}
+
+// Bitbucket can't tell us when restrictions were bypassed or a branch was rewritten, but we
+// only count restrictions nobody is exempt from, so getting around them means changing them.
+// Controls only start at the push here, so without carrying them forward no policy with an
+// earlier start could be met.
+func (bc *BitbucketConnection) CarryForwardUnchecked() bool {
+	return true
+}
+
+// Determines the controls that are in place for a branch using Bitbucket's APIs
+// This is necessarily only as good as Bitbucket's controls and existing APIs.
+func (bc *BitbucketConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	if source_control.GetBranchFromRef(ref) == "" {
+		return nil, fmt.Errorf("ref %s is not a branch", ref)
+	}
+
+	// We want to know when this commit was merged to ensure the rules were active _then_.
+	pr, err := bc.findMergedPullRequest(ctx, commit, ref)
+	if err != nil {
+		return nil, fmt.Errorf("could not find pull request for commit %s: %w", commit, err)
+	}
+
+	var controlStatus source_control.ControlStatus
+	if pr != nil {
+		controlStatus = source_control.ControlStatus{
+			CommitPushTime: pr.mergedAt,
+			ActivityType:   "pr_merge",
+			ActorLogin:     pr.mergedBy,
+			Controls:       slsa_types.Controls{}}
+	} else {
+		// Bitbucket doesn't expose a record of direct pushes, so the best we can do is now.
+		log.Printf("no merged pull request found for commit %s, assuming it was pushed directly", commit)
+		controlStatus = source_control.ControlStatus{
+			CommitPushTime: time.Now(),
+			ActivityType:   "push",
+			Controls:       slsa_types.Controls{}}
+	}
+
+	restrictions, err := bc.branchRestrictions(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("could not get branch restrictions: %w", err)
+	}
+
+	controlStatus.Controls.AddControl(computeContinuityControl(restrictions, controlStatus.CommitPushTime))
+
+	controlStatus.Controls.AddControl(computeReviewControl(restrictions, controlStatus.CommitPushTime))
+
+	immutableTagsControl, err := bc.computeImmutableTagsControl(ctx, controlStatus.CommitPushTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
+
+func (bc *BitbucketConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		Controls:       slsa_types.Controls{}}
+
+	immutableTagsControl, err := bc.computeImmutableTagsControl(ctx, controlStatus.CommitPushTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
@@ -0,0 +1,364 @@
+package bitbucket_control
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+var mergeTime = time.Unix(1678886400, 0).UTC() // March 15, 2023 00:00:00 UTC
+
+const (
+	cloudRepoPath      = "repositories/workspace/repo/"
+	dcRepoPath         = "rest/api/1.0/projects/PROJ/repos/repo/"
+	dcRestrictionsPath = "rest/branch-permissions/2.0/projects/PROJ/repos/repo/restrictions"
+)
+
+// Starts a stand-in for the Bitbucket REST API that serves 'responses' keyed by
+// the path (with the query if one matches). Strings are served as-is, everything else as JSON.
+func newTestBitbucketConnection(t *testing.T, flavor Flavor, responses map[string]any) *BitbucketConnection {
+	t.Helper()
+	server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{
+		PathPrefix: "/",
+		Authenticated: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer test-token"
+		},
+	}, responses)
+
+	owner := "workspace"
+	if flavor == DataCenter {
+		owner = "PROJ"
+	}
+	bc, err := NewBitbucketConnectionWithClient(flavor, server.URL, owner, "repo", "refs/heads/main", nil, server.Client())
+	if err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+	return bc.WithAuthToken("test-token")
+}
+
+// Wraps values in a single Data Center page.
+func dcPage(values any) map[string]any {
+	return map[string]any{"values": values, "isLastPage": true}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func cloudMergedPR() map[string]any {
+	pr := cloudPullRequest{Id: 7, State: "MERGED", MergeCommit: &commit{Hash: "abc123"},
+		ClosedBy: &cloudUser{Nickname: "the-merger"}}
+	pr.Destination.Branch.Name = "main"
+	declined := cloudPullRequest{Id: 6, State: "DECLINED", MergeCommit: &commit{Hash: "abc123"}}
+	declined.Destination.Branch.Name = "main"
+	return map[string]any{"values": []cloudPullRequest{declined, pr}}
+}
+
+// The activity of the merged pull request, newest first. It was commented on after it was merged.
+func cloudMergedPRActivity() map[string]any {
+	return map[string]any{"values": []map[string]any{
+		{"comment": map[string]any{"created_on": mergeTime.Add(time.Hour)}},
+		{"update": map[string]any{"state": "MERGED", "date": mergeTime}},
+		{"approval": map[string]any{"date": mergeTime.Add(-time.Hour)}},
+		{"update": map[string]any{"state": "OPEN", "date": mergeTime.Add(-2 * time.Hour)}},
+	}}
+}
+
+func TestGetBranchControls_Cloud(t *testing.T) {
+	allRestrictions := []cloudRestriction{
+		{Kind: "force", BranchMatchKind: "glob", Pattern: "main"},
+		{Kind: "delete", BranchMatchKind: "glob", Pattern: "ma*"},
+		{Kind: "push", BranchMatchKind: "glob", Pattern: "**"},
+		{Kind: "require_approvals_to_merge", BranchMatchKind: "glob", Pattern: "main", Value: intPtr(1)},
+		{Kind: "reset_pullrequest_approvals_on_change", BranchMatchKind: "glob", Pattern: "main"},
+		{Kind: "enforce_merge_checks", BranchMatchKind: "glob", Pattern: "main"},
+	}
+
+	tests := []struct {
+		name             string
+		restrictions     []cloudRestriction
+		expectedControls slsa_types.Controls
+	}{
+		{
+			name:             "no restrictions",
+			restrictions:     []cloudRestriction{},
+			expectedControls: slsa_types.Controls{},
+		},
+		{
+			name:         "all restrictions",
+			restrictions: allRestrictions,
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+				{Name: slsa_types.ReviewEnforced, Since: mergeTime},
+			},
+		},
+		{
+			name: "merge checks not enforced",
+			restrictions: []cloudRestriction{
+				allRestrictions[0], allRestrictions[1], allRestrictions[2], allRestrictions[3], allRestrictions[4],
+			},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+			},
+		},
+		{
+			name: "someone can push",
+			restrictions: []cloudRestriction{
+				allRestrictions[0], allRestrictions[1], allRestrictions[3], allRestrictions[4], allRestrictions[5],
+				{Kind: "push", BranchMatchKind: "glob", Pattern: "main", Users: []json.RawMessage{json.RawMessage(`{"nickname":"admin"}`)}},
+			},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+			},
+		},
+		{
+			name: "restrictions for other branches",
+			restrictions: []cloudRestriction{
+				{Kind: "force", BranchMatchKind: "glob", Pattern: "release/*"},
+				{Kind: "delete", BranchMatchKind: "branching_model", Pattern: ""},
+			},
+			expectedControls: slsa_types.Controls{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestBitbucketConnection(t, Cloud, map[string]any{
+				cloudRepoPath + "commit/abc123/pullrequests": cloudMergedPR(),
+				cloudRepoPath + "pullrequests/7/activity":    cloudMergedPRActivity(),
+				cloudRepoPath + "branch-restrictions":        map[string]any{"values": tt.restrictions},
+			})
+
+			status, err := bc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("GetBranchControls() error = %v", err)
+			}
+			if status.ActivityType != "pr_merge" {
+				t.Errorf("ActivityType = %q, want %q", status.ActivityType, "pr_merge")
+			}
+			if !status.CommitPushTime.Equal(mergeTime) {
+				t.Errorf("CommitPushTime = %v, want %v", status.CommitPushTime, mergeTime)
+			}
+			if status.ActorLogin != "the-merger" {
+				t.Errorf("ActorLogin = %q, want %q", status.ActorLogin, "the-merger")
+			}
+			assertControls(t, status.Controls, tt.expectedControls)
+		})
+	}
+}
+
+func TestGetBranchControls_DataCenter(t *testing.T) {
+	mergedPR := dcPullRequest{Id: 3, State: "MERGED", ClosedDate: mergeTime.Add(time.Minute).UnixMilli()}
+	mergedPR.ToRef.Id = "refs/heads/main"
+	mergedPR.Properties.MergeCommit = &commit{Id: "abc123"}
+
+	restriction := func(restrictionType, matcherType, id string) dcRestriction {
+		r := dcRestriction{Type: restrictionType, Matcher: dcMatcher{Id: id, DisplayId: id}}
+		r.Matcher.Type.Id = matcherType
+		return r
+	}
+
+	tests := []struct {
+		name             string
+		restrictions     []dcRestriction
+		settings         dcPullRequestSettings
+		expectedControls slsa_types.Controls
+	}{
+		{
+			name:             "no restrictions",
+			restrictions:     []dcRestriction{},
+			settings:         dcPullRequestSettings{RequiredApprovers: 1, UnapproveOnUpdate: true},
+			expectedControls: slsa_types.Controls{},
+		},
+		{
+			name: "all restrictions",
+			restrictions: []dcRestriction{
+				restriction("fast-forward-only", "BRANCH", "refs/heads/main"),
+				restriction("no-deletes", "PATTERN", "ma*"),
+				restriction("pull-request-only", "ANY_REF", ""),
+				restriction("read-only", "PATTERN", "refs/tags/**"),
+			},
+			settings: dcPullRequestSettings{RequiredApprovers: 1, UnapproveOnUpdate: true},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+				{Name: slsa_types.ReviewEnforced, Since: mergeTime},
+				{Name: slsa_types.ImmutableTags, Since: mergeTime},
+			},
+		},
+		{
+			name: "approvals not reset",
+			restrictions: []dcRestriction{
+				restriction("read-only", "BRANCH", "refs/heads/main"),
+				restriction("fast-forward-only", "PATTERN", "refs/tags/*"),
+				restriction("no-deletes", "PATTERN", "refs/tags/*"),
+			},
+			settings: dcPullRequestSettings{RequiredApprovers: 2},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+			},
+		},
+		{
+			name: "exempt users",
+			restrictions: []dcRestriction{
+				restriction("fast-forward-only", "BRANCH", "refs/heads/main"),
+				restriction("no-deletes", "BRANCH", "refs/heads/main"),
+				func() dcRestriction {
+					r := restriction("pull-request-only", "BRANCH", "refs/heads/main")
+					r.Groups = []json.RawMessage{json.RawMessage(`"admins"`)}
+					return r
+				}(),
+			},
+			settings: dcPullRequestSettings{RequiredApprovers: 1, UnapproveOnUpdate: true},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: mergeTime},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestBitbucketConnection(t, DataCenter, map[string]any{
+				dcRepoPath + "commits/abc123/pull-requests": dcPage([]dcPullRequest{mergedPR}),
+				dcRepoPath + "pull-requests/3/activities": dcPage([]dcActivity{
+					{Action: "COMMENTED", User: dcUser{Slug: "someone"}, CreatedDate: mergeTime.Add(-time.Hour).UnixMilli()},
+					{Action: "MERGED", User: dcUser{Slug: "the-merger"}, CreatedDate: mergeTime.UnixMilli()},
+				}),
+				dcRestrictionsPath:                    dcPage(tt.restrictions),
+				dcRepoPath + "settings/pull-requests": tt.settings,
+			})
+
+			status, err := bc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("GetBranchControls() error = %v", err)
+			}
+			if !status.CommitPushTime.Equal(mergeTime) {
+				t.Errorf("CommitPushTime = %v, want %v", status.CommitPushTime, mergeTime)
+			}
+			if status.ActorLogin != "the-merger" {
+				t.Errorf("ActorLogin = %q, want %q", status.ActorLogin, "the-merger")
+			}
+			assertControls(t, status.Controls, tt.expectedControls)
+		})
+	}
+}
+
+func TestGetBranchControls_DirectPush(t *testing.T) {
+	bc := newTestBitbucketConnection(t, Cloud, map[string]any{
+		cloudRepoPath + "commit/abc123/pullrequests": map[string]any{"values": []cloudPullRequest{}},
+		cloudRepoPath + "branch-restrictions":        map[string]any{"values": []cloudRestriction{}},
+	})
+
+	status, err := bc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("GetBranchControls() error = %v", err)
+	}
+	if status.ActivityType != "push" {
+		t.Errorf("ActivityType = %q, want %q", status.ActivityType, "push")
+	}
+}
+
+func TestGetBranchControls_CloudMergeTimeUnknown(t *testing.T) {
+	bc := newTestBitbucketConnection(t, Cloud, map[string]any{
+		cloudRepoPath + "commit/abc123/pullrequests": cloudMergedPR(),
+		cloudRepoPath + "pullrequests/7/activity": map[string]any{"values": []map[string]any{
+			{"comment": map[string]any{"created_on": mergeTime}},
+		}},
+		cloudRepoPath + "branch-restrictions": map[string]any{"values": []cloudRestriction{}},
+	})
+
+	status, err := bc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+	if err == nil {
+		t.Errorf("GetBranchControls() = %v, want error", status)
+	}
+}
+
+func assertControls(t *testing.T, controls, expected slsa_types.Controls) {
+	t.Helper()
+	if len(controls) != len(expected) {
+		t.Fatalf("Controls = %v, want %v", controls, expected)
+	}
+	for i, e := range expected {
+		if controls[i].Name != e.Name || !controls[i].Since.Equal(e.Since) {
+			t.Errorf("Controls[%d] = %v, want %v", i, controls[i], e)
+		}
+	}
+}
+
+func TestPriorCommit(t *testing.T) {
+	cloud := newTestBitbucketConnection(t, Cloud, map[string]any{
+		cloudRepoPath + "commit/abc123": commit{Hash: "abc123", Parents: []*commit{{Hash: "prev123"}}},
+	})
+	dc := newTestBitbucketConnection(t, DataCenter, map[string]any{
+		dcRepoPath + "commits/abc123": commit{Id: "abc123", Parents: []*commit{{Id: "prev456"}}},
+	})
+
+	prev, err := source_control.PriorCommit(context.Background(), cloud, "abc123")
+	if err != nil || prev != "prev123" {
+		t.Errorf("PriorCommit() = %q, %v, want %q", prev, err, "prev123")
+	}
+	prev, err = source_control.PriorCommit(context.Background(), dc, "abc123")
+	if err != nil || prev != "prev456" {
+		t.Errorf("PriorCommit() = %q, %v, want %q", prev, err, "prev456")
+	}
+}
+
+func TestGetLatestCommit_DataCenterPaged(t *testing.T) {
+	bc := newTestBitbucketConnection(t, DataCenter, map[string]any{
+		dcRepoPath + "branches?filterText=main": map[string]any{
+			"values":        []dcBranch{{Id: "refs/heads/main-old", LatestCommit: "old"}},
+			"isLastPage":    false,
+			"nextPageStart": 1,
+		},
+		dcRepoPath + "branches?filterText=main&start=1": dcPage([]dcBranch{{Id: "refs/heads/main", LatestCommit: "abc123"}}),
+	})
+
+	latest, err := bc.GetLatestCommit(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("GetLatestCommit() error = %v", err)
+	}
+	if latest != "abc123" {
+		t.Errorf("GetLatestCommit() = %q, want %q", latest, "abc123")
+	}
+}
+
+func TestGetNotesForCommit(t *testing.T) {
+	bc := newTestBitbucketConnection(t, DataCenter, map[string]any{
+		dcRepoPath + "raw/abc123?at=refs%2Fnotes%2Fcommits": "line1\nline2\n",
+	})
+
+	notes, err := bc.GetNotesForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v", err)
+	}
+	if notes != "line1\nline2\n" {
+		t.Errorf("GetNotesForCommit() = %q, want %q", notes, "line1\nline2\n")
+	}
+
+	notes, err = bc.GetNotesForCommit(context.Background(), "def456")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v for missing notes", err)
+	}
+	if notes != "" {
+		t.Errorf("GetNotesForCommit() = %q, want empty notes", notes)
+	}
+}
+
+func TestGetRepoUri(t *testing.T) {
+	cloud, _ := NewBitbucketConnection(Cloud, "", "workspace", "repo", "refs/heads/main", nil)
+	if uri := cloud.GetRepoUri(); uri != "https://bitbucket.org/workspace/repo" {
+		t.Errorf("GetRepoUri() = %q for Cloud", uri)
+	}
+	dc, _ := NewBitbucketConnection(DataCenter, "https://bitbucket.example.com/", "PROJ", "repo", "refs/heads/main", nil)
+	if uri := dc.GetRepoUri(); uri != "https://bitbucket.example.com/projects/PROJ/repos/repo" {
+		t.Errorf("GetRepoUri() = %q for Data Center", uri)
+	}
+	if _, err := NewBitbucketConnection(DataCenter, "", "PROJ", "repo", "refs/heads/main", nil); err == nil {
+		t.Errorf("NewBitbucketConnection() error = nil, want error for Data Center without a url")
+	}
+}
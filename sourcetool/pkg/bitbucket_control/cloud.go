@@ -0,0 +1,140 @@
+package bitbucket_control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+type cloudUser struct {
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+}
+
+type cloudPullRequest struct {
+	Id          int    `json:"id"`
+	State       string `json:"state"`
+	Destination struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+	} `json:"destination"`
+	MergeCommit *commit    `json:"merge_commit"`
+	ClosedBy    *cloudUser `json:"closed_by"`
+}
+
+// An entry in a pull request's activity log, we only care about updates.
+type cloudPullRequestActivity struct {
+	Update *struct {
+		State string    `json:"state"`
+		Date  time.Time `json:"date"`
+	} `json:"update"`
+}
+
+// Gets when pull request 'id' was merged from its activity log.
+// The pull request itself only says when it was last updated, and comments
+// or tasks can update it after it's merged.
+func (bc *BitbucketConnection) cloudMergeTime(ctx context.Context, id int) (time.Time, error) {
+	activities, err := getPaged[cloudPullRequestActivity](ctx, bc, bc.repoPath(fmt.Sprintf("pullrequests/%d/activity", id)), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, activity := range activities {
+		if activity.Update != nil && activity.Update.State == "MERGED" {
+			return activity.Update.Date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not find when pull request %d was merged", id)
+}
+
+// Finds the pull request that was merged as 'commit' into 'ref'.
+func (bc *BitbucketConnection) cloudMergedPullRequest(ctx context.Context, commit, ref string) (*mergedPullRequest, error) {
+	prs, err := getPaged[cloudPullRequest](ctx, bc, bc.repoPath(fmt.Sprintf("commit/%s/pullrequests", url.PathEscape(commit))), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.State != "MERGED" || pr.MergeCommit == nil || pr.MergeCommit.sha() != commit {
+			continue
+		}
+		if source_control.BranchToFullRef(pr.Destination.Branch.Name) != ref {
+			continue
+		}
+		mergedBy := ""
+		if pr.ClosedBy != nil {
+			mergedBy = pr.ClosedBy.Nickname
+		}
+		mergedAt, err := bc.cloudMergeTime(ctx, pr.Id)
+		if err != nil {
+			return nil, err
+		}
+		return &mergedPullRequest{id: pr.Id, mergedBy: mergedBy, mergedAt: mergedAt}, nil
+	}
+	return nil, nil
+}
+
+type cloudRestriction struct {
+	Kind            string            `json:"kind"`
+	BranchMatchKind string            `json:"branch_match_kind"`
+	Pattern         string            `json:"pattern"`
+	Value           *int              `json:"value"`
+	Users           []json.RawMessage `json:"users"`
+	Groups          []json.RawMessage `json:"groups"`
+}
+
+// Reports if the restriction applies to 'branch'.
+func (r *cloudRestriction) appliesTo(branch string) bool {
+	if r.BranchMatchKind != "glob" {
+		// Branching model restrictions would need the branching model to evaluate.
+		log.Printf("ignoring %s restriction matching by %s", r.Kind, r.BranchMatchKind)
+		return false
+	}
+	return source_control.MatchesGlob(source_control.DoubleStarGlob, r.Pattern, branch)
+}
+
+// Gets the branch restrictions Bitbucket Cloud applies to 'ref'.
+func (bc *BitbucketConnection) cloudRestrictions(ctx context.Context, ref string) (*refRestrictions, error) {
+	branch := source_control.GetBranchFromRef(ref)
+	if branch == "" {
+		// Branch restrictions only apply to branches.
+		return &refRestrictions{}, nil
+	}
+
+	restrictions, err := getPaged[cloudRestriction](ctx, bc, bc.repoPath("branch-restrictions"), url.Values{"pagelen": {"100"}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := refRestrictions{}
+	for _, r := range restrictions {
+		if !r.appliesTo(branch) {
+			continue
+		}
+		switch r.Kind {
+		case "force":
+			result.noRewrites = true
+		case "delete":
+			result.noDeletes = true
+		case "push":
+			// Only the listed users and groups may push, so nobody can if they're empty.
+			if len(r.Users) == 0 && len(r.Groups) == 0 {
+				result.noDirectPushes = true
+			}
+		case "require_approvals_to_merge":
+			if r.Value != nil && *r.Value > result.requiredApprovals {
+				result.requiredApprovals = *r.Value
+			}
+		case "reset_pullrequest_approvals_on_change":
+			result.resetApprovalsOnChange = true
+		case "enforce_merge_checks":
+			// Without this the other merge checks are just warnings.
+			result.mergeChecksEnforced = true
+		}
+	}
+	return &result, nil
+}
@@ -0,0 +1,263 @@
+package bitbucket_control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// Which Bitbucket product is being used, their APIs are quite different.
+type Flavor string
+
+const (
+	Cloud      Flavor = "cloud"
+	DataCenter Flavor = "datacenter"
+)
+
+const DefaultCloudApiUrl = "https://api.bitbucket.org/2.0"
+
+// Where Bitbucket Cloud repos are browsed, used to identify them.
+const cloudWebUrl = "https://bitbucket.org"
+
+// Limits how many pages of results we'll fetch from a paged API.
+const maxPages = 20
+
+var _ source_control.SourceControlPlatform = (*BitbucketConnection)(nil)
+
+// Manages a connection to a Bitbucket repo.
+type BitbucketConnection struct {
+	client           *http.Client
+	flavor           Flavor
+	baseUrl          *url.URL
+	token            string
+	owner, repo, ref string
+	policySource     source_control.PolicySource
+}
+
+// Creates a connection to owner/repo on Bitbucket. Policies are fetched from policySource.
+//
+// For Cloud 'owner' is the workspace and baseUrl is the API url (DefaultCloudApiUrl if empty).
+// For Data Center 'owner' is the project key and baseUrl is the url of the instance.
+func NewBitbucketConnection(flavor Flavor, baseUrl, owner, repo, ref string, policySource source_control.PolicySource) (*BitbucketConnection, error) {
+	return NewBitbucketConnectionWithClient(flavor, baseUrl, owner, repo, ref, policySource, http.DefaultClient)
+}
+
+func NewBitbucketConnectionWithClient(flavor Flavor, baseUrl, owner, repo, ref string, policySource source_control.PolicySource, client *http.Client) (*BitbucketConnection, error) {
+	switch flavor {
+	case Cloud:
+		if baseUrl == "" {
+			baseUrl = DefaultCloudApiUrl
+		}
+	case DataCenter:
+		if baseUrl == "" {
+			return nil, errors.New("the url of the Bitbucket Data Center instance must be set")
+		}
+	default:
+		return nil, fmt.Errorf("unknown Bitbucket flavor %s", flavor)
+	}
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Bitbucket url %s: %w", baseUrl, err)
+	}
+	return &BitbucketConnection{
+		client:       client,
+		flavor:       flavor,
+		baseUrl:      parsedUrl,
+		owner:        owner,
+		repo:         repo,
+		ref:          ref,
+		policySource: policySource}, nil
+}
+
+// Uses the provided access token (or Data Center HTTP access token) for auth.
+// If the token is the empty string this is a no-op.
+func (bc *BitbucketConnection) WithAuthToken(token string) *BitbucketConnection {
+	if token != "" {
+		bc.token = token
+	}
+	return bc
+}
+
+func (bc *BitbucketConnection) Owner() string {
+	return bc.owner
+}
+
+func (bc *BitbucketConnection) Repo() string {
+	return bc.repo
+}
+
+func (bc *BitbucketConnection) GetFullRef() string {
+	return bc.ref
+}
+
+// Returns the URI of the repo this connection tracks.
+func (bc *BitbucketConnection) GetRepoUri() string {
+	if bc.flavor == DataCenter {
+		return fmt.Sprintf("%s/projects/%s/repos/%s", bc.baseUrl.String(), bc.owner, bc.repo)
+	}
+	return fmt.Sprintf("%s/%s/%s", cloudWebUrl, bc.owner, bc.repo)
+}
+
+func (bc *BitbucketConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	if bc.policySource == nil {
+		return "", "", fmt.Errorf("no policy source configured for %s", bc.GetRepoUri())
+	}
+	return bc.policySource.GetPolicyFile(ctx, path)
+}
+
+// Returns the API path for 'path' within this repo.
+func (bc *BitbucketConnection) repoPath(path string) string {
+	if bc.flavor == DataCenter {
+		return fmt.Sprintf("rest/api/1.0/projects/%s/repos/%s/%s", url.PathEscape(bc.owner), url.PathEscape(bc.repo), path)
+	}
+	return fmt.Sprintf("repositories/%s/%s/%s", url.PathEscape(bc.owner), url.PathEscape(bc.repo), path)
+}
+
+// Returns the full url for the API 'path'.
+func (bc *BitbucketConnection) apiUrl(path string, query url.Values) string {
+	reqUrl := fmt.Sprintf("%s/%s", bc.baseUrl.String(), path)
+	if len(query) > 0 {
+		reqUrl = fmt.Sprintf("%s?%s", reqUrl, query.Encode())
+	}
+	return reqUrl
+}
+
+// Issues a GET request for 'reqUrl', returning the raw body.
+// Returns source_control.ErrNotFound if Bitbucket returns a 404.
+func (bc *BitbucketConnection) getUrl(ctx context.Context, reqUrl string) ([]byte, error) {
+	header := http.Header{}
+	if bc.token != "" {
+		header.Set("Authorization", "Bearer "+bc.token)
+	}
+	body, _, err := source_control.HttpGet(ctx, bc.client, reqUrl, header)
+	return body, err
+}
+
+// Issues a GET request for the API 'path', returning the raw body.
+func (bc *BitbucketConnection) getRaw(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	return bc.getUrl(ctx, bc.apiUrl(path, query))
+}
+
+// Issues a GET request for the API 'path' and decodes the JSON result into 'result'.
+func (bc *BitbucketConnection) get(ctx context.Context, path string, query url.Values, result any) error {
+	body, err := bc.getRaw(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// A page of results, Cloud and Data Center paginate differently.
+type page[T any] struct {
+	Values []T `json:"values"`
+	// Cloud
+	Next string `json:"next"`
+	// Data Center
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// Gets all the values from the paged API 'path'.
+func getPaged[T any](ctx context.Context, bc *BitbucketConnection, path string, query url.Values) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	reqUrl := bc.apiUrl(path, query)
+	var values []T
+	for i := 0; i < maxPages; i++ {
+		body, err := bc.getUrl(ctx, reqUrl)
+		if err != nil {
+			return nil, err
+		}
+		var p page[T]
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		values = append(values, p.Values...)
+
+		if bc.flavor == DataCenter {
+			if p.IsLastPage {
+				return values, nil
+			}
+			query.Set("start", strconv.Itoa(p.NextPageStart))
+			reqUrl = bc.apiUrl(path, query)
+		} else {
+			if p.Next == "" {
+				return values, nil
+			}
+			reqUrl = p.Next
+		}
+	}
+	return nil, fmt.Errorf("too many pages of results from %s", path)
+}
+
+// A commit as returned by either Cloud (hash) or Data Center (id).
+type commit struct {
+	Hash    string    `json:"hash"`
+	Id      string    `json:"id"`
+	Parents []*commit `json:"parents"`
+}
+
+func (c *commit) sha() string {
+	if c.Hash != "" {
+		return c.Hash
+	}
+	return c.Id
+}
+
+// Gets the parents of 'sha', in order.
+func (bc *BitbucketConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
+	commitPath := "commit/%s"
+	if bc.flavor == DataCenter {
+		commitPath = "commits/%s"
+	}
+	var c commit
+	err := bc.get(ctx, bc.repoPath(fmt.Sprintf(commitPath, url.PathEscape(sha))), nil, &c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
+	}
+
+	var parents []string
+	for _, parent := range c.Parents {
+		parents = append(parents, parent.sha())
+	}
+	return parents, nil
+}
+
+type cloudBranch struct {
+	Target commit `json:"target"`
+}
+
+type dcBranch struct {
+	Id           string `json:"id"`
+	LatestCommit string `json:"latestCommit"`
+}
+
+func (bc *BitbucketConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
+	if bc.flavor == DataCenter {
+		branches, err := getPaged[dcBranch](ctx, bc, bc.repoPath("branches"), url.Values{"filterText": {targetBranch}})
+		if err != nil {
+			return "", fmt.Errorf("could not get info on specified branch %s: %w", targetBranch, err)
+		}
+		for _, branch := range branches {
+			if branch.Id == source_control.BranchToFullRef(targetBranch) {
+				return branch.LatestCommit, nil
+			}
+		}
+		return "", fmt.Errorf("could not find branch %s", targetBranch)
+	}
+
+	var branch cloudBranch
+	err := bc.get(ctx, bc.repoPath(fmt.Sprintf("refs/branches/%s", url.PathEscape(targetBranch))), nil, &branch)
+	if err != nil {
+		return "", fmt.Errorf("could not get info on specified branch %s: %w", targetBranch, err)
+	}
+	return branch.Target.sha(), nil
+}
@@ -0,0 +1,166 @@
+package bitbucket_control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+type dcUser struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type dcPullRequest struct {
+	Id    int    `json:"id"`
+	State string `json:"state"`
+	ToRef struct {
+		Id string `json:"id"`
+	} `json:"toRef"`
+	Properties struct {
+		MergeCommit *commit `json:"mergeCommit"`
+	} `json:"properties"`
+	ClosedDate int64 `json:"closedDate"`
+}
+
+type dcActivity struct {
+	Action      string `json:"action"`
+	User        dcUser `json:"user"`
+	CreatedDate int64  `json:"createdDate"`
+}
+
+// Finds the pull request that was merged as 'commit' into 'ref'.
+func (bc *BitbucketConnection) dcMergedPullRequest(ctx context.Context, commit, ref string) (*mergedPullRequest, error) {
+	prs, err := getPaged[dcPullRequest](ctx, bc, bc.repoPath(fmt.Sprintf("commits/%s/pull-requests", url.PathEscape(commit))), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.State != "MERGED" || pr.ToRef.Id != ref {
+			continue
+		}
+		// Older versions don't report the merge commit, in which case we have to trust the
+		// PR contains it.
+		if pr.Properties.MergeCommit != nil && pr.Properties.MergeCommit.sha() != commit {
+			continue
+		}
+
+		merged := mergedPullRequest{id: pr.Id, mergedAt: time.UnixMilli(pr.ClosedDate).UTC()}
+		activities, err := getPaged[dcActivity](ctx, bc, bc.repoPath(fmt.Sprintf("pull-requests/%d/activities", pr.Id)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not get activities for pull request %d: %w", pr.Id, err)
+		}
+		for _, activity := range activities {
+			if activity.Action == "MERGED" {
+				merged.mergedBy = activity.User.Slug
+				merged.mergedAt = time.UnixMilli(activity.CreatedDate).UTC()
+				break
+			}
+		}
+		return &merged, nil
+	}
+	return nil, nil
+}
+
+type dcMatcher struct {
+	Id        string `json:"id"`
+	DisplayId string `json:"displayId"`
+	Type      struct {
+		Id string `json:"id"`
+	} `json:"type"`
+}
+
+type dcRestriction struct {
+	Type       string            `json:"type"`
+	Matcher    dcMatcher         `json:"matcher"`
+	Users      []json.RawMessage `json:"users"`
+	Groups     []json.RawMessage `json:"groups"`
+	AccessKeys []json.RawMessage `json:"accessKeys"`
+}
+
+// Reports if anyone is exempt from the restriction.
+func (r *dcRestriction) hasExemptions() bool {
+	return len(r.Users) > 0 || len(r.Groups) > 0 || len(r.AccessKeys) > 0
+}
+
+// Reports if the restriction's matcher applies to 'ref'.
+func (m *dcMatcher) matches(ref string) bool {
+	switch m.Type.Id {
+	case "ANY_REF":
+		return true
+	case "BRANCH":
+		return m.Id == ref
+	case "PATTERN":
+		// Patterns that don't start with refs/ are matched against the branch or tag name.
+		if strings.HasPrefix(m.Id, "refs/") {
+			return source_control.MatchesGlob(source_control.DoubleStarGlob, m.Id, ref)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/")
+		return source_control.MatchesGlob(source_control.DoubleStarGlob, m.Id, name)
+	}
+	// Branching model matchers would need the branching model to evaluate.
+	log.Printf("ignoring restriction with %s matcher %s", m.Type.Id, m.DisplayId)
+	return false
+}
+
+type dcPullRequestSettings struct {
+	RequiredApprovers int  `json:"requiredApprovers"`
+	UnapproveOnUpdate bool `json:"unapproveOnUpdate"`
+}
+
+// Gets the restrictions Bitbucket Data Center applies to refs accepted by 'matches'.
+func (bc *BitbucketConnection) dcRestrictions(ctx context.Context, matches func(*dcMatcher) bool) (*refRestrictions, error) {
+	restrictionsPath := fmt.Sprintf("rest/branch-permissions/2.0/projects/%s/repos/%s/restrictions", url.PathEscape(bc.owner), url.PathEscape(bc.repo))
+	restrictions, err := getPaged[dcRestriction](ctx, bc, restrictionsPath, url.Values{"limit": {"100"}})
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge checks can't be overridden in Data Center.
+	result := refRestrictions{mergeChecksEnforced: true}
+	for _, r := range restrictions {
+		if !matches(&r.Matcher) || r.hasExemptions() {
+			continue
+		}
+		switch r.Type {
+		case "read-only":
+			result.noRewrites = true
+			result.noDeletes = true
+			result.noDirectPushes = true
+		case "fast-forward-only":
+			result.noRewrites = true
+		case "no-deletes":
+			result.noDeletes = true
+		case "pull-request-only":
+			result.noDirectPushes = true
+		}
+	}
+
+	var settings dcPullRequestSettings
+	err = bc.get(ctx, bc.repoPath("settings/pull-requests"), nil, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("could not get pull request settings: %w", err)
+	}
+	result.requiredApprovals = settings.RequiredApprovers
+	result.resetApprovalsOnChange = settings.UnapproveOnUpdate
+
+	return &result, nil
+}
+
+// Reports if the matcher covers every tag.
+func matchesAllTags(m *dcMatcher) bool {
+	switch m.Type.Id {
+	case "ANY_REF":
+		return true
+	case "PATTERN":
+		// '*' doesn't match '/' so it would miss some tags.
+		return m.Id == "refs/tags/**"
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package bitbucket_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+func (bc *BitbucketConnection) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	// Notes for a given commit are stored at the path <commit> within ref `refs/notes/commits`.
+	var path string
+	var query url.Values
+	if bc.flavor == DataCenter {
+		path = bc.repoPath(fmt.Sprintf("raw/%s", url.PathEscape(commit)))
+		query = url.Values{"at": {"refs/notes/commits"}}
+	} else {
+		path = bc.repoPath(fmt.Sprintf("src/%s/%s", url.PathEscape("refs/notes/commits"), url.PathEscape(commit)))
+	}
+
+	contents, err := bc.getRaw(ctx, path, query)
+	if errors.Is(err, source_control.ErrNotFound) {
+		// Don't freak out if it's not there.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot get note contents for commit %s: %w", commit, err)
+	}
+	return string(contents), nil
+}
@@ -0,0 +1,169 @@
+package gerrit_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Gerrit permissions we care about.
+const (
+	pushPermission                   = "push"
+	deletePermission                 = "delete"
+	forgeCommitterPermission         = "forgeCommitter"
+	forgeServerAsCommitterPermission = "forgeServerAsCommitter"
+)
+
+// Limits how far we'll follow a project's parents.
+const maxInheritanceDepth = 20
+
+type permissionRuleInfo struct {
+	Action string `json:"action"`
+	Force  bool   `json:"force"`
+}
+
+type permissionInfo struct {
+	Rules map[string]*permissionRuleInfo `json:"rules"`
+}
+
+type accessSectionInfo struct {
+	Permissions map[string]*permissionInfo `json:"permissions"`
+}
+
+type projectRef struct {
+	Id string `json:"id"`
+}
+
+type projectAccessInfo struct {
+	Revision     string                        `json:"revision"`
+	InheritsFrom *projectRef                   `json:"inherits_from"`
+	Local        map[string]*accessSectionInfo `json:"local"`
+}
+
+// The access rules that apply to a project, including those it inherits.
+type accessRules struct {
+	// Access sections keyed by ref pattern, starting with the project itself
+	// and followed by each of its parents.
+	sections []map[string]*accessSectionInfo
+	// The most recent time any of the access configs were changed.
+	updated time.Time
+}
+
+// Gets the access rules for the project and all the projects it inherits from.
+func (gc *GerritConnection) getAccessRules(ctx context.Context) (*accessRules, error) {
+	rules := accessRules{}
+	project := gc.Project()
+	for depth := 0; project != ""; depth++ {
+		if depth >= maxInheritanceDepth {
+			return nil, fmt.Errorf("project %s inherits from too many projects", gc.Project())
+		}
+		var access projectAccessInfo
+		err := gc.get(ctx, projectPath(project, "access"), nil, &access)
+		if err != nil {
+			return nil, fmt.Errorf("could not get access rules for %s: %w", project, err)
+		}
+		rules.sections = append(rules.sections, access.Local)
+
+		// The access config is stored in refs/meta/config, so its history tells us
+		// when the rules were last changed.
+		if access.Revision != "" {
+			configCommit, err := gc.getCommit(ctx, project, access.Revision)
+			if err != nil {
+				return nil, fmt.Errorf("could not get access config commit for %s: %w", project, err)
+			}
+			if configCommit.Committer.Date.After(rules.updated) {
+				rules.updated = configCommit.Committer.Date.Time
+			}
+		}
+
+		project = ""
+		if access.InheritsFrom != nil {
+			project = access.InheritsFrom.Id
+		}
+	}
+	return &rules, nil
+}
+
+// Reports if the Gerrit ref pattern applies to 'ref'.
+// Patterns are either regular expressions starting with '^', prefixes ending in '/*', or exact refs.
+func refPatternMatches(pattern, ref string) bool {
+	if strings.HasPrefix(pattern, "^") {
+		// Parameterized patterns like ${username} can't be evaluated here.
+		pattern = strings.ReplaceAll(pattern, "${username}", "[^/]+")
+		pattern = strings.ReplaceAll(pattern, "${shardeduserid}", "[^/]+")
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("ignoring invalid ref pattern %s: %v", pattern, err)
+			return false
+		}
+		return re.MatchString(ref)
+	}
+	if idx := strings.Index(pattern, "${"); idx >= 0 {
+		return strings.HasPrefix(ref, pattern[:idx])
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(ref, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == ref
+}
+
+// Returns a matcher for patterns that apply to 'ref'.
+func matchesRef(ref string) func(string) bool {
+	return func(pattern string) bool {
+		return refPatternMatches(pattern, ref)
+	}
+}
+
+// Returns a matcher for patterns that apply to any ref under 'namespace' (e.g. refs/tags/).
+func matchesNamespace(namespace string) func(string) bool {
+	return func(pattern string) bool {
+		return refPatternMatches(pattern, namespace+"x") ||
+			strings.HasPrefix(strings.TrimPrefix(pattern, "^"), namespace)
+	}
+}
+
+// Reports if anyone is allowed 'permission' on refs accepted by 'matches'.
+// If 'force' is set the rule must also allow forcing (e.g. force pushes).
+//
+// BLOCK rules can't be overridden except by an ALLOW rule in the same section,
+// DENY rules are ignored since they only apply in limited circumstances,
+// which means this errs on the side of reporting permissions as allowed.
+func (ar *accessRules) allows(permission string, force bool, matches func(string) bool) bool {
+	allowed := false
+	blocked := false
+	for _, sections := range ar.sections {
+		for pattern, section := range sections {
+			if !matches(pattern) {
+				continue
+			}
+			perm := section.Permissions[permission]
+			if perm == nil {
+				continue
+			}
+			sectionAllows := false
+			sectionBlocks := false
+			for _, rule := range perm.Rules {
+				switch rule.Action {
+				case "ALLOW":
+					if !force || rule.Force {
+						sectionAllows = true
+					}
+				case "BLOCK":
+					// A non-force block stops everything, a force block only stops forcing.
+					if force || !rule.Force {
+						sectionBlocks = true
+					}
+				}
+			}
+			if sectionAllows {
+				allowed = true
+			} else if sectionBlocks {
+				blocked = true
+			}
+		}
+	}
+	return allowed && !blocked
+}
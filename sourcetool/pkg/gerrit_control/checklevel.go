@@ -0,0 +1,304 @@
+package gerrit_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.UncheckedCarryForward = (*GerritConnection)(nil)
+
+// The labels Gerrit uses for review and CI votes.
+const (
+	codeReviewLabel = "Code-Review"
+	verifiedLabel   = "Verified"
+)
+
+type accountInfo struct {
+	AccountId int    `json:"_account_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+}
+
+// The account's username if it has one, otherwise its email or id.
+func (ai *accountInfo) login() string {
+	if ai.Username != "" {
+		return ai.Username
+	}
+	if ai.Email != "" {
+		return ai.Email
+	}
+	return strconv.Itoa(ai.AccountId)
+}
+
+type approvalInfo struct {
+	accountInfo
+	Value int `json:"value"`
+}
+
+type labelInfo struct {
+	All    []*approvalInfo   `json:"all"`
+	Values map[string]string `json:"values"`
+}
+
+// Returns the highest vote that can be given on this label.
+func (li *labelInfo) maxValue() (int, bool) {
+	found := false
+	maxValue := 0
+	for value := range li.Values {
+		v, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		if !found || v > maxValue {
+			maxValue = v
+			found = true
+		}
+	}
+	return maxValue, found
+}
+
+type submitRecordLabel struct {
+	Label  string `json:"label"`
+	Status string `json:"status"`
+}
+
+type submitRecord struct {
+	Status string               `json:"status"`
+	Labels []*submitRecordLabel `json:"labels"`
+}
+
+type revisionInfo struct {
+	Uploader accountInfo `json:"uploader"`
+}
+
+type changeInfo struct {
+	Number          int                      `json:"_number"`
+	Status          string                   `json:"status"`
+	Owner           accountInfo              `json:"owner"`
+	Submitter       *accountInfo             `json:"submitter"`
+	Submitted       *timestamp               `json:"submitted"`
+	CurrentRevision string                   `json:"current_revision"`
+	Revisions       map[string]*revisionInfo `json:"revisions"`
+	Labels          map[string]*labelInfo    `json:"labels"`
+	SubmitRecords   []*submitRecord          `json:"submit_records"`
+}
+
+// Finds the merged change that introduced 'commit' to 'branch'.
+// Returns nil if there isn't one, e.g. because the commit was pushed directly.
+func (gc *GerritConnection) findSubmittedChange(ctx context.Context, commit, branch string) (*changeInfo, error) {
+	query := url.Values{
+		"q": {fmt.Sprintf("commit:%s project:%s branch:%s status:merged", commit, gc.Project(), branch)},
+		"o": {"DETAILED_LABELS", "DETAILED_ACCOUNTS", "CURRENT_REVISION", "SUBMIT_REQUIREMENTS"},
+	}
+	var changes []*changeInfo
+	err := gc.get(ctx, "changes/", query, &changes)
+	if err != nil {
+		return nil, fmt.Errorf("could not query changes for commit %s: %w", commit, err)
+	}
+	for _, change := range changes {
+		if change.Submitted != nil && change.Submitter != nil {
+			return change, nil
+		}
+	}
+	return nil, nil
+}
+
+// Reports if the submit rules were satisfied rather than bypassed when the change was submitted.
+func (change *changeInfo) submitRulesSatisfied() bool {
+	if len(change.SubmitRecords) == 0 {
+		return false
+	}
+	for _, record := range change.SubmitRecords {
+		if record.Status == "FORCED" {
+			return false
+		}
+		for _, label := range record.Labels {
+			switch label.Status {
+			case "REJECT", "NEED", "IMPOSSIBLE":
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reports if someone other than 'excluded' gave the maximum vote on 'label'.
+func (change *changeInfo) hasMaxVote(label string, excluded ...int) bool {
+	info, ok := change.Labels[label]
+	if !ok {
+		return false
+	}
+	maxValue, ok := info.maxValue()
+	if !ok || maxValue <= 0 {
+		return false
+	}
+	for _, approval := range info.All {
+		if approval.Value != maxValue {
+			continue
+		}
+		isExcluded := false
+		for _, id := range excluded {
+			if approval.AccountId == id {
+				isExcluded = true
+			}
+		}
+		if !isExcluded {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports if the change was actually reviewed before submission.
+// The submit rules must not have been bypassed, someone other than the change owner
+// and the uploader of the final patch set must have given the maximum Code-Review vote,
+// and if the project uses the Verified label it must have the maximum vote too.
+func (change *changeInfo) wasReviewed() bool {
+	if !change.submitRulesSatisfied() {
+		log.Printf("submit rules for change %d were not satisfied", change.Number)
+		return false
+	}
+
+	excluded := []int{change.Owner.AccountId}
+	if revision, ok := change.Revisions[change.CurrentRevision]; ok {
+		excluded = append(excluded, revision.Uploader.AccountId)
+	}
+	if !change.hasMaxVote(codeReviewLabel, excluded...) {
+		log.Printf("change %d has no independent max %s vote", change.Number, codeReviewLabel)
+		return false
+	}
+
+	if _, ok := change.Labels[verifiedLabel]; ok && !change.hasMaxVote(verifiedLabel) {
+		log.Printf("change %d has no max %s vote", change.Number, verifiedLabel)
+		return false
+	}
+	return true
+}
+
+// Computes the continuity control returning nil if it's not enabled.
+// Nobody may force push to or delete the branch.
+func computeContinuityControl(commit, ref string, rules *accessRules, pushTime time.Time) (*slsa_types.Control, error) {
+	if rules.allows(pushPermission, true, matchesRef(ref)) || rules.allows(deletePermission, false, matchesRef(ref)) {
+		log.Printf("access rules allow force pushes or deletion of %s, cannot be L2+", ref)
+		return nil, nil
+	}
+
+	// Check that the commit was created after the rules were put in place...
+	// to be sure folks aren't somehow sneaking something through...
+	if pushTime.Before(rules.updated) {
+		return nil, fmt.Errorf("commit %s created before (%v) the access rules were changed (%v), that shouldn't happen", commit, pushTime, rules.updated)
+	}
+
+	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: rules.updated}, nil
+}
+
+// Computes the review control returning nil if it's not enabled.
+// Unlike other platforms this is based on the votes the change actually got,
+// along with making sure nobody could have bypassed review by pushing directly.
+func computeReviewControl(ref string, rules *accessRules, change *changeInfo) *slsa_types.Control {
+	if change == nil {
+		return nil
+	}
+	if rules.allows(pushPermission, false, matchesRef(ref)) ||
+		rules.allows(forgeCommitterPermission, false, matchesRef(ref)) ||
+		rules.allows(forgeServerAsCommitterPermission, false, matchesRef(ref)) {
+		log.Printf("access rules allow bypassing review on %s", ref)
+		return nil
+	}
+	if !change.wasReviewed() {
+		return nil
+	}
+	// The review evidence only covers this change.
+	return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: change.Submitted.Time}
+}
+
+// Computes the immutable tags control returning nil if it's not enabled.
+// Nobody may force push to or delete any tag.
+func computeImmutableTagsControl(rules *accessRules, activityTime time.Time) *slsa_types.Control {
+	tags := matchesNamespace("refs/tags/")
+	if rules.allows(pushPermission, true, tags) || rules.allows(deletePermission, false, tags) {
+		return nil
+	}
+
+	// Check that the commit was created after these rules were in place.
+	if activityTime.Before(rules.updated) {
+		return nil
+	}
+
+	return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: rules.updated}
+}
+
+// Gerrit can't tell us when a branch was force pushed, but we only report controls when the
+// access rules let nobody around them, and their start time moves whenever the rules change.
+func (gc *GerritConnection) CarryForwardUnchecked() bool {
+	return true
+}
+
+// Determines the controls that are in place for a branch using Gerrit's APIs
+// This is necessarily only as good as Gerrit's controls and existing APIs.
+func (gc *GerritConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	branch := source_control.GetBranchFromRef(ref)
+	if branch == "" {
+		return nil, fmt.Errorf("ref %s is not a branch", ref)
+	}
+
+	change, err := gc.findSubmittedChange(ctx, commit, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var controlStatus source_control.ControlStatus
+	if change != nil {
+		controlStatus = source_control.ControlStatus{
+			CommitPushTime: change.Submitted.Time,
+			ActivityType:   "pr_merge",
+			ActorLogin:     change.Submitter.login(),
+			Controls:       slsa_types.Controls{}}
+	} else {
+		// Gerrit doesn't keep a record of direct pushes, so the best we can do is now.
+		log.Printf("no submitted change found for commit %s, assuming it was pushed directly", commit)
+		controlStatus = source_control.ControlStatus{
+			CommitPushTime: time.Now(),
+			ActivityType:   "push",
+			Controls:       slsa_types.Controls{}}
+	}
+
+	rules, err := gc.getAccessRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	continuityControl, err := computeContinuityControl(commit, ref, rules, controlStatus.CommitPushTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ContinuityControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(continuityControl)
+
+	controlStatus.Controls.AddControl(computeReviewControl(ref, rules, change))
+
+	controlStatus.Controls.AddControl(computeImmutableTagsControl(rules, controlStatus.CommitPushTime))
+
+	return &controlStatus, nil
+}
+
+func (gc *GerritConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		Controls:       slsa_types.Controls{}}
+
+	rules, err := gc.getAccessRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(computeImmutableTagsControl(rules, controlStatus.CommitPushTime))
+
+	return &controlStatus, nil
+}
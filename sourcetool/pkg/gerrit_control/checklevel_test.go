@@ -0,0 +1,305 @@
+package gerrit_control
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+var submitTime = time.Unix(1678886400, 0).UTC() // March 15, 2023 00:00:00 UTC
+var projectConfigTime = submitTime.Add(-24 * time.Hour)
+var parentConfigTime = submitTime.Add(-48 * time.Hour)
+
+const testProjectPath = "projects/owner%2Frepo/"
+const testParentPath = "projects/All-Projects/"
+
+var (
+	owner    = accountInfo{AccountId: 1, Username: "owner"}
+	reviewer = accountInfo{AccountId: 2, Username: "reviewer"}
+	bot      = accountInfo{AccountId: 3, Username: "ci-bot"}
+)
+
+// Starts a stand-in for the Gerrit REST API that serves 'responses' as JSON
+// (with Gerrit's XSSI prefix) keyed by the escaped path.
+func newTestGerritConnection(t *testing.T, responses map[string]any) *GerritConnection {
+	t.Helper()
+	server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{
+		PathPrefix: "/a/",
+		JsonPrefix: xssiPrefix + "\n",
+		Authenticated: func(r *http.Request) bool {
+			user, pass, ok := r.BasicAuth()
+			return ok && user == "test-user" && pass == "test-password"
+		},
+	}, responses)
+
+	gc, err := NewGerritConnectionWithClient(server.URL, "owner/repo", "refs/heads/main", nil, server.Client())
+	if err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+	return gc.WithAuth("test-user", "test-password")
+}
+
+func allow(force bool) *permissionInfo {
+	return &permissionInfo{Rules: map[string]*permissionRuleInfo{
+		"group:Registered-Users": {Action: "ALLOW", Force: force},
+	}}
+}
+
+func block(force bool) *permissionInfo {
+	return &permissionInfo{Rules: map[string]*permissionRuleInfo{
+		"global:Anonymous-Users": {Action: "BLOCK", Force: force},
+	}}
+}
+
+func reviewedChange() *changeInfo {
+	return &changeInfo{
+		Number:          42,
+		Status:          "MERGED",
+		Owner:           owner,
+		Submitter:       &reviewer,
+		Submitted:       &timestamp{submitTime},
+		CurrentRevision: "abc123",
+		Revisions:       map[string]*revisionInfo{"abc123": {Uploader: owner}},
+		Labels: map[string]*labelInfo{
+			codeReviewLabel: {
+				All:    []*approvalInfo{{accountInfo: owner, Value: 1}, {accountInfo: reviewer, Value: 2}},
+				Values: map[string]string{"-2": "", "-1": "", " 0": "", "+1": "", "+2": ""},
+			},
+			verifiedLabel: {
+				All:    []*approvalInfo{{accountInfo: bot, Value: 1}},
+				Values: map[string]string{"-1": "", " 0": "", "+1": ""},
+			},
+		},
+		SubmitRecords: []*submitRecord{
+			{Status: "CLOSED", Labels: []*submitRecordLabel{
+				{Label: codeReviewLabel, Status: "OK"},
+				{Label: verifiedLabel, Status: "OK"},
+			}},
+		},
+	}
+}
+
+func configCommit(date time.Time) commitInfo {
+	return commitInfo{Commit: "cfg", Committer: gitPerson{Date: timestamp{date}}}
+}
+
+func TestGetBranchControls(t *testing.T) {
+	tests := []struct {
+		name             string
+		projectAccess    map[string]*accessSectionInfo
+		change           *changeInfo
+		expectedType     string
+		expectedControls slsa_types.Controls
+	}{
+		{
+			name: "everything enforced",
+			projectAccess: map[string]*accessSectionInfo{
+				"refs/for/refs/heads/*": {Permissions: map[string]*permissionInfo{pushPermission: allow(false)}},
+			},
+			change:       reviewedChange(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+				{Name: slsa_types.ReviewEnforced, Since: submitTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "deletion allowed by regex",
+			projectAccess: map[string]*accessSectionInfo{
+				"^refs/heads/ma.*": {Permissions: map[string]*permissionInfo{deletePermission: allow(false)}},
+			},
+			change:       reviewedChange(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ReviewEnforced, Since: submitTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "force push allowed but blocked by parent",
+			projectAccess: map[string]*accessSectionInfo{
+				"refs/heads/*": {Permissions: map[string]*permissionInfo{pushPermission: allow(true)}},
+			},
+			change:       reviewedChange(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "direct push",
+			projectAccess: map[string]*accessSectionInfo{
+				"refs/tags/*": {Permissions: map[string]*permissionInfo{deletePermission: allow(false)}},
+			},
+			expectedType: "push",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "self approved",
+			change: func() *changeInfo {
+				c := reviewedChange()
+				c.Labels[codeReviewLabel].All = []*approvalInfo{{accountInfo: owner, Value: 2}}
+				return c
+			}(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "not verified",
+			change: func() *changeInfo {
+				c := reviewedChange()
+				c.Labels[verifiedLabel].All = nil
+				return c
+			}(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+		{
+			name: "submit forced",
+			change: func() *changeInfo {
+				c := reviewedChange()
+				c.SubmitRecords[0].Status = "FORCED"
+				return c
+			}(),
+			expectedType: "pr_merge",
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: projectConfigTime},
+				{Name: slsa_types.ImmutableTags, Since: projectConfigTime},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := []*changeInfo{}
+			if tt.change != nil {
+				changes = append(changes, tt.change)
+			}
+			gc := newTestGerritConnection(t, map[string]any{
+				"changes/": changes,
+				testProjectPath + "access": projectAccessInfo{
+					Revision:     "project-cfg",
+					InheritsFrom: &projectRef{Id: "All-Projects"},
+					Local:        tt.projectAccess,
+				},
+				testProjectPath + "commits/project-cfg": configCommit(projectConfigTime),
+				testParentPath + "access": projectAccessInfo{
+					Revision: "parent-cfg",
+					Local: map[string]*accessSectionInfo{
+						"refs/*": {Permissions: map[string]*permissionInfo{pushPermission: block(true)}},
+					},
+				},
+				testParentPath + "commits/parent-cfg": configCommit(parentConfigTime),
+			})
+
+			status, err := gc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("GetBranchControls() error = %v", err)
+			}
+			if status.ActivityType != tt.expectedType {
+				t.Errorf("ActivityType = %q, want %q", status.ActivityType, tt.expectedType)
+			}
+			if tt.change != nil {
+				if !status.CommitPushTime.Equal(submitTime) {
+					t.Errorf("CommitPushTime = %v, want %v", status.CommitPushTime, submitTime)
+				}
+				if status.ActorLogin != "reviewer" {
+					t.Errorf("ActorLogin = %q, want %q", status.ActorLogin, "reviewer")
+				}
+			}
+			if len(status.Controls) != len(tt.expectedControls) {
+				t.Fatalf("Controls = %v, want %v", status.Controls, tt.expectedControls)
+			}
+			for i, expected := range tt.expectedControls {
+				if status.Controls[i].Name != expected.Name || !status.Controls[i].Since.Equal(expected.Since) {
+					t.Errorf("Controls[%d] = %v, want %v", i, status.Controls[i], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBranchControls_RulesChangedAfterSubmit(t *testing.T) {
+	gc := newTestGerritConnection(t, map[string]any{
+		"changes/":                              []*changeInfo{reviewedChange()},
+		testProjectPath + "access":              projectAccessInfo{Revision: "project-cfg"},
+		testProjectPath + "commits/project-cfg": configCommit(submitTime.Add(time.Hour)),
+	})
+
+	_, err := gc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+	if err == nil {
+		t.Errorf("GetBranchControls() error = nil, want error for access rules changed after the submit")
+	}
+}
+
+func TestGetNotesForCommit(t *testing.T) {
+	gc := newTestGerritConnection(t, map[string]any{
+		testProjectPath + "branches/refs%2Fnotes%2Fcommits/files/abc123/content": "bGluZTEKbGluZTIK",
+	})
+
+	notes, err := gc.GetNotesForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v", err)
+	}
+	if notes != "line1\nline2\n" {
+		t.Errorf("GetNotesForCommit() = %q, want %q", notes, "line1\nline2\n")
+	}
+
+	notes, err = gc.GetNotesForCommit(context.Background(), "def456")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v for missing notes", err)
+	}
+	if notes != "" {
+		t.Errorf("GetNotesForCommit() = %q, want empty notes", notes)
+	}
+}
+
+func TestPriorCommit(t *testing.T) {
+	gc := newTestGerritConnection(t, map[string]any{
+		testProjectPath + "commits/abc123": commitInfo{Commit: "abc123", Parents: []*commitInfo{{Commit: "prev123"}}},
+	})
+
+	prev, err := source_control.PriorCommit(context.Background(), gc, "abc123")
+	if err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if prev != "prev123" {
+		t.Errorf("PriorCommit() = %q, want %q", prev, "prev123")
+	}
+}
+
+func TestRefPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern, ref string
+		expected     bool
+	}{
+		{"refs/heads/main", "refs/heads/main", true},
+		{"refs/heads/*", "refs/heads/release/1.0", true},
+		{"refs/*", "refs/tags/v1", true},
+		{"refs/heads/*", "refs/tags/v1", false},
+		{"^refs/heads/rel-[0-9]+", "refs/heads/rel-12", true},
+		{"^refs/heads/rel-[0-9]+", "refs/heads/main", false},
+		{"refs/heads/sandbox/${username}/*", "refs/heads/sandbox/me/x", true},
+		{"refs/heads/main", "refs/heads/maintenance", false},
+	}
+	for _, tt := range tests {
+		if got := refPatternMatches(tt.pattern, tt.ref); got != tt.expected {
+			t.Errorf("refPatternMatches(%q, %q) = %v, want %v", tt.pattern, tt.ref, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package gerrit_control
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// Gerrit prefixes all JSON responses with this to prevent XSSI.
+const xssiPrefix = ")]}'"
+
+// The layout Gerrit uses for timestamps, they're always UTC.
+const timestampLayout = "2006-01-02 15:04:05.000000000"
+
+var _ source_control.SourceControlPlatform = (*GerritConnection)(nil)
+
+// A time as formatted by the Gerrit REST API.
+type timestamp struct {
+	time.Time
+}
+
+func (ts *timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := time.ParseInLocation(timestampLayout, s, time.UTC)
+	if err != nil {
+		return err
+	}
+	ts.Time = t
+	return nil
+}
+
+func (ts timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ts.UTC().Format(timestampLayout))
+}
+
+// Manages a connection to a Gerrit project.
+type GerritConnection struct {
+	client             *http.Client
+	baseUrl            *url.URL
+	username, password string
+	project, ref       string
+	policySource       source_control.PolicySource
+}
+
+// Creates a connection to 'project' hosted on the Gerrit instance at baseUrl.
+// Policies are fetched from policySource.
+func NewGerritConnection(baseUrl, project, ref string, policySource source_control.PolicySource) (*GerritConnection, error) {
+	return NewGerritConnectionWithClient(baseUrl, project, ref, policySource, http.DefaultClient)
+}
+
+func NewGerritConnectionWithClient(baseUrl, project, ref string, policySource source_control.PolicySource, client *http.Client) (*GerritConnection, error) {
+	if baseUrl == "" {
+		return nil, errors.New("the url of the Gerrit instance must be set")
+	}
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Gerrit url %s: %w", baseUrl, err)
+	}
+	return &GerritConnection{
+		client:       client,
+		baseUrl:      parsedUrl,
+		project:      project,
+		ref:          ref,
+		policySource: policySource}, nil
+}
+
+// Uses the provided username and HTTP password for auth.
+// If the username is the empty string this is a no-op.
+func (gc *GerritConnection) WithAuth(username, password string) *GerritConnection {
+	if username != "" {
+		gc.username = username
+		gc.password = password
+	}
+	return gc
+}
+
+func (gc *GerritConnection) Project() string {
+	return gc.project
+}
+
+func (gc *GerritConnection) GetFullRef() string {
+	return gc.ref
+}
+
+// Returns the URI of the project this connection tracks.
+func (gc *GerritConnection) GetRepoUri() string {
+	return fmt.Sprintf("%s/%s", gc.baseUrl.String(), gc.project)
+}
+
+func (gc *GerritConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	if gc.policySource == nil {
+		return "", "", fmt.Errorf("no policy source configured for %s", gc.GetRepoUri())
+	}
+	return gc.policySource.GetPolicyFile(ctx, path)
+}
+
+// Issues a GET request for the API 'path', returning the raw body.
+// Returns source_control.ErrNotFound if Gerrit returns a 404.
+func (gc *GerritConnection) getRaw(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	// Authenticated requests have to go to the /a/ endpoints.
+	prefix := ""
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if gc.username != "" {
+		prefix = "/a"
+		credentials := base64.StdEncoding.EncodeToString([]byte(gc.username + ":" + gc.password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+	reqUrl := fmt.Sprintf("%s%s/%s", gc.baseUrl.String(), prefix, path)
+	if len(query) > 0 {
+		reqUrl = fmt.Sprintf("%s?%s", reqUrl, query.Encode())
+	}
+	body, _, err := source_control.HttpGet(ctx, gc.client, reqUrl, header)
+	return body, err
+}
+
+// Issues a GET request for the API 'path' and decodes the JSON result into 'result'.
+func (gc *GerritConnection) get(ctx context.Context, path string, query url.Values, result any) error {
+	body, err := gc.getRaw(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	body = bytes.TrimPrefix(body, []byte(xssiPrefix))
+	return json.Unmarshal(body, result)
+}
+
+// Returns the API path for 'path' within a project.
+func projectPath(project, path string) string {
+	return fmt.Sprintf("projects/%s/%s", url.PathEscape(project), path)
+}
+
+type gitPerson struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  timestamp `json:"date"`
+}
+
+type commitInfo struct {
+	Commit    string        `json:"commit"`
+	Parents   []*commitInfo `json:"parents"`
+	Committer gitPerson     `json:"committer"`
+}
+
+func (gc *GerritConnection) getCommit(ctx context.Context, project, sha string) (*commitInfo, error) {
+	var c commitInfo
+	err := gc.get(ctx, projectPath(project, fmt.Sprintf("commits/%s", url.PathEscape(sha))), nil, &c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Gets the parents of 'sha', in order.
+func (gc *GerritConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
+	c, err := gc.getCommit(ctx, gc.Project(), sha)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
+	}
+
+	var parents []string
+	for _, parent := range c.Parents {
+		parents = append(parents, parent.Commit)
+	}
+	return parents, nil
+}
+
+type branchInfo struct {
+	Ref      string `json:"ref"`
+	Revision string `json:"revision"`
+}
+
+func (gc *GerritConnection) getBranch(ctx context.Context, project, branch string) (*branchInfo, error) {
+	var b branchInfo
+	err := gc.get(ctx, projectPath(project, fmt.Sprintf("branches/%s", url.PathEscape(branch))), nil, &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (gc *GerritConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
+	branch, err := gc.getBranch(ctx, gc.Project(), targetBranch)
+	if err != nil {
+		return "", fmt.Errorf("could not get info on specified branch %s: %w", targetBranch, err)
+	}
+	return branch.Revision, nil
+}
@@ -0,0 +1,31 @@
+package gerrit_control
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+func (gc *GerritConnection) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	// Notes for a given commit are stored at the path <commit> within ref `refs/notes/commits`.
+	// Gerrit returns file contents base64 encoded.
+	encoded, err := gc.getRaw(ctx, projectPath(gc.Project(),
+		fmt.Sprintf("branches/%s/files/%s/content", url.PathEscape("refs/notes/commits"), url.PathEscape(commit))), nil)
+	if errors.Is(err, source_control.ErrNotFound) {
+		// Don't freak out if it's not there.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot get note contents for commit %s: %w", commit, err)
+	}
+
+	contents, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return "", fmt.Errorf("cannot decode note contents for commit %s: %w", commit, err)
+	}
+	return string(contents), nil
+}
@@ -0,0 +1,253 @@
+package gh_control
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// GitHub rejects app JWTs that are valid for more than 10 minutes.
+const appJwtLifetime = 9 * time.Minute
+
+// Installation tokens are refreshed when they're this close to expiring.
+const tokenRefreshMargin = 5 * time.Minute
+
+// Loads a GitHub App private key from a PEM file as downloaded from GitHub.
+func LoadAppPrivateKey(path string) (*rsa.PrivateKey, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read app private key: %w", err)
+	}
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse app private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("app private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// An http.RoundTripper that authenticates requests as the installation of a
+// GitHub App on owner/repo. Installation tokens only last an hour, so they're
+// refreshed as needed, which keeps long-running commands working.
+type AppTransport struct {
+	base http.RoundTripper
+	// Used for the requests authenticated as the app itself.
+	appBase     http.RoundTripper
+	apiUrl      string
+	appId       string
+	key         *rsa.PrivateKey
+	owner, repo string
+	now         func() time.Time
+
+	mu                      sync.Mutex
+	policyOwner, policyRepo string
+	installationId          int64
+	token                   string
+	expiresAt               time.Time
+}
+
+// Creates a transport that authenticates as app 'appId' (the app or client ID)
+// using the installation on owner/repo. apiUrl is the url of the GitHub API the
+// tokens are for and base is used for all requests (http.DefaultTransport if nil).
+func NewAppTransport(base http.RoundTripper, apiUrl, appId string, key *rsa.PrivateKey, owner, repo string) *AppTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// Requests authenticated as the app aren't cached: the installation has to be current
+	// and each JWT is only used for a few minutes anyway.
+	appBase := base
+	if ct, ok := base.(*CachingTransport); ok {
+		appBase = ct.base
+	}
+	return &AppTransport{
+		base:    base,
+		appBase: appBase,
+		apiUrl:  strings.TrimSuffix(apiUrl, "/"),
+		appId:   appId,
+		key:     key,
+		owner:   owner,
+		repo:    repo,
+		now:     time.Now}
+}
+
+// Also gives installation tokens access to policyOwner/policyRepo, where policies are read from.
+// Tokens can only be limited to repos the installation covers, so a policy repo with another
+// owner isn't added and has to be public.
+func (at *AppTransport) WithPolicyRepo(policyOwner, policyRepo string) *AppTransport {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.policyOwner = policyOwner
+	at.policyRepo = policyRepo
+	// Tokens for the old set of repos shouldn't be used anymore.
+	at.token = ""
+	return at
+}
+
+// Returns the repos installation tokens are limited to. Must be called with mu held.
+func (at *AppTransport) tokenRepos() []string {
+	repos := []string{at.repo}
+	if strings.EqualFold(at.policyOwner, at.owner) && at.policyRepo != "" && !strings.EqualFold(at.policyRepo, at.repo) {
+		repos = append(repos, at.policyRepo)
+	}
+	return repos
+}
+
+func (at *AppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := at.installationToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "token "+token)
+	resp, err := at.base.RoundTrip(authed)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || req.Body != nil {
+		return resp, err
+	}
+
+	// The token may have been revoked early, get a new one and try once more.
+	resp.Body.Close()
+	at.mu.Lock()
+	at.token = ""
+	at.mu.Unlock()
+	token, err = at.installationToken(req)
+	if err != nil {
+		return nil, err
+	}
+	authed = req.Clone(req.Context())
+	authed.Header.Set("Authorization", "token "+token)
+	return at.base.RoundTrip(authed)
+}
+
+// Returns a valid installation token, fetching a new one if needed.
+func (at *AppTransport) installationToken(req *http.Request) (string, error) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.token != "" && at.now().Add(tokenRefreshMargin).Before(at.expiresAt) {
+		return at.token, nil
+	}
+
+	jwt, err := at.appJwt()
+	if err != nil {
+		return "", err
+	}
+
+	if at.installationId == 0 {
+		var installation github.Installation
+		err = at.appRequest(req, http.MethodGet, fmt.Sprintf("repos/%s/%s/installation", at.owner, at.repo), jwt, nil, &installation)
+		if err != nil {
+			return "", fmt.Errorf("could not find app installation for %s/%s: %w", at.owner, at.repo, err)
+		}
+		at.installationId = installation.GetID()
+	}
+
+	// Limit the token to the repos we're working with.
+	body := map[string]any{"repositories": at.tokenRepos()}
+	var token github.InstallationToken
+	err = at.appRequest(req, http.MethodPost, fmt.Sprintf("app/installations/%d/access_tokens", at.installationId), jwt, body, &token)
+	if err != nil {
+		return "", fmt.Errorf("could not get installation token for %s/%s: %w", at.owner, at.repo, err)
+	}
+	at.token = token.GetToken()
+	at.expiresAt = token.GetExpiresAt().Time
+	return at.token, nil
+}
+
+// Issues a request to the app API 'path' authenticated with the app JWT.
+func (at *AppTransport) appRequest(orig *http.Request, method, path, jwt string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(orig.Context(), method, fmt.Sprintf("%s/%s", at.apiUrl, path), reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := at.appBase.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %d: %s", method, req.URL.Path, resp.StatusCode, string(respBody))
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// Creates the RS256 signed JWT that authenticates as the app itself.
+func (at *AppTransport) appJwt() (string, error) {
+	now := at.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		// Allow for some clock drift.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJwtLifetime).Unix(),
+		"iss": at.appId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, at.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("could not sign app jwt: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Authenticates as the installation of GitHub App 'appId' on this connection's repo
+// instead of with a token. Tokens are limited to the repo and the policy repo.
+func (ghc *GitHubConnection) WithAppAuth(appId string, key *rsa.PrivateKey) *GitHubConnection {
+	httpClient := ghc.client.Client()
+	transport := NewAppTransport(httpClient.Transport, ghc.client.BaseURL.String(), appId, key, ghc.owner, ghc.repo).
+		WithPolicyRepo(ghc.policyRepoOwner, ghc.policyRepo)
+	client := github.NewClient(&http.Client{Transport: transport, Timeout: httpClient.Timeout})
+	client.BaseURL = ghc.client.BaseURL
+	client.UploadURL = ghc.client.UploadURL
+	ghc.client = client
+	return ghc
+}
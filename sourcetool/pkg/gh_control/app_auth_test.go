@@ -0,0 +1,258 @@
+package gh_control
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// A stand-in for the GitHub API that issues installation tokens for app "123"
+// and serves a commit to requests that use a valid token.
+type tokenStub struct {
+	t            *testing.T
+	key          *rsa.PrivateKey
+	now          time.Time
+	tokenCount   int
+	validTokens  map[string]bool
+	requestedFor []string
+	// How many times the installation was looked up.
+	installationLookups int
+}
+
+func (ts *tokenStub) verifyJwt(auth string) {
+	ts.t.Helper()
+	jwt, found := strings.CutPrefix(auth, "Bearer ")
+	if !found {
+		ts.t.Fatalf("app request not authenticated with a jwt: %q", auth)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		ts.t.Fatalf("malformed jwt %q", jwt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		ts.t.Fatalf("malformed jwt signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&ts.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		ts.t.Fatalf("invalid jwt signature: %v", err)
+	}
+	claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(claimsJson, &claims); err != nil {
+		ts.t.Fatalf("malformed jwt claims: %v", err)
+	}
+	if claims.Iss != "123" {
+		ts.t.Errorf("jwt iss = %q, want %q", claims.Iss, "123")
+	}
+	if claims.Exp-claims.Iat > 600 {
+		ts.t.Errorf("jwt valid for %ds, GitHub allows at most 600", claims.Exp-claims.Iat)
+	}
+}
+
+func (ts *tokenStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/repos/owner/repo/installation":
+		ts.verifyJwt(r.Header.Get("Authorization"))
+		ts.installationLookups++
+		w.Header().Set("ETag", `"installation"`)
+		_ = json.NewEncoder(w).Encode(github.Installation{ID: github.Ptr(int64(42))})
+	case r.URL.Path == "/app/installations/42/access_tokens" && r.Method == http.MethodPost:
+		ts.verifyJwt(r.Header.Get("Authorization"))
+		var body struct {
+			Repositories []string `json:"repositories"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		ts.requestedFor = append(ts.requestedFor, body.Repositories...)
+		ts.tokenCount++
+		token := fmt.Sprintf("token-%d", ts.tokenCount)
+		ts.validTokens[token] = true
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.InstallationToken{
+			Token:     github.Ptr(token),
+			ExpiresAt: &github.Timestamp{Time: ts.now.Add(time.Hour)},
+		})
+	case r.URL.Path == "/repos/owner/repo/git/commits/abc123":
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "token ")
+		if !ts.validTokens[token] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(github.Commit{SHA: github.Ptr("abc123"), Parents: []*github.Commit{{SHA: github.Ptr("prev123")}}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestAppConnection(t *testing.T) (*GitHubConnection, *tokenStub, *AppTransport) {
+	t.Helper()
+	return newTestAppConnectionWithCache(t, "")
+}
+
+// Like newTestAppConnection but requests go through a CachingTransport using cacheDir.
+func newTestAppConnectionWithCache(t *testing.T, cacheDir string) (*GitHubConnection, *tokenStub, *AppTransport) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	stub := &tokenStub{t: t, key: key, now: time.Now(), validTokens: map[string]bool{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	httpClient := server.Client()
+	if cacheDir != "" {
+		httpClient = &http.Client{Transport: NewCachingTransport(httpClient.Transport, cacheDir)}
+	}
+	client := github.NewClient(httpClient)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	ghc := NewGhConnectionWithClient("owner", "repo", "refs/heads/main", client).WithAppAuth("123", key)
+	transport := ghc.Client().Client().Transport.(*AppTransport)
+	return ghc, stub, transport
+}
+
+func TestAppAuth(t *testing.T) {
+	ghc, stub, _ := newTestAppConnection(t)
+
+	for i := 0; i < 2; i++ {
+		prior, err := source_control.PriorCommit(context.Background(), ghc, "abc123")
+		if err != nil {
+			t.Fatalf("PriorCommit() error = %v", err)
+		}
+		if prior != "prev123" {
+			t.Errorf("PriorCommit() = %q, want %q", prior, "prev123")
+		}
+	}
+	if stub.tokenCount != 1 {
+		t.Errorf("got %d installation tokens, want the token to be reused", stub.tokenCount)
+	}
+	if len(stub.requestedFor) != 1 || stub.requestedFor[0] != "repo" {
+		t.Errorf("token requested for %v, want [repo]", stub.requestedFor)
+	}
+}
+
+func TestAppAuth_RefreshesExpiringToken(t *testing.T) {
+	ghc, stub, transport := newTestAppConnection(t)
+
+	if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	// Move the clock to just before the token expires.
+	transport.now = func() time.Time { return stub.now.Add(58 * time.Minute) }
+	if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if stub.tokenCount != 2 {
+		t.Errorf("got %d installation tokens, want the token to be refreshed", stub.tokenCount)
+	}
+}
+
+func TestAppAuth_RetriesRevokedToken(t *testing.T) {
+	ghc, stub, _ := newTestAppConnection(t)
+
+	if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	stub.validTokens = map[string]bool{}
+	if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+		t.Fatalf("PriorCommit() error = %v after the token was revoked", err)
+	}
+	if stub.tokenCount != 2 {
+		t.Errorf("got %d installation tokens, want a new token after it was revoked", stub.tokenCount)
+	}
+}
+
+func TestAppAuth_TokenCoversPolicyRepo(t *testing.T) {
+	tests := []struct {
+		name                    string
+		policyOwner, policyRepo string
+		expected                []string
+	}{
+		{
+			name:        "policy repo of the same owner",
+			policyOwner: "owner", policyRepo: "policies",
+			expected: []string{"repo", "policies"},
+		},
+		{
+			name:        "policy repo of another owner",
+			policyOwner: "someone-else", policyRepo: "policies",
+			expected: []string{"repo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc, stub, _ := newTestAppConnection(t)
+			ghc = ghc.WithPolicyRepo(tt.policyOwner, tt.policyRepo)
+
+			if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+				t.Fatalf("PriorCommit() error = %v", err)
+			}
+			if fmt.Sprint(stub.requestedFor) != fmt.Sprint(tt.expected) {
+				t.Errorf("token requested for %v, want %v", stub.requestedFor, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAppAuth_AppRequestsNotCached(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	for i := 0; i < 2; i++ {
+		// A new connection each time, like separate runs.
+		ghc, stub, _ := newTestAppConnectionWithCache(t, cacheDir)
+		if _, err := source_control.PriorCommit(context.Background(), ghc, "abc123"); err != nil {
+			t.Fatalf("PriorCommit() error = %v", err)
+		}
+		if stub.installationLookups != 1 {
+			t.Errorf("looked up the installation %d times, want 1", stub.installationLookups)
+		}
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read cache dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("cache has %d entries, want app requests not to be cached", len(entries))
+	}
+}
+
+func TestLoadAppPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "app.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, pemBytes, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	loaded, err := LoadAppPrivateKey(path)
+	if err != nil {
+		t.Fatalf("LoadAppPrivateKey() error = %v", err)
+	}
+	if !loaded.Equal(key) {
+		t.Errorf("LoadAppPrivateKey() returned a different key")
+	}
+}
@@ -0,0 +1,130 @@
+package gh_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+// The id GitHub uses for the admin repository role in bypass lists.
+const adminRepositoryRoleId = 5
+
+// Gets the classic branch protection for the branch, nil if it isn't protected
+// or we're not allowed to see how it's protected.
+func (ghc *GitHubConnection) getBranchProtection(ctx context.Context, branch string) (*github.Protection, error) {
+	protection, _, err := ghc.Client().Repositories.GetBranchProtection(ctx, ghc.Owner(), ghc.Repo(), branch)
+	if errors.Is(err, github.ErrBranchNotProtected) {
+		return nil, nil
+	}
+	if err != nil {
+		if isForbiddenOrNotFound(err) {
+			log.Printf("cannot read branch protection for %s, ignoring it: %v", branch, err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get branch protection for %s: %w", branch, err)
+	}
+	return protection, nil
+}
+
+// Returns who can bypass all of the branch protection, repo admins unless it's enforced for them too.
+func protectionBypassActors(protection *github.Protection) []slsa_types.BypassActor {
+	if protection.EnforceAdmins != nil && protection.EnforceAdmins.Enabled {
+		return nil
+	}
+	return []slsa_types.BypassActor{{ActorType: string(github.BypassActorTypeRepositoryRole), ActorId: adminRepositoryRoleId, BypassMode: string(github.BypassModeAlways)}}
+}
+
+// Computes the continuity control from classic branch protection, returning nil if it's not enabled.
+// Branch protection doesn't say when it was enabled, so it's only known to be enforced since 'pushTime'.
+func computeProtectionContinuityControl(protection *github.Protection, pushTime time.Time) *slsa_types.Control {
+	if protection == nil {
+		return nil
+	}
+	// Force pushes and deletions are blocked unless they're explicitly allowed.
+	if protection.AllowForcePushes != nil && protection.AllowForcePushes.Enabled {
+		return nil
+	}
+	if protection.AllowDeletions != nil && protection.AllowDeletions.Enabled {
+		return nil
+	}
+	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime, BypassActors: protectionBypassActors(protection)}
+}
+
+// Computes the review control from classic branch protection, returning nil if it's not enabled.
+func computeProtectionReviewControl(protection *github.Protection, pushTime time.Time) *slsa_types.Control {
+	if protection == nil || protection.RequiredPullRequestReviews == nil {
+		return nil
+	}
+	reviews := protection.RequiredPullRequestReviews
+	if !pullRequestParamsRequireReview(&github.PullRequestRuleParameters{
+		RequiredApprovingReviewCount: reviews.RequiredApprovingReviewCount,
+		DismissStaleReviewsOnPush:    reviews.DismissStaleReviews,
+		RequireCodeOwnerReview:       reviews.RequireCodeOwnerReviews,
+		RequireLastPushApproval:      reviews.RequireLastPushApproval,
+	}) {
+		return nil
+	}
+
+	bypassActors := protectionBypassActors(protection)
+	if allowances := reviews.BypassPullRequestAllowances; allowances != nil {
+		for _, user := range allowances.Users {
+			bypassActors = append(bypassActors, slsa_types.BypassActor{ActorType: "User", ActorId: user.GetID(), BypassMode: string(github.BypassModeAlways)})
+		}
+		for _, team := range allowances.Teams {
+			bypassActors = append(bypassActors, slsa_types.BypassActor{ActorType: string(github.BypassActorTypeTeam), ActorId: team.GetID(), BypassMode: string(github.BypassModeAlways)})
+		}
+		for _, app := range allowances.Apps {
+			bypassActors = append(bypassActors, slsa_types.BypassActor{ActorType: string(github.BypassActorTypeIntegration), ActorId: app.GetID(), BypassMode: string(github.BypassModeAlways)})
+		}
+	}
+	return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime, BypassActors: bypassActors}
+}
+
+// Computes the signed commits control from classic branch protection, returning nil if it's not enabled.
+func computeProtectionSignedCommitsControl(protection *github.Protection, pushTime time.Time) *slsa_types.Control {
+	if protection == nil || protection.RequiredSignatures == nil || !protection.RequiredSignatures.GetEnabled() {
+		return nil
+	}
+	return &slsa_types.Control{Name: slsa_types.SignedCommits, Since: pushTime, BypassActors: protectionBypassActors(protection)}
+}
+
+// Computes the linear history control from classic branch protection, returning nil if it's not enabled.
+func computeProtectionLinearHistoryControl(protection *github.Protection, pushTime time.Time) *slsa_types.Control {
+	if protection == nil || protection.RequireLinearHistory == nil || !protection.RequireLinearHistory.Enabled {
+		return nil
+	}
+	return &slsa_types.Control{Name: slsa_types.RequiredLinearHistory, Since: pushTime, BypassActors: protectionBypassActors(protection)}
+}
+
+// Combines the same control enforced by both rulesets and branch protection.
+// Only those who can bypass both can bypass it. It's been enforced since either started
+// enforcing it, unless that one could be bypassed by more actors, in which case it's only
+// been enforced as recorded since the other started too.
+func combineControls(control, other *slsa_types.Control) *slsa_types.Control {
+	if control == nil {
+		return other
+	}
+	if other == nil {
+		return control
+	}
+	earlier, later := control, other
+	if other.Since.Before(control.Since) {
+		earlier, later = other, control
+	}
+	bypassActors := intersectBypassActors(control.BypassActors, other.BypassActors)
+	since := earlier.Since
+	if !slices.Equal(bypassActors, earlier.BypassActors) {
+		since = later.Since
+	}
+	return &slsa_types.Control{
+		Name:         control.Name,
+		Since:        since,
+		BypassActors: bypassActors,
+	}
+}
@@ -0,0 +1,175 @@
+package gh_control
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestBranchProtectionControls(t *testing.T) {
+	pushTime := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	admins := slsa_types.BypassActor{ActorType: "RepositoryRole", ActorId: adminRepositoryRoleId, BypassMode: "always"}
+	requiredReviews := map[string]any{
+		"required_approving_review_count": 1,
+		"dismiss_stale_reviews":           true,
+		"require_code_owner_reviews":      true,
+		"require_last_push_approval":      true,
+	}
+	notProtected := func(w http.ResponseWriter, _ *http.Request) any {
+		w.WriteHeader(http.StatusNotFound)
+		return map[string]any{"message": "Branch not protected"}
+	}
+
+	tests := []struct {
+		name               string
+		protection         any
+		expectedContinuity *slsa_types.Control
+		expectedReview     *slsa_types.Control
+	}{
+		{
+			name:       "not protected",
+			protection: notProtected,
+		},
+		{
+			name: "enforced for admins",
+			protection: map[string]any{
+				"required_pull_request_reviews": requiredReviews,
+				"enforce_admins":                map[string]any{"enabled": true},
+				"allow_force_pushes":            map[string]any{"enabled": false},
+				"allow_deletions":               map[string]any{"enabled": false},
+			},
+			expectedContinuity: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime},
+			expectedReview:     &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime},
+		},
+		{
+			name: "admins can bypass",
+			protection: map[string]any{
+				"required_pull_request_reviews": requiredReviews,
+				"enforce_admins":                map[string]any{"enabled": false},
+			},
+			expectedContinuity: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime, BypassActors: []slsa_types.BypassActor{admins}},
+			expectedReview:     &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime, BypassActors: []slsa_types.BypassActor{admins}},
+		},
+		{
+			name: "force pushes allowed",
+			protection: map[string]any{
+				"enforce_admins":     map[string]any{"enabled": true},
+				"allow_force_pushes": map[string]any{"enabled": true},
+			},
+		},
+		{
+			name: "deletions allowed",
+			protection: map[string]any{
+				"enforce_admins":  map[string]any{"enabled": true},
+				"allow_deletions": map[string]any{"enabled": true},
+			},
+		},
+		{
+			name: "too few reviews",
+			protection: map[string]any{
+				"required_pull_request_reviews": map[string]any{"required_approving_review_count": 0, "dismiss_stale_reviews": true},
+				"enforce_admins":                map[string]any{"enabled": true},
+			},
+			expectedContinuity: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime},
+		},
+		{
+			name: "pull request bypass allowances",
+			protection: map[string]any{
+				"required_pull_request_reviews": map[string]any{
+					"required_approving_review_count": 1,
+					"dismiss_stale_reviews":           true,
+					"require_code_owner_reviews":      true,
+					"require_last_push_approval":      true,
+					"bypass_pull_request_allowances": map[string]any{
+						"users": []map[string]any{{"id": 11}},
+						"teams": []map[string]any{{"id": 22}},
+						"apps":  []map[string]any{{"id": 33}},
+					},
+				},
+				"enforce_admins": map[string]any{"enabled": true},
+			},
+			expectedContinuity: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime},
+			expectedReview: &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime, BypassActors: []slsa_types.BypassActor{
+				{ActorType: "User", ActorId: 11, BypassMode: "always"},
+				{ActorType: "Team", ActorId: 22, BypassMode: "always"},
+				{ActorType: "Integration", ActorId: 33, BypassMode: "always"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, map[string]any{"/repos/owner/repo/branches/main/protection": tt.protection})
+			protection, err := ghc.getBranchProtection(context.Background(), "main")
+			if err != nil {
+				t.Fatalf("getBranchProtection() error = %v", err)
+			}
+			if got := computeProtectionContinuityControl(protection, pushTime); !reflect.DeepEqual(got, tt.expectedContinuity) {
+				t.Errorf("computeProtectionContinuityControl() = %v, want %v", got, tt.expectedContinuity)
+			}
+			if got := computeProtectionReviewControl(protection, pushTime); !reflect.DeepEqual(got, tt.expectedReview) {
+				t.Errorf("computeProtectionReviewControl() = %v, want %v", got, tt.expectedReview)
+			}
+		})
+	}
+}
+
+func TestCombineControls(t *testing.T) {
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	admins := slsa_types.BypassActor{ActorType: "RepositoryRole", ActorId: adminRepositoryRoleId, BypassMode: "always"}
+	team := slsa_types.BypassActor{ActorType: "Team", ActorId: 5, BypassMode: "always"}
+	rulesetControl := &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: later, BypassActors: []slsa_types.BypassActor{admins, team}}
+	protectionControl := &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: earlier, BypassActors: []slsa_types.BypassActor{admins}}
+
+	tests := []struct {
+		name     string
+		control  *slsa_types.Control
+		other    *slsa_types.Control
+		expected *slsa_types.Control
+	}{
+		{
+			name: "neither",
+		},
+		{
+			name:     "rulesets only",
+			control:  rulesetControl,
+			expected: rulesetControl,
+		},
+		{
+			name:     "branch protection only",
+			other:    protectionControl,
+			expected: protectionControl,
+		},
+		{
+			name:     "both",
+			control:  rulesetControl,
+			other:    protectionControl,
+			expected: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: earlier, BypassActors: []slsa_types.BypassActor{admins}},
+		},
+		{
+			name:     "earlier could be bypassed by more actors",
+			control:  &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: earlier, BypassActors: []slsa_types.BypassActor{admins, team}},
+			other:    &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: later, BypassActors: []slsa_types.BypassActor{admins}},
+			expected: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: later, BypassActors: []slsa_types.BypassActor{admins}},
+		},
+		{
+			name:     "earlier could be bypassed in more ways",
+			control:  &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: later, BypassActors: []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "pull_request"}}},
+			other:    &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: earlier, BypassActors: []slsa_types.BypassActor{team}},
+			expected: &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: later, BypassActors: []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "pull_request"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := combineControls(tt.control, tt.other); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("combineControls() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.CheckResultsProvider = (*GitHubConnection)(nil)
+
+// Lists the latest check runs against 'sha'.
+func (ghc *GitHubConnection) listCheckRuns(ctx context.Context, sha string) ([]*github.CheckRun, error) {
+	opts := &github.ListCheckRunsOptions{Filter: github.Ptr("latest"), ListOptions: github.ListOptions{PerPage: 100}}
+	var runs []*github.CheckRun
+	for {
+		result, resp, err := ghc.Client().Checks.ListCheckRunsForRef(ctx, ghc.Owner(), ghc.Repo(), sha, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not list check runs for %s: %w", sha, err)
+		}
+		runs = append(runs, result.CheckRuns...)
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// Lists the latest commit status for each context against 'sha'.
+func (ghc *GitHubConnection) listCommitStatuses(ctx context.Context, sha string) ([]*github.RepoStatus, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var statuses []*github.RepoStatus
+	for {
+		combined, resp, err := ghc.Client().Repositories.GetCombinedStatus(ctx, ghc.Owner(), ghc.Repo(), sha, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not get commit statuses for %s: %w", sha, err)
+		}
+		statuses = append(statuses, combined.Statuses...)
+		if resp.NextPage == 0 {
+			return statuses, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// Gets the results of the check runs and commit statuses against 'sha'.
+func (ghc *GitHubConnection) checkResultsFor(ctx context.Context, sha, commit string) ([]slsa_types.CheckResult, error) {
+	// Only note the commit the checks ran against when it's not the one they're for.
+	ranAgainst := ""
+	if sha != commit {
+		ranAgainst = sha
+	}
+
+	runs, err := ghc.listCheckRuns(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+	var results []slsa_types.CheckResult
+	for _, run := range runs {
+		conclusion := run.GetConclusion()
+		if conclusion == "" {
+			conclusion = run.GetStatus()
+		}
+		results = append(results, slsa_types.CheckResult{
+			Name:       run.GetName(),
+			Conclusion: conclusion,
+			App:        run.GetApp().GetSlug(),
+			AppId:      run.GetApp().GetID(),
+			Commit:     ranAgainst,
+		})
+	}
+
+	statuses, err := ghc.listCommitStatuses(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		results = append(results, slsa_types.CheckResult{
+			Name:       status.GetContext(),
+			Conclusion: status.GetState(),
+			Commit:     ranAgainst,
+		})
+	}
+	return results, nil
+}
+
+// Gets the results of the checks that ran against 'commit'. If it was merged from a pull
+// request the checks that ran against the pull request's head are included too, since
+// that's usually where CI runs.
+func (ghc *GitHubConnection) GetCheckResults(ctx context.Context, commit string) ([]slsa_types.CheckResult, error) {
+	results, err := ghc.checkResultsFor(ctx, commit, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := ghc.mergedPullRequest(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil || pr.GetHead().GetSHA() == commit {
+		return results, nil
+	}
+	head := pr.GetHead().GetSHA()
+	headResults, err := ghc.checkResultsFor(ctx, head, commit)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, headResults...), nil
+}
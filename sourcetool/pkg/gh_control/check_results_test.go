@@ -0,0 +1,69 @@
+package gh_control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestGetCheckResults(t *testing.T) {
+	checkRuns := func(runs ...map[string]any) map[string]any {
+		return map[string]any{"total_count": len(runs), "check_runs": runs}
+	}
+	actions := map[string]any{"id": 15368, "slug": "github-actions"}
+
+	tests := []struct {
+		name      string
+		responses map[string]any
+		expected  []slsa_types.CheckResult
+	}{
+		{
+			name: "pushed directly",
+			responses: map[string]any{
+				"/repos/owner/repo/commits/abc123/check-runs": checkRuns(
+					map[string]any{"name": "build", "status": "completed", "conclusion": "success", "app": actions},
+					map[string]any{"name": "test", "status": "in_progress", "app": actions}),
+				"/repos/owner/repo/commits/abc123/status": map[string]any{"statuses": []map[string]any{{"context": "ci/legacy", "state": "failure"}}},
+				"/repos/owner/repo/commits/abc123/pulls":  []map[string]any{},
+			},
+			expected: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", App: "github-actions", AppId: 15368},
+				{Name: "test", Conclusion: "in_progress", App: "github-actions", AppId: 15368},
+				{Name: "ci/legacy", Conclusion: "failure"},
+			},
+		},
+		{
+			name: "merged from a pull request",
+			responses: map[string]any{
+				"/repos/owner/repo/commits/abc123/check-runs": checkRuns(),
+				"/repos/owner/repo/commits/abc123/status":     map[string]any{},
+				"/repos/owner/repo/commits/abc123/pulls": []map[string]any{
+					// Only contains the commit, wasn't merged as it.
+					{"number": 1, "merged_at": "2025-01-01T00:00:00Z", "merge_commit_sha": "other", "head": map[string]any{"sha": "wrong"}},
+					{"number": 2, "merged_at": "2025-01-02T00:00:00Z", "merge_commit_sha": "abc123", "head": map[string]any{"sha": "head456"}},
+				},
+				"/repos/owner/repo/commits/head456/check-runs": checkRuns(
+					map[string]any{"name": "build", "status": "completed", "conclusion": "success", "app": actions}),
+				"/repos/owner/repo/commits/head456/status": map[string]any{},
+			},
+			expected: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", App: "github-actions", AppId: 15368, Commit: "head456"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, tt.responses)
+			got, err := ghc.GetCheckResults(context.Background(), "abc123")
+			if err != nil {
+				t.Fatalf("GetCheckResults() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetCheckResults() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
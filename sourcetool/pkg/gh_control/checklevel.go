@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"slices"
 	"time"
 
 	"github.com/google/go-github/v69/github"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 )
 
+var _ source_control.DiscontinuityDetector = (*GitHubConnection)(nil)
+
 type actor struct {
 	Login string `json:"login"`
 }
@@ -25,99 +29,194 @@ type activity struct {
 	Actor        actor  `json:"actor"`
 }
 
-func (ghc *GitHubConnection) commitActivity(ctx context.Context, commit, targetRef string) (*activity, error) {
-	// Unfortunately the gh_client doesn't have native support for this...'
-	reqUrl := fmt.Sprintf("repos/%s/%s/activity", ghc.Owner(), ghc.Repo())
-	req, err := ghc.Client().NewRequest("GET", reqUrl, nil)
-	if err != nil {
-		return nil, err
+// Returns the smallest activity API time_period that covers 'lookback'.
+func activityTimePeriod(lookback time.Duration) string {
+	periods := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"month", 31 * 24 * time.Hour},
+		{"quarter", 92 * 24 * time.Hour},
+	}
+	for _, period := range periods {
+		if lookback <= period.duration {
+			return period.name
+		}
 	}
+	return "year"
+}
 
-	var result []*activity
-	_, err = ghc.Client().Do(ctx, req, &result)
-	if err != nil {
-		return nil, err
+// Visits the activity on 'ref' newest first, back to 'oldest' or until 'visit' returns false.
+// Returns whether the visit was stopped, or source_control.ErrHistoryIncomplete if the
+// activity runs out before 'oldest' since then there's no telling what happened before it.
+func (ghc *GitHubConnection) visitActivity(ctx context.Context, ref string, oldest time.Time, visit func(*activity) bool) (bool, error) {
+	// Unfortunately the gh_client doesn't have native support for this...'
+	query := url.Values{
+		"ref":         {ref},
+		"time_period": {activityTimePeriod(time.Since(oldest))},
+		"per_page":    {"100"},
 	}
 
-	monitoredTypes := []string{"push", "force_push", "pr_merge"}
-	for _, activity := range result {
-		if !slices.Contains(monitoredTypes, activity.ActivityType) {
-			continue
+	for {
+		reqUrl := fmt.Sprintf("repos/%s/%s/activity?%s", ghc.Owner(), ghc.Repo(), query.Encode())
+		req, err := ghc.Client().NewRequest("GET", reqUrl, nil)
+		if err != nil {
+			return false, err
 		}
-		if activity.After == commit && activity.Ref == targetRef {
-			// Found it
-			return activity, nil
+
+		var result []*activity
+		resp, err := ghc.Client().Do(ctx, req, &result)
+		if err != nil {
+			return false, err
 		}
+
+		for _, activity := range result {
+			if activity.Timestamp.Before(oldest) {
+				return false, nil
+			}
+			if !visit(activity) {
+				return true, nil
+			}
+		}
+
+		if resp.After == "" {
+			return false, fmt.Errorf("activity on %s doesn't go back to %v: %w", ref, oldest, source_control.ErrHistoryIncomplete)
+		}
+		query.Set("after", resp.After)
 	}
+}
 
-	return nil, fmt.Errorf("could not find repo activity for commit %s and ref %s", commit, targetRef)
+func (ghc *GitHubConnection) commitActivity(ctx context.Context, commit, targetRef string) (*activity, error) {
+	// Only look at activity on the ref within the look-back window, paging through
+	// the results (newest first) until we find the commit or run out of window.
+	monitoredTypes := []string{"push", "force_push", "pr_merge", "branch_creation"}
+	var found *activity
+	stopped, err := ghc.visitActivity(ctx, targetRef, time.Now().Add(-ghc.activityLookback), func(activity *activity) bool {
+		if slices.Contains(monitoredTypes, activity.ActivityType) && activity.After == commit && activity.Ref == targetRef {
+			// Found it
+			found = activity
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !stopped {
+		return nil, fmt.Errorf("could not find repo activity for commit %s and ref %s in the last %v", commit, targetRef, ghc.activityLookback)
+	}
+	return found, nil
 }
 
-type GhControlStatus struct {
-	// The time the commit we're evaluating was pushed.
-	CommitPushTime time.Time
-	// The actor that pushed the commit.
-	ActorLogin string
-	// The type of activity that created the commit.
-	ActivityType string
-	// The controls that are enabled according to the GitHub API.
-	// May not include other controls like if we have provenance.
-	Controls slsa_types.Controls
+// Gets the times 'ref' was force pushed, deleted or created between 'from' and 'to', oldest first.
+// GitHub keeps activity for a limited time, so this fails with source_control.ErrHistoryIncomplete
+// when 'from' is older than that.
+func (ghc *GitHubConnection) GetDiscontinuities(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.Discontinuity, error) {
+	discontinuityTypes := []string{slsa_types.DiscontinuityForcePush, slsa_types.DiscontinuityBranchDeletion, slsa_types.DiscontinuityBranchCreation}
+	var discontinuities []slsa_types.Discontinuity
+	_, err := ghc.visitActivity(ctx, ref, from, func(activity *activity) bool {
+		if activity.Timestamp.After(to) || activity.Ref != ref || !slices.Contains(discontinuityTypes, activity.ActivityType) {
+			return true
+		}
+		discontinuities = append(discontinuities, slsa_types.Discontinuity{
+			Type:   activity.ActivityType,
+			Actor:  activity.Actor.Login,
+			Time:   activity.Timestamp,
+			Before: activity.Before,
+			After:  activity.After,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list activity on %s: %w", ref, err)
+	}
+	slices.Reverse(discontinuities)
+	return discontinuities, nil
 }
 
 func (ghc *GitHubConnection) ruleMeetsRequiresReview(rule *github.PullRequestBranchRule) bool {
-	return rule.Parameters.RequiredApprovingReviewCount > 0 &&
-		rule.Parameters.DismissStaleReviewsOnPush &&
-		rule.Parameters.RequireCodeOwnerReview &&
-		rule.Parameters.RequireLastPushApproval
+	return pullRequestParamsRequireReview(&rule.Parameters)
+}
+
+func pullRequestParamsRequireReview(params *github.PullRequestRuleParameters) bool {
+	return params.RequiredApprovingReviewCount > 0 &&
+		params.DismissStaleReviewsOnPush &&
+		params.RequireCodeOwnerReview &&
+		params.RequireLastPushApproval
 }
 
 // Computes the continuity control returning nil if it's not enabled.
 func (ghc *GitHubConnection) computeContinuityControl(ctx context.Context, commit string, rules *github.BranchRules, activity *activity) (*slsa_types.Control, error) {
-	oldestDeletion, err := ghc.getOldestActiveRule(ctx, rules.Deletion)
+	deletionRulesets, err := ghc.getActiveRulesets(ctx, rules.Deletion)
 	if err != nil {
 		return nil, err
 	}
 
-	oldestNoFf, err := ghc.getOldestActiveRule(ctx, rules.NonFastForward)
+	noFfRulesets, err := ghc.getActiveRulesets(ctx, rules.NonFastForward)
 	if err != nil {
 		return nil, err
 	}
 
-	if oldestDeletion == nil || oldestNoFf == nil {
-		log.Printf("oldestDeletion (%v) or oldestNoFf (%v) is nil, cannot be L2+", oldestDeletion, oldestNoFf)
+	// The rules may have been enforced since before the rulesets were last updated.
+	deletionSince, foundDeletion, err := ghc.earliestQualifyingSince(ctx, deletionRulesets, hasDeletionRule)
+	if err != nil {
+		return nil, err
+	}
+	noFfSince, foundNoFf, err := ghc.earliestQualifyingSince(ctx, noFfRulesets, hasNonFastForwardRule)
+	if err != nil {
+		return nil, err
+	}
+	if !foundDeletion || !foundNoFf {
+		log.Printf("deletion (%v) or non_fast_forward (%v) rule is not active, cannot be L2+", foundDeletion, foundNoFf)
 		return nil, nil
 	}
 
-	newestRule := oldestDeletion
-	if newestRule.UpdatedAt.Time.Before(oldestNoFf.UpdatedAt.Time) {
-		newestRule = oldestNoFf
-	}
+	since := slsa_types.LaterTime(deletionSince, noFfSince)
 
 	// Check that the commit was created after the newest rule was enabled...
 	// to be sure folks aren't somehow sneaking something through...
-	if activity.Timestamp.Before(newestRule.UpdatedAt.Time) {
-		return nil, fmt.Errorf("commit %s created before (%v) the rule was enabled (%v), that shouldn't happen", commit, activity.Timestamp, newestRule.UpdatedAt.Time)
+	if activity.Timestamp.Before(since) {
+		return nil, fmt.Errorf("commit %s created before (%v) the rule was enabled (%v), that shouldn't happen", commit, activity.Timestamp, since)
 	}
 
-	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: newestRule.UpdatedAt.Time}, nil
+	// Bypassing either rule breaks continuity.
+	bypassActors := mergeBypassActors(effectiveBypassActors(deletionRulesets), effectiveBypassActors(noFfRulesets))
+	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: since, BypassActors: bypassActors}, nil
 }
 
 func enforcesImmutableTags(ruleset *github.RepositoryRuleset) bool {
-	if ruleset.Rules != nil &&
+	return ruleset.Rules != nil &&
 		ruleset.Rules.Update != nil &&
 		ruleset.Rules.Deletion != nil &&
-		ruleset.Rules.NonFastForward != nil &&
-		ruleset.Conditions != nil &&
-		len(ruleset.Conditions.RefName.Exclude) == 0 &&
-		slices.Contains(ruleset.Conditions.RefName.Include, "~ALL") {
-		return true
+		ruleset.Rules.NonFastForward != nil
+}
+
+// Reports if the ruleset applies to every tag.
+func appliesToAllTags(ruleset *github.RepositoryRuleset) bool {
+	conditions := refConditions(ruleset)
+	return conditions != nil &&
+		len(conditions.Exclude) == 0 &&
+		slices.Contains(conditions.Include, "~ALL")
+}
+
+// Reports if the ruleset's ref_name conditions include 'ref' and don't exclude it.
+func appliesToRef(ruleset *github.RepositoryRuleset, ref string) bool {
+	conditions := refConditions(ruleset)
+	if conditions == nil {
+		return false
+	}
+	matches := func(pattern string) bool {
+		return pattern == "~ALL" || source_control.MatchesGlob(source_control.FnmatchGlob, pattern, ref)
 	}
-	return false
+	return slices.ContainsFunc(conditions.Include, matches) && !slices.ContainsFunc(conditions.Exclude, matches)
 }
 
-func (ghc *GitHubConnection) computeImmutableTagsControl(ctx context.Context, commit string, allRulesets []*github.RepositoryRuleset, activityTime *time.Time) (*slsa_types.Control, error) {
-	var validRuleset *github.RepositoryRuleset
+// Computes the immutable tags control from the active tag rulesets that 'applies' says
+// cover the tags in question, returning nil if there are none.
+func (ghc *GitHubConnection) computeImmutableTagsControl(ctx context.Context, commit string, allRulesets []*github.RepositoryRuleset, activityTime *time.Time, applies rulesetPredicate) (*slsa_types.Control, error) {
+	var validRulesets []*github.RepositoryRuleset
 	for _, ruleset := range allRulesets {
 		if *ruleset.Target != github.RulesetTargetTag {
 			continue
@@ -129,87 +228,157 @@ func (ghc *GitHubConnection) computeImmutableTagsControl(ctx context.Context, co
 
 		// The GitHub API only seems to return a partial ruleset when asking for 'all' the rules
 		// So we'll ask for this specific rule here so we can get all the data.
-		fullRuleset, _, err := ghc.Client().Repositories.GetRuleset(ctx, ghc.Owner(), ghc.Repo(), ruleset.GetID(), false)
+		fullRuleset, err := ghc.getFullRuleset(ctx, ruleset)
 		if err != nil {
 			return nil, fmt.Errorf("could not get full ruleset for ruleset id %d: err: %w", ruleset.GetID(), err)
 		}
 
-		if !enforcesImmutableTags(fullRuleset) {
+		if !enforcesImmutableTags(fullRuleset) || !applies(fullRuleset) {
 			continue
 		}
-		if validRuleset == nil || validRuleset.UpdatedAt.After(ruleset.UpdatedAt.Time) {
-			validRuleset = ruleset
-		}
+		validRulesets = append(validRulesets, fullRuleset)
 	}
 
-	if validRuleset == nil {
+	since, found, err := ghc.earliestQualifyingSince(ctx, validRulesets, enforcesImmutableTags)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
 		return nil, nil
 	}
 
 	// Check that the commit was created after this rule was enabled.
-	if activityTime.Before(validRuleset.UpdatedAt.Time) {
+	if activityTime.Before(since) {
 		return nil, nil
 	}
 
-	return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: validRuleset.UpdatedAt.Time}, nil
+	return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: since, BypassActors: effectiveBypassActors(validRulesets)}, nil
 }
 
 // Computes the review control returning nil if it's not enabled.
 func (ghc *GitHubConnection) computeReviewControl(ctx context.Context, rules []*github.PullRequestBranchRule) (*slsa_types.Control, error) {
-	var oldestActive *github.RepositoryRuleset
+	var qualifying []*github.BranchRuleMetadata
 	for _, rule := range rules {
 		if ghc.ruleMeetsRequiresReview(rule) {
-			ruleset, _, err := ghc.Client().Repositories.GetRuleset(ctx, ghc.Owner(), ghc.Repo(), rule.RulesetID, false)
-			if err != nil {
-				return nil, err
-			}
-			if ruleset.Enforcement == "active" {
-				if oldestActive == nil || oldestActive.UpdatedAt.Time.After(ruleset.UpdatedAt.Time) {
-					oldestActive = ruleset
-				}
-			}
+			qualifying = append(qualifying, &rule.BranchRuleMetadata)
 		}
 	}
 
-	if oldestActive != nil {
-		return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: oldestActive.UpdatedAt.Time}, nil
+	rulesets, err := ghc.getActiveRulesets(ctx, qualifying)
+	if err != nil {
+		return nil, err
+	}
+
+	since, found, err := ghc.earliestQualifyingSince(ctx, rulesets, requiresReview)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
 	}
 
-	return nil, nil
+	return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: since, BypassActors: effectiveBypassActors(rulesets)}, nil
+}
+
+// Computes the control enforced by a single kind of rule, returning nil if none of the
+// rulesets enforce it.
+func (ghc *GitHubConnection) computeRuleControl(ctx context.Context, name string, rules []*github.BranchRuleMetadata, qualifies rulesetPredicate) (*slsa_types.Control, error) {
+	rulesets, err := ghc.getActiveRulesets(ctx, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	since, found, err := ghc.earliestQualifyingSince(ctx, rulesets, qualifies)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+
+	return &slsa_types.Control{Name: name, Since: since, BypassActors: effectiveBypassActors(rulesets)}, nil
+}
+
+// Returns the status checks the ruleset requires as a control, nil if it doesn't require any.
+func rulesetStatusChecks(ruleset *github.RepositoryRuleset) *slsa_types.Control {
+	if ruleset.Rules == nil || ruleset.Rules.RequiredStatusChecks == nil {
+		return nil
+	}
+	params := ruleset.Rules.RequiredStatusChecks
+	control := &slsa_types.Control{Name: slsa_types.RequiredStatusChecks, StrictChecks: params.StrictRequiredStatusChecksPolicy}
+	for _, check := range params.RequiredStatusChecks {
+		control.RequiredChecks = append(control.RequiredChecks, slsa_types.StatusCheck{Context: check.Context, IntegrationId: check.GetIntegrationID()})
+	}
+	if len(control.RequiredChecks) == 0 {
+		return nil
+	}
+	return control
 }
 
-func (ghc *GitHubConnection) getOldestActiveRule(ctx context.Context, rules []*github.BranchRuleMetadata) (*github.RepositoryRuleset, error) {
-	var oldestActive *github.RepositoryRuleset
+// Computes the required status checks control returning nil if no checks are required.
+// The checks required by every ruleset are combined, and they've all been required
+// since the latest any of them started requiring theirs.
+func (ghc *GitHubConnection) computeRequiredStatusChecksControl(ctx context.Context, rules []*github.RequiredStatusChecksBranchRule) (*slsa_types.Control, error) {
+	var metadata []*github.BranchRuleMetadata
 	for _, rule := range rules {
-		ruleset, _, err := ghc.Client().Repositories.GetRuleset(ctx, ghc.Owner(), ghc.Repo(), rule.RulesetID, false)
+		metadata = append(metadata, &rule.BranchRuleMetadata)
+	}
+	rulesets, err := ghc.getActiveRulesets(ctx, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var control *slsa_types.Control
+	for _, ruleset := range rulesets {
+		required := rulesetStatusChecks(ruleset)
+		if required == nil {
+			continue
+		}
+		// The checks may have been required since before the ruleset was last updated.
+		since, err := ghc.qualifyingSince(ctx, ruleset, func(version *github.RepositoryRuleset) bool {
+			previous := rulesetStatusChecks(version)
+			return previous != nil && required.CoveredBy(*previous)
+		})
 		if err != nil {
 			return nil, err
 		}
-		if ruleset.Enforcement == "active" {
-			if oldestActive == nil || oldestActive.UpdatedAt.Time.After(ruleset.UpdatedAt.Time) {
-				oldestActive = ruleset
+		required.Since = since
+		// Bypassing any of the rulesets skips the checks it requires.
+		required.BypassActors = rulesetBypassActors(ruleset)
+
+		if control == nil {
+			control = required
+			continue
+		}
+		control.Since = slsa_types.LaterTime(control.Since, required.Since)
+		control.StrictChecks = control.StrictChecks || required.StrictChecks
+		for _, check := range required.RequiredChecks {
+			if !slices.Contains(control.RequiredChecks, check) {
+				control.RequiredChecks = append(control.RequiredChecks, check)
 			}
 		}
+		control.BypassActors = mergeBypassActors(control.BypassActors, required.BypassActors)
 	}
-	return oldestActive, nil
+	return control, nil
 }
 
 // Determines the controls that are in place for a branch using GitHub's APIs
 // This is necessarily only as good as GitHub's controls and existing APIs.
-func (ghc *GitHubConnection) GetBranchControls(ctx context.Context, commit, ref string) (*GhControlStatus, error) {
+func (ghc *GitHubConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
 	// We want to know when this commit was pushed to ensure the rules were active _then_.
 	activity, err := ghc.commitActivity(ctx, commit, ref)
 	if err != nil {
 		return nil, err
 	}
 
-	controlStatus := GhControlStatus{
+	controlStatus := source_control.ControlStatus{
 		CommitPushTime: activity.Timestamp,
 		ActivityType:   activity.ActivityType,
 		ActorLogin:     activity.Actor.Login,
+		BeforeCommit:   activity.Before,
 		Controls:       slsa_types.Controls{}}
 
-	branch := GetBranchFromRef(ref)
+	branch := source_control.GetBranchFromRef(ref)
 	if branch == "" {
 		return nil, fmt.Errorf("ref %s is not a branch", ref)
 	}
@@ -218,24 +387,58 @@ func (ghc *GitHubConnection) GetBranchControls(ctx context.Context, commit, ref
 	if err != nil {
 		return nil, err
 	}
+	// Classic branch protection can enforce the same controls as rulesets.
+	protection, err := ghc.getBranchProtection(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
 	// Compute the controls enforced.
 	continuityControl, err := ghc.computeContinuityControl(ctx, commit, branchRules, activity)
 	if err != nil {
 		return nil, fmt.Errorf("could not populate ContinuityControl: %w", err)
 	}
-	controlStatus.Controls.AddControl(continuityControl)
+	controlStatus.Controls.AddControl(combineControls(continuityControl, computeProtectionContinuityControl(protection, activity.Timestamp)))
 
 	reviewControl, err := ghc.computeReviewControl(ctx, branchRules.PullRequest)
 	if err != nil {
 		return nil, fmt.Errorf("could not populate ReviewControl: %w", err)
 	}
-	controlStatus.Controls.AddControl(reviewControl)
+	controlStatus.Controls.AddControl(combineControls(reviewControl, computeProtectionReviewControl(protection, activity.Timestamp)))
+
+	signedCommitsControl, err := ghc.computeRuleControl(ctx, slsa_types.SignedCommits, branchRules.RequiredSignatures, hasRequiredSignaturesRule)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate SignedCommitsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(combineControls(signedCommitsControl, computeProtectionSignedCommitsControl(protection, activity.Timestamp)))
+
+	linearHistoryControl, err := ghc.computeRuleControl(ctx, slsa_types.RequiredLinearHistory, branchRules.RequiredLinearHistory, hasRequiredLinearHistoryRule)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate LinearHistoryControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(combineControls(linearHistoryControl, computeProtectionLinearHistoryControl(protection, activity.Timestamp)))
+
+	var mergeQueueRules []*github.BranchRuleMetadata
+	for _, rule := range branchRules.MergeQueue {
+		mergeQueueRules = append(mergeQueueRules, &rule.BranchRuleMetadata)
+	}
+	mergeQueueControl, err := ghc.computeRuleControl(ctx, slsa_types.MergeQueue, mergeQueueRules, hasMergeQueueRule)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate MergeQueueControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(mergeQueueControl)
+
+	statusChecksControl, err := ghc.computeRequiredStatusChecksControl(ctx, branchRules.RequiredStatusChecks)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate RequiredStatusChecksControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(statusChecksControl)
 
 	allRulesets, _, err := ghc.Client().Repositories.GetAllRulesets(ctx, ghc.Owner(), ghc.Repo(), true)
 	if err != nil {
 		return nil, err
 	}
-	ImmutableTagsControl, err := ghc.computeImmutableTagsControl(ctx, commit, allRulesets, &activity.Timestamp)
+	// Branches don't have a tag to check, so only count rulesets that cover every tag.
+	ImmutableTagsControl, err := ghc.computeImmutableTagsControl(ctx, commit, allRulesets, &activity.Timestamp, appliesToAllTags)
 	if err != nil {
 		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
 	}
@@ -244,8 +447,8 @@ func (ghc *GitHubConnection) GetBranchControls(ctx context.Context, commit, ref
 	return &controlStatus, nil
 }
 
-func (ghc *GitHubConnection) GetTagControls(ctx context.Context, commit, ref string) (*GhControlStatus, error) {
-	controlStatus := GhControlStatus{
+func (ghc *GitHubConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	controlStatus := source_control.ControlStatus{
 		CommitPushTime: time.Now(),
 		Controls:       slsa_types.Controls{}}
 
@@ -253,7 +456,10 @@ func (ghc *GitHubConnection) GetTagControls(ctx context.Context, commit, ref str
 	if err != nil {
 		return nil, err
 	}
-	ImmutableTagsControl, err := ghc.computeImmutableTagsControl(ctx, commit, allRulesets, &controlStatus.CommitPushTime)
+	appliesToTag := func(ruleset *github.RepositoryRuleset) bool {
+		return appliesToRef(ruleset, ref)
+	}
+	ImmutableTagsControl, err := ghc.computeImmutableTagsControl(ctx, commit, allRulesets, &controlStatus.CommitPushTime, appliesToTag)
 	if err != nil {
 		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
 	}
@@ -0,0 +1,361 @@
+package gh_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+// Starts a stand-in for the GitHub API that serves 'responses' as JSON keyed by path.
+// A response may be a func that gets the request and returns the result to serve.
+func newTestGhConnection(t *testing.T, responses map[string]any) *GitHubConnection {
+	t.Helper()
+	server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{}, responses)
+
+	client := github.NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return NewGhConnectionWithClient("owner", "repo", "refs/heads/main", client)
+}
+
+// Serves 'pages' of activity, linking each to the next with an 'after' cursor.
+func pagedActivity(t *testing.T, pages [][]*activity) func(http.ResponseWriter, *http.Request) any {
+	return func(w http.ResponseWriter, r *http.Request) any {
+		query := r.URL.Query()
+		if query.Get("ref") != "refs/heads/main" {
+			t.Errorf("activity requested for ref %q, want refs/heads/main", query.Get("ref"))
+		}
+		page := 0
+		if after := query.Get("after"); after != "" {
+			fmt.Sscanf(after, "cursor%d", &page)
+		}
+		if page+1 < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/owner/repo/activity?after=cursor%d>; rel="next"`, r.Host, page+1))
+		}
+		return pages[page]
+	}
+}
+
+func TestCommitActivity(t *testing.T) {
+	now := time.Now()
+	filler := func(count int, at time.Time) []*activity {
+		var page []*activity
+		for i := 0; i < count; i++ {
+			page = append(page, &activity{ActivityType: "push", After: fmt.Sprintf("other%d", i), Ref: "refs/heads/main", Timestamp: at})
+		}
+		return page
+	}
+
+	tests := []struct {
+		name       string
+		pages      [][]*activity
+		lookback   time.Duration
+		expectedId int
+	}{
+		{
+			name: "on the first page",
+			pages: [][]*activity{
+				append(filler(5, now), &activity{Id: 1, ActivityType: "pr_merge", After: "abc123", Ref: "refs/heads/main", Timestamp: now}),
+			},
+			expectedId: 1,
+		},
+		{
+			name: "on a later page",
+			pages: [][]*activity{
+				filler(100, now),
+				filler(100, now.Add(-time.Hour)),
+				append(filler(3, now.Add(-2*time.Hour)), &activity{Id: 2, ActivityType: "push", After: "abc123", Ref: "refs/heads/main", Timestamp: now.Add(-2 * time.Hour)}),
+			},
+			expectedId: 2,
+		},
+		{
+			name: "outside the look-back window",
+			pages: [][]*activity{
+				filler(100, now),
+				{{Id: 3, ActivityType: "push", After: "abc123", Ref: "refs/heads/main", Timestamp: now.Add(-3 * time.Hour)}},
+			},
+			lookback: 2 * time.Hour,
+		},
+		{
+			name: "pushed to create the branch",
+			pages: [][]*activity{
+				{{Id: 5, ActivityType: "branch_creation", After: "abc123", Ref: "refs/heads/main", Timestamp: now}},
+			},
+			expectedId: 5,
+		},
+		{
+			name: "ignores other activity types",
+			pages: [][]*activity{
+				{{Id: 4, ActivityType: "branch_deletion", After: "abc123", Ref: "refs/heads/main", Timestamp: now}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, map[string]any{
+				"/repos/owner/repo/activity": pagedActivity(t, tt.pages),
+			}).WithActivityLookback(tt.lookback)
+
+			activity, err := ghc.commitActivity(context.Background(), "abc123", "refs/heads/main")
+			if tt.expectedId == 0 {
+				if err == nil {
+					t.Errorf("commitActivity() = %v, want error", activity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commitActivity() error = %v", err)
+			}
+			if activity.Id != tt.expectedId {
+				t.Errorf("commitActivity() found activity %d, want %d", activity.Id, tt.expectedId)
+			}
+		})
+	}
+}
+
+func TestGetDiscontinuities(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	from := now.Add(-2 * time.Hour)
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/activity": pagedActivity(t, [][]*activity{
+			{
+				// After 'to'.
+				{ActivityType: "force_push", Before: "d", After: "e", Ref: "refs/heads/main", Timestamp: now.Add(time.Minute)},
+				{ActivityType: "push", Before: "c", After: "d", Ref: "refs/heads/main", Timestamp: now},
+				{ActivityType: "force_push", Before: "b", After: "c", Ref: "refs/heads/main", Timestamp: now.Add(-time.Hour), Actor: actor{Login: "admin"}},
+			},
+			{
+				{ActivityType: "branch_creation", Before: "0000000000000000000000000000000000000000", After: "b", Ref: "refs/heads/main", Timestamp: now.Add(-90 * time.Minute), Actor: actor{Login: "admin"}},
+				{ActivityType: "branch_deletion", Before: "a", After: "0000000000000000000000000000000000000000", Ref: "refs/heads/main", Timestamp: now.Add(-100 * time.Minute), Actor: actor{Login: "admin"}},
+				// Before 'from'.
+				{ActivityType: "force_push", Before: "z", After: "a", Ref: "refs/heads/main", Timestamp: now.Add(-3 * time.Hour)},
+			},
+		}),
+	})
+
+	got, err := ghc.GetDiscontinuities(context.Background(), "refs/heads/main", from, now)
+	if err != nil {
+		t.Fatalf("GetDiscontinuities() error = %v", err)
+	}
+	expected := []slsa_types.Discontinuity{
+		{Type: slsa_types.DiscontinuityBranchDeletion, Actor: "admin", Time: now.Add(-100 * time.Minute), Before: "a", After: "0000000000000000000000000000000000000000"},
+		{Type: slsa_types.DiscontinuityBranchCreation, Actor: "admin", Time: now.Add(-90 * time.Minute), Before: "0000000000000000000000000000000000000000", After: "b"},
+		{Type: slsa_types.DiscontinuityForcePush, Actor: "admin", Time: now.Add(-time.Hour), Before: "b", After: "c"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("GetDiscontinuities() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestGetDiscontinuities_ActivityTooShort(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/activity": pagedActivity(t, [][]*activity{
+			{
+				{ActivityType: "push", Before: "b", After: "c", Ref: "refs/heads/main", Timestamp: now},
+				{ActivityType: "push", Before: "a", After: "b", Ref: "refs/heads/main", Timestamp: now.Add(-time.Hour)},
+			},
+		}),
+	})
+
+	got, err := ghc.GetDiscontinuities(context.Background(), "refs/heads/main", now.Add(-2*time.Hour), now)
+	if !errors.Is(err, source_control.ErrHistoryIncomplete) {
+		t.Errorf("GetDiscontinuities() = %+v, %v, want ErrHistoryIncomplete", got, err)
+	}
+}
+
+func TestActivityTimePeriod(t *testing.T) {
+	tests := []struct {
+		lookback time.Duration
+		expected string
+	}{
+		{time.Hour, "day"},
+		{24 * time.Hour, "day"},
+		{DefaultActivityLookback, "week"},
+		{30 * 24 * time.Hour, "month"},
+		{90 * 24 * time.Hour, "quarter"},
+		{200 * 24 * time.Hour, "year"},
+	}
+	for _, tt := range tests {
+		if got := activityTimePeriod(tt.lookback); got != tt.expected {
+			t.Errorf("activityTimePeriod(%v) = %q, want %q", tt.lookback, got, tt.expected)
+		}
+	}
+}
+
+func TestComputeRequiredStatusChecksControl(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	statusChecksRule := func(strict bool, checks ...map[string]any) []map[string]any {
+		return []map[string]any{{
+			"type":       "required_status_checks",
+			"parameters": map[string]any{"required_status_checks": checks, "strict_required_status_checks_policy": strict},
+		}}
+	}
+	build := map[string]any{"context": "build", "integration_id": 15368}
+	lint := map[string]any{"context": "lint"}
+	ruleset := func(id int64, updated time.Time, rules []map[string]any, bypassTeam int64) map[string]any {
+		return map[string]any{
+			"id":            id,
+			"enforcement":   "active",
+			"updated_at":    updated,
+			"rules":         rules,
+			"bypass_actors": []map[string]any{{"actor_type": "Team", "actor_id": bypassTeam, "bypass_mode": "always"}},
+		}
+	}
+	version := func(id int64, at time.Time, rules []map[string]any) map[string]any {
+		return map[string]any{"version_id": id, "updated_at": at, "state": map[string]any{"id": 1, "enforcement": "active", "rules": rules}}
+	}
+
+	tests := []struct {
+		name      string
+		responses map[string]any
+		rulesets  []int64
+		expected  *slsa_types.Control
+	}{
+		{
+			name: "no rulesets",
+		},
+		{
+			name: "checks from every ruleset",
+			responses: map[string]any{
+				"/repos/owner/repo/rulesets/1": ruleset(1, t1, statusChecksRule(true, build), 5),
+				"/repos/owner/repo/rulesets/2": ruleset(2, t2, statusChecksRule(false, lint), 6),
+			},
+			rulesets: []int64{1, 2},
+			expected: &slsa_types.Control{
+				Name:           slsa_types.RequiredStatusChecks,
+				Since:          t2,
+				RequiredChecks: []slsa_types.StatusCheck{{Context: "build", IntegrationId: 15368}, {Context: "lint"}},
+				StrictChecks:   true,
+				BypassActors: []slsa_types.BypassActor{
+					{ActorType: "Team", ActorId: 5, BypassMode: "always"},
+					{ActorType: "Team", ActorId: 6, BypassMode: "always"},
+				},
+			},
+		},
+		{
+			name: "loosened since the checks were required",
+			responses: map[string]any{
+				"/repos/owner/repo/rulesets/1":           ruleset(1, t2, statusChecksRule(false, build), 5),
+				"/repos/owner/repo/rulesets/1/history":   []map[string]any{{"version_id": 2, "updated_at": t2}, {"version_id": 1, "updated_at": t1}},
+				"/repos/owner/repo/rulesets/1/history/2": version(2, t2, statusChecksRule(false, build)),
+				"/repos/owner/repo/rulesets/1/history/1": version(1, t1, statusChecksRule(true, build, lint)),
+			},
+			rulesets: []int64{1},
+			expected: &slsa_types.Control{
+				Name:           slsa_types.RequiredStatusChecks,
+				Since:          t1,
+				RequiredChecks: []slsa_types.StatusCheck{{Context: "build", IntegrationId: 15368}},
+				BypassActors:   []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "always"}},
+			},
+		},
+		{
+			name: "check added since",
+			responses: map[string]any{
+				"/repos/owner/repo/rulesets/1":           ruleset(1, t2, statusChecksRule(false, build, lint), 5),
+				"/repos/owner/repo/rulesets/1/history":   []map[string]any{{"version_id": 2, "updated_at": t2}, {"version_id": 1, "updated_at": t1}},
+				"/repos/owner/repo/rulesets/1/history/2": version(2, t2, statusChecksRule(false, build, lint)),
+				"/repos/owner/repo/rulesets/1/history/1": version(1, t1, statusChecksRule(false, build)),
+			},
+			rulesets: []int64{1},
+			expected: &slsa_types.Control{
+				Name:           slsa_types.RequiredStatusChecks,
+				Since:          t2,
+				RequiredChecks: []slsa_types.StatusCheck{{Context: "build", IntegrationId: 15368}, {Context: "lint"}},
+				BypassActors:   []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "always"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, tt.responses)
+			var rules []*github.RequiredStatusChecksBranchRule
+			for _, id := range tt.rulesets {
+				rules = append(rules, &github.RequiredStatusChecksBranchRule{BranchRuleMetadata: github.BranchRuleMetadata{
+					RulesetSourceType: github.RulesetSourceTypeRepository,
+					RulesetSource:     "owner/repo",
+					RulesetID:         id,
+				}})
+			}
+			got, err := ghc.computeRequiredStatusChecksControl(context.Background(), rules)
+			if err != nil {
+				t.Fatalf("computeRequiredStatusChecksControl() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("computeRequiredStatusChecksControl() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestComputeRuleControl(t *testing.T) {
+	updated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/rulesets/1": map[string]any{
+			"id":          1,
+			"enforcement": "active",
+			"updated_at":  updated,
+			"rules": []map[string]any{
+				{"type": "required_linear_history"},
+				{"type": "merge_queue", "parameters": map[string]any{"merge_method": "SQUASH", "grouping_strategy": "ALLGREEN"}},
+			},
+		},
+		"/repos/owner/repo/rulesets/2": map[string]any{"id": 2, "enforcement": "disabled", "updated_at": updated},
+	})
+	rule := func(id int64) *github.BranchRuleMetadata {
+		return &github.BranchRuleMetadata{RulesetSourceType: github.RulesetSourceTypeRepository, RulesetSource: "owner/repo", RulesetID: id}
+	}
+
+	tests := []struct {
+		name      string
+		control   string
+		rules     []*github.BranchRuleMetadata
+		qualifies rulesetPredicate
+		expected  *slsa_types.Control
+	}{
+		{
+			name:      "linear history",
+			control:   slsa_types.RequiredLinearHistory,
+			rules:     []*github.BranchRuleMetadata{rule(1)},
+			qualifies: hasRequiredLinearHistoryRule,
+			expected:  &slsa_types.Control{Name: slsa_types.RequiredLinearHistory, Since: updated},
+		},
+		{
+			name:      "merge queue",
+			control:   slsa_types.MergeQueue,
+			rules:     []*github.BranchRuleMetadata{rule(1)},
+			qualifies: hasMergeQueueRule,
+			expected:  &slsa_types.Control{Name: slsa_types.MergeQueue, Since: updated},
+		},
+		{
+			name:      "ruleset not active",
+			control:   slsa_types.RequiredLinearHistory,
+			rules:     []*github.BranchRuleMetadata{rule(2)},
+			qualifies: hasRequiredLinearHistoryRule,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ghc.computeRuleControl(context.Background(), tt.control, tt.rules, tt.qualifies)
+			if err != nil {
+				t.Fatalf("computeRuleControl() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("computeRuleControl() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// Where GitHub looks for the CODEOWNERS file, in the order it looks.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Gets the CODEOWNERS file at 'commit' and its path, "" if there isn't one.
+func (ghc *GitHubConnection) getCodeowners(ctx context.Context, commit string) (string, source_control.Codeowners, error) {
+	for _, path := range codeownersPaths {
+		contents, _, resp, err := ghc.Client().Repositories.GetContents(
+			ctx, ghc.Owner(), ghc.Repo(), path, &github.RepositoryContentGetOptions{Ref: commit})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("could not get %s at %s: %w", path, commit, err)
+		}
+		content, err := contents.GetContent()
+		if err != nil {
+			return "", nil, fmt.Errorf("could not decode %s at %s: %w", path, commit, err)
+		}
+		return path, source_control.ParseCodeowners(content), nil
+	}
+	return "", nil, nil
+}
+
+// Lists the paths the pull request changed, including the old paths of renamed files.
+// GitHub only lists the first 3000 files, so this fails for larger pull requests rather
+// than miss any paths.
+func (ghc *GitHubConnection) listChangedPaths(ctx context.Context, pr *github.PullRequest) ([]string, error) {
+	number := pr.GetNumber()
+	opts := &github.ListOptions{PerPage: 100}
+	var paths []string
+	listed := 0
+	for {
+		files, resp, err := ghc.Client().PullRequests.ListFiles(ctx, ghc.Owner(), ghc.Repo(), number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not list files of pull request %d: %w", number, err)
+		}
+		listed += len(files)
+		for _, file := range files {
+			paths = append(paths, file.GetFilename())
+			if file.GetPreviousFilename() != "" {
+				paths = append(paths, file.GetPreviousFilename())
+			}
+		}
+		if resp.NextPage != 0 {
+			opts.Page = resp.NextPage
+			continue
+		}
+		if listed < pr.GetChangedFiles() {
+			return nil, fmt.Errorf("pull request %d changed %d files, but GitHub only lists %d of them", number, pr.GetChangedFiles(), listed)
+		}
+		return paths, nil
+	}
+}
+
+// Reports if 'login' is an active member of 'team' ('org/team').
+func (ghc *GitHubConnection) isTeamMember(ctx context.Context, team, login string) (bool, error) {
+	org, slug, ok := strings.Cut(team, "/")
+	if !ok {
+		return false, fmt.Errorf("invalid team %q", team)
+	}
+	membership, resp, err := ghc.Client().Teams.GetTeamMembershipBySlug(ctx, org, slug, login)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not get %s's membership of %s: %w", login, team, err)
+	}
+	return membership.GetState() == "active", nil
+}
+
+// Tells which code owners approved a pull request, remembering team memberships.
+type ownerApprovals struct {
+	ghc       *GitHubConnection
+	approvers []string
+	approved  map[string]bool
+}
+
+// Reports if any of 'owners' approved. Owners given by email can't be matched to the
+// approvers, so they never count.
+func (oa *ownerApprovals) anyApproved(ctx context.Context, owners []string) (bool, error) {
+	for _, owner := range owners {
+		if approved, ok := oa.approved[owner]; ok {
+			if approved {
+				return true, nil
+			}
+			continue
+		}
+
+		approved := false
+		name, isHandle := strings.CutPrefix(owner, "@")
+		switch {
+		case !isHandle:
+		case strings.Contains(name, "/"):
+			for _, approver := range oa.approvers {
+				member, err := oa.ghc.isTeamMember(ctx, name, approver)
+				if err != nil {
+					return false, err
+				}
+				if member {
+					approved = true
+					break
+				}
+			}
+		default:
+			approved = slices.ContainsFunc(oa.approvers, func(approver string) bool {
+				return strings.EqualFold(approver, name)
+			})
+		}
+		oa.approved[owner] = approved
+		if approved {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Checks whether the code owners of each file the pull request changed approved its head.
+// The owners are those in the CODEOWNERS file on the base branch, like GitHub, so the pull
+// request can't change who has to approve it.
+func (ghc *GitHubConnection) reviewCodeOwners(ctx context.Context, pr *github.PullRequest, approvals []slsa_types.Approval) (*slsa_types.CodeOwnerReview, error) {
+	file, codeowners, err := ghc.getCodeowners(ctx, pr.GetBase().GetSHA())
+	if err != nil {
+		return nil, err
+	}
+	paths, err := ghc.listChangedPaths(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	oa := &ownerApprovals{ghc: ghc, approved: map[string]bool{}}
+	for _, approval := range approvals {
+		if approval.Commit == pr.GetHead().GetSHA() {
+			oa.approvers = append(oa.approvers, approval.Reviewer)
+		}
+	}
+
+	review := &slsa_types.CodeOwnerReview{File: file}
+	for _, path := range paths {
+		owners := codeowners.OwnersOf(path)
+		if len(owners) == 0 {
+			review.UncoveredPaths = append(review.UncoveredPaths, path)
+			continue
+		}
+		approved, err := oa.anyApproved(ctx, owners)
+		if err != nil {
+			return nil, err
+		}
+		if !approved {
+			review.UnapprovedPaths = append(review.UnapprovedPaths, path)
+		}
+	}
+	return review, nil
+}
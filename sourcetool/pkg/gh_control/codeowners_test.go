@@ -0,0 +1,125 @@
+package gh_control
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestReviewCodeOwners(t *testing.T) {
+	codeownersFile := func(content string) map[string]any {
+		return map[string]any{"type": "file", "encoding": "base64", "content": base64.StdEncoding.EncodeToString([]byte(content))}
+	}
+	changedFiles := []map[string]any{
+		{"filename": "src/main.go"},
+		{"filename": "docs/guide.md", "previous_filename": "docs/old.md"},
+		{"filename": "LICENSE"},
+	}
+	notMember := func(w http.ResponseWriter, _ *http.Request) any {
+		w.WriteHeader(http.StatusNotFound)
+		return map[string]any{"message": "Not Found"}
+	}
+	codeowners := "src/ @org/devs\n*.md @writer docs@example.com\n"
+	pr := &github.PullRequest{
+		Number:       github.Ptr(7),
+		Base:         &github.PullRequestBranch{SHA: github.Ptr("base123")},
+		Head:         &github.PullRequestBranch{SHA: github.Ptr("head123")},
+		ChangedFiles: github.Ptr(len(changedFiles)),
+	}
+
+	tests := []struct {
+		name      string
+		approvers []string
+		// Approvers of a commit older than the head.
+		staleApprovers []string
+		responses      map[string]any
+		expected       *slsa_types.CodeOwnerReview
+	}{
+		{
+			name:      "no CODEOWNERS",
+			approvers: []string{"writer"},
+			responses: map[string]any{},
+			expected:  &slsa_types.CodeOwnerReview{UncoveredPaths: []string{"src/main.go", "docs/guide.md", "docs/old.md", "LICENSE"}},
+		},
+		{
+			name:      "owners approved",
+			approvers: []string{"Writer", "dev"},
+			responses: map[string]any{
+				"/repos/owner/repo/contents/.github/CODEOWNERS?ref=base123": codeownersFile(codeowners),
+				"/orgs/org/teams/devs/memberships/writer":                   notMember,
+				"/orgs/org/teams/devs/memberships/dev":                      map[string]any{"state": "active"},
+			},
+			expected: &slsa_types.CodeOwnerReview{File: ".github/CODEOWNERS", UncoveredPaths: []string{"LICENSE"}},
+		},
+		{
+			name:           "owner approved an older commit",
+			approvers:      []string{"writer"},
+			staleApprovers: []string{"dev"},
+			responses: map[string]any{
+				"/repos/owner/repo/contents/.github/CODEOWNERS?ref=base123": codeownersFile(codeowners),
+				"/orgs/org/teams/devs/memberships/writer":                   notMember,
+			},
+			expected: &slsa_types.CodeOwnerReview{File: ".github/CODEOWNERS", UncoveredPaths: []string{"LICENSE"}, UnapprovedPaths: []string{"src/main.go"}},
+		},
+		{
+			name:      "CODEOWNERS only added by the pull request",
+			approvers: []string{"writer"},
+			responses: map[string]any{
+				"/repos/owner/repo/contents/.github/CODEOWNERS?ref=head123": codeownersFile(codeowners),
+			},
+			expected: &slsa_types.CodeOwnerReview{UncoveredPaths: []string{"src/main.go", "docs/guide.md", "docs/old.md", "LICENSE"}},
+		},
+		{
+			name:      "team owner didn't approve",
+			approvers: []string{"writer"},
+			responses: map[string]any{
+				"/repos/owner/repo/contents/docs/CODEOWNERS?ref=base123": codeownersFile(codeowners),
+				"/orgs/org/teams/devs/memberships/writer":                notMember,
+			},
+			expected: &slsa_types.CodeOwnerReview{File: "docs/CODEOWNERS", UncoveredPaths: []string{"LICENSE"}, UnapprovedPaths: []string{"src/main.go"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.responses["/repos/owner/repo/pulls/7/files"] = changedFiles
+			ghc := newTestGhConnection(t, tt.responses)
+			var approvals []slsa_types.Approval
+			for _, approver := range tt.approvers {
+				approvals = append(approvals, slsa_types.Approval{Reviewer: approver, Commit: "head123"})
+			}
+			for _, approver := range tt.staleApprovers {
+				approvals = append(approvals, slsa_types.Approval{Reviewer: approver, Commit: "older123"})
+			}
+			got, err := ghc.reviewCodeOwners(context.Background(), pr, approvals)
+			if err != nil {
+				t.Fatalf("reviewCodeOwners() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("reviewCodeOwners() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// GitHub only lists the first 3000 files, the rest could be owned by anyone.
+func TestReviewCodeOwners_TooManyFiles(t *testing.T) {
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/pulls/7/files": []map[string]any{{"filename": "src/main.go"}},
+	})
+	pr := &github.PullRequest{
+		Number:       github.Ptr(7),
+		Base:         &github.PullRequestBranch{SHA: github.Ptr("base123")},
+		Head:         &github.PullRequestBranch{SHA: github.Ptr("head123")},
+		ChangedFiles: github.Ptr(3500),
+	}
+	_, err := ghc.reviewCodeOwners(context.Background(), pr, []slsa_types.Approval{{Reviewer: "writer", Commit: "head123"}})
+	if err == nil {
+		t.Errorf("reviewCodeOwners() succeeded, want an error when GitHub doesn't list every changed file")
+	}
+}
@@ -0,0 +1,75 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.CommitSignatureVerifier = (*GitHubConnection)(nil)
+
+// Gets the signature on 'commit' as verified by GitHub, nil if it isn't signed.
+// GitHub verifies GPG and SSH signatures against the committer's keys and S/MIME
+// signatures against trusted CAs. It doesn't verify gitsign's signatures, so we
+// verify those against Sigstore ourselves.
+func (ghc *GitHubConnection) GetCommitSignature(ctx context.Context, commit string) (*slsa_types.CommitSignature, error) {
+	gitCommit, _, err := ghc.Client().Git.GetCommit(ctx, ghc.Owner(), ghc.Repo(), commit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit %s: %w", commit, err)
+	}
+	verification := gitCommit.GetVerification()
+	if verification == nil || verification.GetSignature() == "" {
+		return nil, nil
+	}
+
+	signature := &slsa_types.CommitSignature{
+		Verified: verification.GetVerified(),
+		Reason:   verification.GetReason(),
+	}
+	signature.Type, signature.Signer, err = source_control.IdentifySigner(verification.GetSignature())
+	if err != nil {
+		// We still know it's signed and whether GitHub could verify it.
+		log.Printf("could not identify who signed commit %s: %v", commit, err)
+	}
+	if !signature.Verified && signature.Type == source_control.SignatureTypeX509 {
+		ghc.verifyGitsignSignature(commit, verification, signature)
+	}
+	return signature, nil
+}
+
+// Marks 'signature' verified if it's a gitsign signature that verifies against Sigstore.
+// Otherwise it's left as GitHub found it, since it may be an S/MIME signature GitHub
+// didn't trust.
+func (ghc *GitHubConnection) verifyGitsignSignature(commit string, verification *github.SignatureVerification, signature *slsa_types.CommitSignature) {
+	trustedMaterial, err := ghc.sigstoreTrustedMaterial()
+	if err != nil {
+		log.Printf("could not verify x509 signature on commit %s: %v", commit, err)
+		return
+	}
+	signer, err := source_control.VerifyGitsignSignature(verification.GetSignature(), verification.GetPayload(), trustedMaterial)
+	if err != nil {
+		log.Printf("could not verify x509 signature on commit %s as a gitsign signature: %v", commit, err)
+		return
+	}
+	signature.Verified = true
+	signature.Reason = "valid"
+	signature.Signer = signer
+}
+
+func (ghc *GitHubConnection) sigstoreTrustedMaterial() (root.TrustedMaterial, error) {
+	ghc.sigstoreMu.Lock()
+	defer ghc.sigstoreMu.Unlock()
+	if ghc.trustedMaterial == nil {
+		trustedRoot, err := root.FetchTrustedRoot()
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch Sigstore's trusted root: %w", err)
+		}
+		ghc.trustedMaterial = trustedRoot
+	}
+	return ghc.trustedMaterial, nil
+}
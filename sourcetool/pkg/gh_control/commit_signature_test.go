@@ -0,0 +1,82 @@
+package gh_control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+func TestGetCommitSignature(t *testing.T) {
+	sigstore, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatalf("could not create virtual Sigstore: %v", err)
+	}
+	const payload = "tree abc\nauthor Dev <dev@example.com> 1700000000 +0000\n\nchange\n"
+
+	tests := []struct {
+		name     string
+		commit   map[string]any
+		expected *slsa_types.CommitSignature
+	}{
+		{
+			name:   "unsigned",
+			commit: map[string]any{"sha": "abc123", "verification": map[string]any{"verified": false, "reason": "unsigned"}},
+		},
+		{
+			name: "unknown key",
+			commit: map[string]any{"sha": "abc123", "verification": map[string]any{
+				"verified":  false,
+				"reason":    "unknown_key",
+				"signature": "-----BEGIN PGP SIGNATURE-----\n\nnot really\n-----END PGP SIGNATURE-----",
+			}},
+			expected: &slsa_types.CommitSignature{Type: "gpg", Verified: false, Reason: "unknown_key"},
+		},
+		{
+			name: "gitsign",
+			commit: map[string]any{"sha": "abc123", "verification": map[string]any{
+				"verified":  false,
+				"reason":    "unknown_signature_type",
+				"signature": testsupport.GitsignSignature(t, sigstore, "dev@example.com", payload, true),
+				"payload":   payload,
+			}},
+			expected: &slsa_types.CommitSignature{Type: "x509", Verified: true, Reason: "valid", Signer: "dev@example.com"},
+		},
+		{
+			name: "gitsign not in Rekor",
+			commit: map[string]any{"sha": "abc123", "verification": map[string]any{
+				"verified":  false,
+				"reason":    "unknown_signature_type",
+				"signature": testsupport.GitsignSignature(t, sigstore, "dev@example.com", payload, false),
+				"payload":   payload,
+			}},
+			expected: &slsa_types.CommitSignature{Type: "x509", Verified: false, Reason: "unknown_signature_type", Signer: "dev@example.com"},
+		},
+		{
+			name: "corrupt x509",
+			commit: map[string]any{"sha": "abc123", "verification": map[string]any{
+				"verified":  false,
+				"reason":    "unknown_signature_type",
+				"signature": "-----BEGIN SIGNED MESSAGE-----\nMIIB\n-----END SIGNED MESSAGE-----",
+			}},
+			expected: &slsa_types.CommitSignature{Type: "x509", Verified: false, Reason: "unknown_signature_type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, map[string]any{"/repos/owner/repo/git/commits/abc123": tt.commit}).
+				WithSigstoreTrustedMaterial(sigstore)
+			got, err := ghc.GetCommitSignature(context.Background(), "abc123")
+			if err != nil {
+				t.Fatalf("GetCommitSignature() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetCommitSignature() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -3,14 +3,50 @@ package gh_control
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v69/github"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 )
 
+const (
+	// The GitHub repo that hosts source policies.
+	SourcePolicyRepoOwner = "slsa-framework"
+	SourcePolicyRepo      = "slsa-source-poc"
+)
+
+// How far back we look for the activity that pushed a commit by default.
+const DefaultActivityLookback = 7 * 24 * time.Hour
+
+// Where repos on github.com are browsed, used to identify them.
+const defaultWebUrl = "https://github.com"
+
+var _ source_control.SourceControlPlatform = (*GitHubConnection)(nil)
+
 // Manages a connection to a GitHub repository.
 type GitHubConnection struct {
 	client           *github.Client
+	webUrl           string
 	owner, repo, ref string
+	// The repo policies are fetched from.
+	policyRepoOwner, policyRepo string
+	// How far back to look for the activity that pushed a commit.
+	activityLookback time.Duration
+
+	// Rulesets fetched so far, by id. Many rules come from the same ruleset.
+	rulesetsMu sync.Mutex
+	rulesets   map[rulesetKey]*github.RepositoryRuleset
+	// Ruleset histories and versions fetched so far, by url. Versions never change.
+	rulesetHistories map[string][]*rulesetVersion
+	rulesetVersions  map[string]*github.RepositoryRuleset
+
+	// What gitsign signatures are verified against, Sigstore's public good instance
+	// (fetched when first needed) unless set.
+	sigstoreMu      sync.Mutex
+	trustedMaterial root.TrustedMaterial
 }
 
 func NewGhConnection(owner, repo, ref string) *GitHubConnection {
@@ -19,10 +55,48 @@ func NewGhConnection(owner, repo, ref string) *GitHubConnection {
 
 func NewGhConnectionWithClient(owner, repo, ref string, client *github.Client) *GitHubConnection {
 	return &GitHubConnection{
-		client: client,
-		owner:  owner,
-		repo:   repo,
-		ref:    ref}
+		client:           client,
+		webUrl:           defaultWebUrl,
+		owner:            owner,
+		repo:             repo,
+		ref:              ref,
+		policyRepoOwner:  SourcePolicyRepoOwner,
+		policyRepo:       SourcePolicyRepo,
+		activityLookback: DefaultActivityLookback,
+		rulesets:         map[rulesetKey]*github.RepositoryRuleset{},
+		rulesetHistories: map[string][]*rulesetVersion{},
+		rulesetVersions:  map[string]*github.RepositoryRuleset{}}
+}
+
+// Creates a connection to a repo on GitHub Enterprise Server.
+// baseUrl is the url of the instance (or its API, e.g. https://ghes.example.com/api/v3/),
+// uploadUrl may be empty if it's the same as baseUrl.
+// httpClient may be nil to use the default client.
+func NewGhEnterpriseConnection(baseUrl, uploadUrl, owner, repo, ref string, httpClient *http.Client) (*GitHubConnection, error) {
+	if uploadUrl == "" {
+		uploadUrl = baseUrl
+	}
+	client, err := github.NewClient(httpClient).WithEnterpriseURLs(baseUrl, uploadUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub Enterprise url %s: %w", baseUrl, err)
+	}
+	ghc := NewGhConnectionWithClient(owner, repo, ref, client)
+	// The API is served from /api/v3/ on the same host as the web UI.
+	ghc.webUrl = fmt.Sprintf("%s://%s", client.BaseURL.Scheme, client.BaseURL.Host)
+	return ghc, nil
+}
+
+// Fetches policies from owner/repo rather than the default policy repo.
+// If either is the empty string this is a no-op.
+func (ghc *GitHubConnection) WithPolicyRepo(owner, repo string) *GitHubConnection {
+	if owner != "" && repo != "" {
+		ghc.policyRepoOwner = owner
+		ghc.policyRepo = repo
+		if transport, ok := ghc.client.Client().Transport.(*AppTransport); ok {
+			transport.WithPolicyRepo(owner, repo)
+		}
+	}
+	return ghc
 }
 
 func (ghc *GitHubConnection) Client() *github.Client {
@@ -50,29 +124,39 @@ func (ghc *GitHubConnection) WithAuthToken(token string) *GitHubConnection {
 	return ghc
 }
 
+// Looks this far back for the activity that pushed a commit.
+// If lookback isn't positive this is a no-op.
+func (ghc *GitHubConnection) WithActivityLookback(lookback time.Duration) *GitHubConnection {
+	if lookback > 0 {
+		ghc.activityLookback = lookback
+	}
+	return ghc
+}
+
+// Verifies gitsign signatures against 'trustedMaterial' rather than Sigstore's public
+// good instance, e.g. for a private Sigstore deployment.
+func (ghc *GitHubConnection) WithSigstoreTrustedMaterial(trustedMaterial root.TrustedMaterial) *GitHubConnection {
+	ghc.trustedMaterial = trustedMaterial
+	return ghc
+}
+
 // Returns the URI of the repo this connection tracks.
 func (ghc *GitHubConnection) GetRepoUri() string {
-	return fmt.Sprintf("https://github.com/%s/%s", ghc.Owner(), ghc.Repo())
+	return fmt.Sprintf("%s/%s/%s", ghc.webUrl, ghc.Owner(), ghc.Repo())
 }
 
-// Gets the previous commit to 'sha' if it has one.
-// If there are more than one parents this fails with an error.
-// (This tool generally operates in an environment of linear history)
-func (ghc *GitHubConnection) GetPriorCommit(ctx context.Context, sha string) (string, error) {
+// Gets the parents of 'sha', in order.
+func (ghc *GitHubConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
 	commit, _, err := ghc.Client().Git.GetCommit(ctx, ghc.Owner(), ghc.Repo(), sha)
 	if err != nil {
-		return "", fmt.Errorf("cannot get commit data for %s: %w", sha, err)
-	}
-
-	if len(commit.Parents) == 0 {
-		return "", fmt.Errorf("there is no commit earlier than %s, that isn't yet supported", sha)
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
 	}
 
-	if len(commit.Parents) > 1 {
-		return "", fmt.Errorf("commit %s has more than one parent (%v), which is not supported", sha, commit.Parents)
+	var parents []string
+	for _, parent := range commit.Parents {
+		parents = append(parents, parent.GetSHA())
 	}
-
-	return *commit.Parents[0].SHA, nil
+	return parents, nil
 }
 
 func (ghc *GitHubConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
@@ -82,3 +166,21 @@ func (ghc *GitHubConnection) GetLatestCommit(ctx context.Context, targetBranch s
 	}
 	return *branch.Commit.SHA, nil
 }
+
+// Gets the policy at 'path' from the policy repo.
+// Returns "" if the policy does not exist.
+func (ghc *GitHubConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	policyContents, _, resp, err := ghc.Client().Repositories.GetContents(ctx, ghc.policyRepoOwner, ghc.policyRepo, path, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err := policyContents.GetContent()
+	if err != nil {
+		return "", "", err
+	}
+	return content, policyContents.GetHTMLURL(), nil
+}
@@ -0,0 +1,95 @@
+package gh_control
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// Starts a stand-in for a GitHub Enterprise Server API using a self-signed
+// certificate and returns a connection that trusts it through a CA bundle.
+func newTestEnterpriseConnection(t *testing.T, handler http.HandlerFunc) (*GitHubConnection, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caBundle, certPem, 0644); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+	httpClient, err := source_control.NewHttpClient(caBundle)
+	if err != nil {
+		t.Fatalf("NewHttpClient() error = %v", err)
+	}
+
+	ghc, err := NewGhEnterpriseConnection(server.URL, "", "owner", "repo", "refs/heads/main", httpClient)
+	if err != nil {
+		t.Fatalf("NewGhEnterpriseConnection() error = %v", err)
+	}
+	return ghc, server
+}
+
+func TestEnterpriseGetPolicyFile(t *testing.T) {
+	ghc, server := newTestEnterpriseConnection(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/policy-org/policies/contents/policy/path.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"type":     "file",
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte("{}")),
+			"html_url": "https://ghes.example.com/policy-org/policies/blob/main/policy/path.json",
+		})
+	})
+	ghc = ghc.WithPolicyRepo("policy-org", "policies")
+
+	if uri := ghc.GetRepoUri(); uri != server.URL+"/owner/repo" {
+		t.Errorf("GetRepoUri() = %q, want %q", uri, server.URL+"/owner/repo")
+	}
+
+	contents, policyUri, err := ghc.GetPolicyFile(context.Background(), "policy/path.json")
+	if err != nil {
+		t.Fatalf("GetPolicyFile() error = %v", err)
+	}
+	if contents != "{}" {
+		t.Errorf("GetPolicyFile() contents = %q, want %q", contents, "{}")
+	}
+	if policyUri != "https://ghes.example.com/policy-org/policies/blob/main/policy/path.json" {
+		t.Errorf("GetPolicyFile() uri = %q", policyUri)
+	}
+
+	contents, _, err = ghc.GetPolicyFile(context.Background(), "policy/missing.json")
+	if err != nil || contents != "" {
+		t.Errorf("GetPolicyFile() = %q, %v, want no policy", contents, err)
+	}
+}
+
+func TestEnterpriseUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	ghc, err := NewGhEnterpriseConnection(server.URL, "", "owner", "repo", "refs/heads/main", nil)
+	if err != nil {
+		t.Fatalf("NewGhEnterpriseConnection() error = %v", err)
+	}
+	if _, _, err := ghc.GetPolicyFile(context.Background(), "policy/path.json"); err == nil {
+		t.Errorf("GetPolicyFile() error = nil, want certificate error without the CA bundle")
+	}
+}
+
+func TestGetRepoUri(t *testing.T) {
+	ghc := NewGhConnection("owner", "repo", "refs/heads/main")
+	if uri := ghc.GetRepoUri(); uri != "https://github.com/owner/repo" {
+		t.Errorf("GetRepoUri() = %q, want %q", uri, "https://github.com/owner/repo")
+	}
+}
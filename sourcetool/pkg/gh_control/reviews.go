@@ -0,0 +1,176 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.ReviewEvidenceProvider = (*GitHubConnection)(nil)
+
+// Gets the pull request that was merged as 'commit', nil if it wasn't merged from one.
+func (ghc *GitHubConnection) mergedPullRequest(ctx context.Context, commit string) (*github.PullRequest, error) {
+	prs, _, err := ghc.Client().PullRequests.ListPullRequestsWithCommit(ctx, ghc.Owner(), ghc.Repo(), commit, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not list pull requests for %s: %w", commit, err)
+	}
+	for _, pr := range prs {
+		if pr.MergedAt != nil && pr.GetMergeCommitSHA() == commit {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// Lists all the reviews of the pull request, oldest first.
+func (ghc *GitHubConnection) listReviews(ctx context.Context, number int) ([]*github.PullRequestReview, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var reviews []*github.PullRequestReview
+	for {
+		page, resp, err := ghc.Client().PullRequests.ListReviews(ctx, ghc.Owner(), ghc.Repo(), number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not list reviews of pull request %d: %w", number, err)
+		}
+		reviews = append(reviews, page...)
+		if resp.NextPage == 0 {
+			return reviews, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// Lists the authors of the commits in the pull request, by both their login (if the commit
+// is linked to a GitHub user) and the email in the commit.
+// GitHub only lists the first 250 commits, so this fails for larger pull requests rather
+// than miss any authors.
+func (ghc *GitHubConnection) listCommitAuthors(ctx context.Context, pr *github.PullRequest) ([]string, error) {
+	number := pr.GetNumber()
+	opts := &github.ListOptions{PerPage: 100}
+	var authors []string
+	listed := 0
+	for {
+		commits, resp, err := ghc.Client().PullRequests.ListCommits(ctx, ghc.Owner(), ghc.Repo(), number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not list commits of pull request %d: %w", number, err)
+		}
+		listed += len(commits)
+		for _, commit := range commits {
+			for _, author := range []string{commit.GetAuthor().GetLogin(), commit.GetCommit().GetAuthor().GetEmail()} {
+				if author != "" && !slices.Contains(authors, author) {
+					authors = append(authors, author)
+				}
+			}
+		}
+		if resp.NextPage != 0 {
+			opts.Page = resp.NextPage
+			continue
+		}
+		if listed < pr.GetCommits() {
+			return nil, fmt.Errorf("pull request %d has %d commits, but GitHub only lists %d of them", number, pr.GetCommits(), listed)
+		}
+		return authors, nil
+	}
+}
+
+// Returns the approvals that still stand, i.e. the reviewer didn't later request changes
+// and the approval wasn't dismissed.
+func standingApprovals(reviews []*github.PullRequestReview) []slsa_types.Approval {
+	latest := map[string]*github.PullRequestReview{}
+	var reviewers []string
+	for _, review := range reviews {
+		switch review.GetState() {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+		default:
+			// Comments don't change whether someone approved.
+			continue
+		}
+		reviewer := review.GetUser().GetLogin()
+		if _, ok := latest[reviewer]; !ok {
+			reviewers = append(reviewers, reviewer)
+		}
+		latest[reviewer] = review
+	}
+
+	var approvals []slsa_types.Approval
+	for _, reviewer := range reviewers {
+		review := latest[reviewer]
+		if review.GetState() != "APPROVED" {
+			continue
+		}
+		approvals = append(approvals, slsa_types.Approval{
+			Reviewer: reviewer,
+			Commit:   review.GetCommitID(),
+			Time:     review.GetSubmittedAt().Time,
+		})
+	}
+	return approvals
+}
+
+// Gets the emails GitHub knows belong to 'login': their public email, if any, and the
+// noreply emails GitHub uses for their commits.
+func (ghc *GitHubConnection) userEmails(ctx context.Context, login string) ([]string, error) {
+	user, _, err := ghc.Client().Users.Get(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user %s: %w", login, err)
+	}
+	emails := []string{
+		fmt.Sprintf("%d+%s@users.noreply.github.com", user.GetID(), login),
+		fmt.Sprintf("%s@users.noreply.github.com", login),
+	}
+	if email := user.GetEmail(); email != "" {
+		emails = append(emails, email)
+	}
+	return emails, nil
+}
+
+// Gets the evidence of how the pull request merged as 'commit' was reviewed, nil if it
+// wasn't merged from a pull request.
+func (ghc *GitHubConnection) GetReviewEvidence(ctx context.Context, commit string) (*slsa_types.ReviewEvidence, error) {
+	pr, err := ghc.mergedPullRequest(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, nil
+	}
+	number := pr.GetNumber()
+	// Pull requests are listed without how many commits and files they have.
+	pr, _, err = ghc.Client().PullRequests.Get(ctx, ghc.Owner(), ghc.Repo(), number)
+	if err != nil {
+		return nil, fmt.Errorf("could not get pull request %d: %w", number, err)
+	}
+
+	reviews, err := ghc.listReviews(ctx, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	commitAuthors, err := ghc.listCommitAuthors(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	approvals := standingApprovals(reviews)
+	for i := range approvals {
+		approvals[i].ReviewerEmails, err = ghc.userEmails(ctx, approvals[i].Reviewer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	codeOwners, err := ghc.reviewCodeOwners(ctx, pr, approvals)
+	if err != nil {
+		return nil, err
+	}
+
+	return &slsa_types.ReviewEvidence{
+		PullRequest:   pr.GetHTMLURL(),
+		Head:          pr.GetHead().GetSHA(),
+		Author:        pr.GetUser().GetLogin(),
+		CommitAuthors: commitAuthors,
+		Approvals:     approvals,
+		CodeOwners:    codeOwners,
+	}, nil
+}
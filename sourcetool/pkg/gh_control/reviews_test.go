@@ -0,0 +1,99 @@
+package gh_control
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestGetReviewEvidence(t *testing.T) {
+	approvedAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	review := func(login, state, commit string, submitted time.Time) map[string]any {
+		return map[string]any{"user": map[string]any{"login": login}, "state": state, "commit_id": commit, "submitted_at": submitted}
+	}
+	mergedPr := map[string]any{"number": 7, "html_url": "https://github.com/owner/repo/pull/7", "user": map[string]any{"login": "author"}, "merged_at": "2025-01-03T00:00:00Z", "merge_commit_sha": "abc123", "head": map[string]any{"sha": "head789"}}
+	// Only the full pull request says how many commits and files it has.
+	fullPr := func(commits int) map[string]any {
+		pr := maps.Clone(mergedPr)
+		pr["commits"] = commits
+		pr["changed_files"] = 1
+		return pr
+	}
+	commits := []map[string]any{
+		{"sha": "head123", "author": map[string]any{"login": "author"}, "commit": map[string]any{"author": map[string]any{"email": "author@example.com"}}},
+		{"sha": "head456", "author": map[string]any{"login": "author"}},
+		// Not linked to a GitHub user.
+		{"sha": "head789", "commit": map[string]any{"author": map[string]any{"email": "dev@example.com"}}},
+	}
+
+	tests := []struct {
+		name        string
+		responses   map[string]any
+		expected    *slsa_types.ReviewEvidence
+		expectError bool
+	}{
+		{
+			name:      "pushed directly",
+			responses: map[string]any{"/repos/owner/repo/commits/abc123/pulls": []map[string]any{}},
+		},
+		{
+			name: "merged from a pull request",
+			responses: map[string]any{
+				"/repos/owner/repo/commits/abc123/pulls": []map[string]any{mergedPr},
+				"/repos/owner/repo/pulls/7":              fullPr(3),
+				"/repos/owner/repo/pulls/7/reviews": []map[string]any{
+					review("reviewer", "APPROVED", "head123", approvedAt),
+					// Comments don't undo approvals, requesting changes does.
+					review("reviewer", "COMMENTED", "head456", approvedAt.Add(time.Hour)),
+					review("other", "APPROVED", "head123", approvedAt),
+					review("other", "CHANGES_REQUESTED", "head456", approvedAt.Add(time.Hour)),
+					review("dismissed", "DISMISSED", "head123", approvedAt),
+				},
+				"/repos/owner/repo/pulls/7/commits": commits,
+				"/repos/owner/repo/pulls/7/files":   []map[string]any{{"filename": "main.go"}},
+				"/users/reviewer":                   map[string]any{"login": "reviewer", "id": 5, "email": "reviewer@example.com"},
+			},
+			expected: &slsa_types.ReviewEvidence{
+				PullRequest:   "https://github.com/owner/repo/pull/7",
+				Head:          "head789",
+				Author:        "author",
+				CommitAuthors: []string{"author", "author@example.com", "dev@example.com"},
+				Approvals: []slsa_types.Approval{{
+					Reviewer:       "reviewer",
+					ReviewerEmails: []string{"5+reviewer@users.noreply.github.com", "reviewer@users.noreply.github.com", "reviewer@example.com"},
+					Commit:         "head123",
+					Time:           approvedAt,
+				}},
+				CodeOwners: &slsa_types.CodeOwnerReview{UncoveredPaths: []string{"main.go"}},
+			},
+		},
+		{
+			// GitHub only lists the first 250 commits, the rest could be by anyone.
+			name: "too many commits to list",
+			responses: map[string]any{
+				"/repos/owner/repo/commits/abc123/pulls": []map[string]any{mergedPr},
+				"/repos/owner/repo/pulls/7":              fullPr(300),
+				"/repos/owner/repo/pulls/7/reviews":      []map[string]any{},
+				"/repos/owner/repo/pulls/7/commits":      commits,
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, tt.responses)
+			got, err := ghc.GetReviewEvidence(context.Background(), "abc123")
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetReviewEvidence() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetReviewEvidence() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
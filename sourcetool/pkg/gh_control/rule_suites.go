@@ -0,0 +1,179 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.BypassDetector = (*GitHubConnection)(nil)
+
+// GitHub only keeps rule suites for this long.
+const ruleSuiteRetention = 30 * 24 * time.Hour
+
+// Who bypassed the rules when we can't tell if they were, e.g. because the rule suites expired.
+const unknownBypassActor = "unknown"
+
+// The evaluation of the rulesets that applied to a push.
+type ruleSuite struct {
+	Id        int64     `json:"id"`
+	ActorName string    `json:"actor_name"`
+	AfterSha  string    `json:"after_sha"`
+	Ref       string    `json:"ref"`
+	PushedAt  time.Time `json:"pushed_at"`
+	// 'pass', 'fail' or 'bypass'.
+	Result string `json:"result"`
+	// Only included when getting a single rule suite.
+	RuleEvaluations []ruleEvaluation `json:"rule_evaluations"`
+}
+
+type ruleEvaluation struct {
+	RuleType    string `json:"rule_type"`
+	Enforcement string `json:"enforcement"`
+	Result      string `json:"result"`
+}
+
+// The controls each type of branch rule enforces.
+var branchRuleControls = map[string]string{
+	"deletion":                slsa_types.ContinuityEnforced,
+	"non_fast_forward":        slsa_types.ContinuityEnforced,
+	"pull_request":            slsa_types.ReviewEnforced,
+	"required_status_checks":  slsa_types.RequiredStatusChecks,
+	"required_signatures":     slsa_types.SignedCommits,
+	"required_linear_history": slsa_types.RequiredLinearHistory,
+	"merge_queue":             slsa_types.MergeQueue,
+}
+
+// The controls each type of tag rule enforces.
+var tagRuleControls = map[string]string{
+	"update":           slsa_types.ImmutableTags,
+	"deletion":         slsa_types.ImmutableTags,
+	"non_fast_forward": slsa_types.ImmutableTags,
+}
+
+// Returns the smallest rule suite time_period that covers 'window'.
+func ruleSuiteTimePeriod(window time.Duration) string {
+	switch {
+	case window <= time.Hour:
+		return "hour"
+	case window <= 24*time.Hour:
+		return "day"
+	case window <= 7*24*time.Hour:
+		return "week"
+	default:
+		return "month"
+	}
+}
+
+// Lists the rule suites for pushes to 'ref' that bypassed the rules since 'from'.
+func (ghc *GitHubConnection) bypassedRuleSuites(ctx context.Context, ref string, from time.Time) ([]*ruleSuite, error) {
+	window := time.Since(from)
+	query := url.Values{
+		"ref":               {ref},
+		"time_period":       {ruleSuiteTimePeriod(window)},
+		"rule_suite_result": {"bypass"},
+		"per_page":          {"100"},
+	}
+
+	var suites []*ruleSuite
+	for {
+		reqUrl := fmt.Sprintf("repos/%s/%s/rulesets/rule-suites?%s", ghc.Owner(), ghc.Repo(), query.Encode())
+		req, err := ghc.Client().NewRequest("GET", reqUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []*ruleSuite
+		resp, err := ghc.Client().Do(ctx, req, &page)
+		if err != nil {
+			return nil, fmt.Errorf("could not list rule suites for %s: %w", ref, err)
+		}
+		suites = append(suites, page...)
+
+		if resp.NextPage == 0 {
+			return suites, nil
+		}
+		query.Set("page", strconv.Itoa(resp.NextPage))
+	}
+}
+
+// Gets the rule suite with 'id' including how each rule was evaluated.
+func (ghc *GitHubConnection) getRuleSuite(ctx context.Context, id int64) (*ruleSuite, error) {
+	req, err := ghc.Client().NewRequest("GET", fmt.Sprintf("repos/%s/%s/rulesets/rule-suites/%d", ghc.Owner(), ghc.Repo(), id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var suite ruleSuite
+	if _, err := ghc.Client().Do(ctx, req, &suite); err != nil {
+		return nil, fmt.Errorf("could not get rule suite %d: %w", id, err)
+	}
+	return &suite, nil
+}
+
+// Gets the times the rules enforcing controls on 'ref' were bypassed between 'from' and 'to'
+// according to GitHub's rule suites (aka rule insights).
+func (ghc *GitHubConnection) GetControlBypasses(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.ControlBypass, error) {
+	ruleControls := branchRuleControls
+	if strings.HasPrefix(ref, "refs/tags/") {
+		ruleControls = tagRuleControls
+	}
+
+	var bypasses []slsa_types.ControlBypass
+	// Rule suites older than GitHub keeps may have recorded bypasses we can't see, so as
+	// far as we can tell every control was bypassed just before the oldest we can.
+	if retained := time.Now().Add(-ruleSuiteRetention); from.Before(retained) {
+		log.Printf("GitHub only keeps rule suites for %v, cannot check for bypasses on %s before %v", ruleSuiteRetention, ref, retained)
+		from = retained
+		var controls []string
+		for _, control := range ruleControls {
+			if !slices.Contains(controls, control) {
+				controls = append(controls, control)
+			}
+		}
+		slices.Sort(controls)
+		for _, control := range controls {
+			bypasses = append(bypasses, slsa_types.ControlBypass{Control: control, Actor: unknownBypassActor, Time: retained})
+		}
+	}
+
+	suites, err := ghc.bypassedRuleSuites(ctx, ref, from)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, suite := range suites {
+		if suite.Result != "bypass" || suite.Ref != ref || suite.PushedAt.Before(from) || suite.PushedAt.After(to) {
+			continue
+		}
+
+		// Only the list of rule suites is filtered by result, we need the details
+		// to see which rules were bypassed.
+		detailed, err := ghc.getRuleSuite(ctx, suite.Id)
+		if err != nil {
+			return nil, err
+		}
+		bypassed := map[string]bool{}
+		for _, evaluation := range detailed.RuleEvaluations {
+			control, ok := ruleControls[evaluation.RuleType]
+			if !ok || evaluation.Enforcement != "active" || evaluation.Result != "fail" || bypassed[control] {
+				continue
+			}
+			bypassed[control] = true
+			bypasses = append(bypasses, slsa_types.ControlBypass{
+				Control: control,
+				Actor:   suite.ActorName,
+				Commit:  suite.AfterSha,
+				Time:    suite.PushedAt,
+			})
+		}
+	}
+	return bypasses, nil
+}
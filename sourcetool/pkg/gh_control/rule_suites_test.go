@@ -0,0 +1,92 @@
+package gh_control
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestGetControlBypasses(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	from := now.Add(-2 * time.Hour)
+	suites := []map[string]any{
+		{"id": 1, "actor_name": "admin", "after_sha": "aaa", "ref": "refs/heads/main", "pushed_at": now.Add(-time.Hour), "result": "bypass"},
+		// Before the window.
+		{"id": 2, "actor_name": "admin", "after_sha": "bbb", "ref": "refs/heads/main", "pushed_at": now.Add(-3 * time.Hour), "result": "bypass"},
+		// Only bypassed rules we don't care about.
+		{"id": 3, "actor_name": "bot", "after_sha": "ccc", "ref": "refs/heads/main", "pushed_at": now.Add(-time.Minute), "result": "bypass"},
+	}
+
+	var requestedPeriod, requestedResult string
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/rulesets/rule-suites": func(w http.ResponseWriter, r *http.Request) any {
+			requestedPeriod = r.URL.Query().Get("time_period")
+			requestedResult = r.URL.Query().Get("rule_suite_result")
+			return suites
+		},
+		"/repos/owner/repo/rulesets/rule-suites/1": map[string]any{
+			"id": 1,
+			"rule_evaluations": []map[string]any{
+				{"rule_type": "pull_request", "enforcement": "active", "result": "fail"},
+				{"rule_type": "non_fast_forward", "enforcement": "active", "result": "pass"},
+				{"rule_type": "deletion", "enforcement": "evaluate", "result": "fail"},
+			},
+		},
+		"/repos/owner/repo/rulesets/rule-suites/3": map[string]any{
+			"id": 3,
+			"rule_evaluations": []map[string]any{
+				{"rule_type": "commit_message_pattern", "enforcement": "active", "result": "fail"},
+			},
+		},
+	})
+
+	bypasses, err := ghc.GetControlBypasses(context.Background(), "refs/heads/main", from, now)
+	if err != nil {
+		t.Fatalf("GetControlBypasses() error = %v", err)
+	}
+	if requestedPeriod != "day" || requestedResult != "bypass" {
+		t.Errorf("requested rule suites for time_period %q result %q, want day and bypass", requestedPeriod, requestedResult)
+	}
+	expected := slsa_types.ControlBypass{Control: slsa_types.ReviewEnforced, Actor: "admin", Commit: "aaa", Time: now.Add(-time.Hour)}
+	if len(bypasses) != 1 || bypasses[0].Control != expected.Control || bypasses[0].Commit != expected.Commit || !bypasses[0].Time.Equal(expected.Time) {
+		t.Errorf("GetControlBypasses() = %v, want [%v]", bypasses, expected)
+	}
+}
+
+func TestGetControlBypasses_BeyondRetention(t *testing.T) {
+	now := time.Now()
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/rulesets/rule-suites": []map[string]any{},
+	})
+
+	bypasses, err := ghc.GetControlBypasses(context.Background(), "refs/tags/v1", now.Add(-60*24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("GetControlBypasses() error = %v", err)
+	}
+	// The rule suites that could show a bypass before then have expired.
+	retained := now.Add(-ruleSuiteRetention)
+	if len(bypasses) != 1 || bypasses[0].Control != slsa_types.ImmutableTags || bypasses[0].Time.Sub(retained).Abs() > time.Minute {
+		t.Errorf("GetControlBypasses() = %v, want %s bypassed at %v", bypasses, slsa_types.ImmutableTags, retained)
+	}
+}
+
+func TestRuleSuiteTimePeriod(t *testing.T) {
+	tests := []struct {
+		window   time.Duration
+		expected string
+	}{
+		{time.Minute, "hour"},
+		{2 * time.Hour, "day"},
+		{3 * 24 * time.Hour, "week"},
+		{20 * 24 * time.Hour, "month"},
+		{60 * 24 * time.Hour, "month"},
+	}
+	for _, tt := range tests {
+		if got := ruleSuiteTimePeriod(tt.window); got != tt.expected {
+			t.Errorf("ruleSuiteTimePeriod(%v) = %q, want %q", tt.window, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+// A version of a ruleset, as it was after someone changed it.
+type rulesetVersion struct {
+	VersionId int64     `json:"version_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Only included when getting a single version.
+	State *github.RepositoryRuleset `json:"state"`
+}
+
+// Reports if a version of a ruleset enforces what a control needs.
+type rulesetPredicate func(*github.RepositoryRuleset) bool
+
+// Returns the url of the ruleset's history, "" if GitHub doesn't keep its history.
+func rulesetHistoryUrl(ruleset *github.RepositoryRuleset, owner, repo string) string {
+	sourceType := github.RulesetSourceTypeRepository
+	if ruleset.SourceType != nil {
+		sourceType = *ruleset.SourceType
+	}
+	switch sourceType {
+	case github.RulesetSourceTypeRepository:
+		return fmt.Sprintf("repos/%s/%s/rulesets/%d/history", owner, repo, ruleset.GetID())
+	case github.RulesetSourceTypeOrganization:
+		return fmt.Sprintf("orgs/%s/rulesets/%d/history", ruleset.Source, ruleset.GetID())
+	default:
+		return ""
+	}
+}
+
+// Gets the versions of the ruleset, newest first.
+func (ghc *GitHubConnection) getRulesetHistory(ctx context.Context, historyUrl string) ([]*rulesetVersion, error) {
+	ghc.rulesetsMu.Lock()
+	versions, ok := ghc.rulesetHistories[historyUrl]
+	ghc.rulesetsMu.Unlock()
+	if ok {
+		return versions, nil
+	}
+
+	page := 1
+	for {
+		req, err := ghc.Client().NewRequest("GET", fmt.Sprintf("%s?per_page=100&page=%d", historyUrl, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result []*rulesetVersion
+		resp, err := ghc.Client().Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, result...)
+		if resp.NextPage == 0 {
+			break
+		}
+		page = resp.NextPage
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].UpdatedAt.After(versions[j].UpdatedAt)
+	})
+	ghc.rulesetsMu.Lock()
+	ghc.rulesetHistories[historyUrl] = versions
+	ghc.rulesetsMu.Unlock()
+	return versions, nil
+}
+
+// Gets the state of the ruleset at 'version'.
+func (ghc *GitHubConnection) getRulesetVersion(ctx context.Context, historyUrl string, versionId int64) (*github.RepositoryRuleset, error) {
+	versionUrl := fmt.Sprintf("%s/%d", historyUrl, versionId)
+	ghc.rulesetsMu.Lock()
+	state, ok := ghc.rulesetVersions[versionUrl]
+	ghc.rulesetsMu.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	req, err := ghc.Client().NewRequest("GET", versionUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	var version rulesetVersion
+	if _, err := ghc.Client().Do(ctx, req, &version); err != nil {
+		return nil, err
+	}
+	if version.State == nil {
+		return nil, fmt.Errorf("%s has no state", versionUrl)
+	}
+	ghc.rulesetsMu.Lock()
+	ghc.rulesetVersions[versionUrl] = version.State
+	ghc.rulesetsMu.Unlock()
+	return version.State, nil
+}
+
+// Returns the time from which 'ruleset' has continuously been active, applied to the same
+// refs and repos, could only be bypassed by actors that still can and met 'qualifies',
+// going back through its history. Without history that's when it was last updated.
+func (ghc *GitHubConnection) qualifyingSince(ctx context.Context, ruleset *github.RepositoryRuleset, qualifies rulesetPredicate) (time.Time, error) {
+	since := ruleset.GetUpdatedAt().Time
+	historyUrl := rulesetHistoryUrl(ruleset, ghc.Owner(), ghc.Repo())
+	if historyUrl == "" {
+		return since, nil
+	}
+
+	history, err := ghc.getRulesetHistory(ctx, historyUrl)
+	if err != nil {
+		if isForbiddenOrNotFound(err) {
+			log.Printf("history of ruleset %d not available, using when it was last updated: %v", ruleset.GetID(), err)
+			return since, nil
+		}
+		return time.Time{}, fmt.Errorf("could not get history of ruleset %d: %w", ruleset.GetID(), err)
+	}
+
+	for _, version := range history {
+		if version.UpdatedAt.After(since) {
+			// Newer than what we evaluated, shouldn't happen.
+			continue
+		}
+		state, err := ghc.getRulesetVersion(ctx, historyUrl, version.VersionId)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not get version %d of ruleset %d: %w", version.VersionId, ruleset.GetID(), err)
+		}
+		if state.Enforcement != github.RulesetEnforcementActive ||
+			!reflect.DeepEqual(state.Conditions, ruleset.Conditions) ||
+			!bypassActorsSubset(rulesetBypassActors(state), rulesetBypassActors(ruleset)) ||
+			!qualifies(state) {
+			break
+		}
+		since = version.UpdatedAt
+	}
+	return since, nil
+}
+
+func refConditions(ruleset *github.RepositoryRuleset) *github.RepositoryRulesetRefConditionParameters {
+	if ruleset.Conditions == nil {
+		return nil
+	}
+	return ruleset.Conditions.RefName
+}
+
+// Reports if every one of 'actors' is in 'others'.
+func bypassActorsSubset(actors, others []slsa_types.BypassActor) bool {
+	for _, actor := range actors {
+		if !slices.Contains(others, actor) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the earliest time from which any of 'rulesets' has continuously met 'qualifies'
+// and whether there were any.
+func (ghc *GitHubConnection) earliestQualifyingSince(ctx context.Context, rulesets []*github.RepositoryRuleset, qualifies rulesetPredicate) (time.Time, bool, error) {
+	var earliest time.Time
+	found := false
+	for _, ruleset := range rulesets {
+		since, err := ghc.qualifyingSince(ctx, ruleset, qualifies)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if !found || since.Before(earliest) {
+			earliest = since
+			found = true
+		}
+	}
+	return earliest, found, nil
+}
+
+func hasDeletionRule(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.Deletion != nil
+}
+
+func hasNonFastForwardRule(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.NonFastForward != nil
+}
+
+func requiresReview(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.PullRequest != nil && pullRequestParamsRequireReview(ruleset.Rules.PullRequest)
+}
+
+func hasRequiredSignaturesRule(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.RequiredSignatures != nil
+}
+
+func hasRequiredLinearHistoryRule(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.RequiredLinearHistory != nil
+}
+
+func hasMergeQueueRule(ruleset *github.RepositoryRuleset) bool {
+	return ruleset.Rules != nil && ruleset.Rules.MergeQueue != nil
+}
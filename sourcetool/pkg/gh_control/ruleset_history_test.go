@@ -0,0 +1,165 @@
+package gh_control
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestQualifyingSince(t *testing.T) {
+	v1Time := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v2Time := v1Time.Add(24 * time.Hour)
+	v3Time := v2Time.Add(24 * time.Hour)
+	mainOnly := map[string]any{"ref_name": map[string]any{"include": []string{"~DEFAULT_BRANCH"}, "exclude": []string{}}}
+	allBranches := map[string]any{"ref_name": map[string]any{"include": []string{"~ALL"}, "exclude": []string{}}}
+	deletion := []map[string]any{{"type": "deletion"}}
+	deletionAndNoFf := []map[string]any{{"type": "deletion"}, {"type": "non_fast_forward"}}
+	mainOfAllRepos := map[string]any{
+		"ref_name":        map[string]any{"include": []string{"~DEFAULT_BRANCH"}, "exclude": []string{}},
+		"repository_name": map[string]any{"include": []string{"~ALL"}, "exclude": []string{}},
+	}
+	admins := []map[string]any{{"actor_type": "OrganizationAdmin", "bypass_mode": "always"}}
+
+	version := func(id int64, at time.Time, enforcement string, conditions map[string]any, rules []map[string]any) map[string]any {
+		return map[string]any{
+			"version_id": id,
+			"updated_at": at,
+			"state":      map[string]any{"id": 1, "name": "main", "enforcement": enforcement, "conditions": conditions, "rules": rules},
+		}
+	}
+	bypassableVersion := func(id int64, at time.Time, rules []map[string]any, bypassActors []map[string]any) map[string]any {
+		v := version(id, at, "active", mainOnly, rules)
+		v["state"].(map[string]any)["bypass_actors"] = bypassActors
+		return v
+	}
+	// Serves the versions as the ruleset history at 'path'.
+	history := func(path string, versions ...map[string]any) map[string]any {
+		responses := map[string]any{}
+		var list []map[string]any
+		for _, v := range versions {
+			list = append(list, map[string]any{"version_id": v["version_id"], "updated_at": v["updated_at"]})
+			responses[fmt.Sprintf("%s/%d", path, v["version_id"])] = v
+		}
+		responses[path] = list
+		return responses
+	}
+	current := &github.RepositoryRuleset{
+		ID:          github.Ptr(int64(1)),
+		Enforcement: github.RulesetEnforcementActive,
+		UpdatedAt:   &github.Timestamp{Time: v3Time},
+		Conditions:  &github.RepositoryRulesetConditions{RefName: &github.RepositoryRulesetRefConditionParameters{Include: []string{"~DEFAULT_BRANCH"}, Exclude: []string{}}},
+	}
+	orgCurrent := *current
+	orgCurrent.SourceType = github.Ptr(github.RulesetSourceTypeOrganization)
+	orgCurrent.Source = "my-org"
+	bypassableCurrent := *current
+	bypassableCurrent.BypassActors = []*github.BypassActor{{ActorType: github.Ptr(github.BypassActorTypeOrganizationAdmin), BypassMode: github.Ptr(github.BypassModeAlways)}}
+
+	tests := []struct {
+		name      string
+		ruleset   *github.RepositoryRuleset
+		responses map[string]any
+		qualifies rulesetPredicate
+		expected  time.Time
+	}{
+		{
+			name:      "no history",
+			ruleset:   current,
+			responses: map[string]any{},
+			qualifies: hasDeletionRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "tightened after the rule was added",
+			ruleset: current,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletionAndNoFf),
+				version(2, v2Time, "active", mainOnly, deletion),
+				version(1, v1Time, "active", mainOnly, nil)),
+			qualifies: hasDeletionRule,
+			expected:  v2Time,
+		},
+		{
+			name:    "rule added last",
+			ruleset: current,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletionAndNoFf),
+				version(2, v2Time, "active", mainOnly, deletion),
+				version(1, v1Time, "active", mainOnly, nil)),
+			qualifies: hasNonFastForwardRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "disabled in between",
+			ruleset: current,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletion),
+				version(2, v2Time, "evaluate", mainOnly, deletion),
+				version(1, v1Time, "active", mainOnly, deletion)),
+			qualifies: hasDeletionRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "targeted other refs",
+			ruleset: current,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletion),
+				version(2, v2Time, "active", allBranches, deletion)),
+			qualifies: hasDeletionRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "organization ruleset",
+			ruleset: &orgCurrent,
+			responses: history("/orgs/my-org/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletion),
+				version(2, v2Time, "active", mainOnly, deletion),
+				version(1, v1Time, "active", mainOnly, deletion)),
+			qualifies: hasDeletionRule,
+			expected:  v1Time,
+		},
+		{
+			name:    "organization ruleset applied to other repos",
+			ruleset: &orgCurrent,
+			responses: history("/orgs/my-org/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletion),
+				version(2, v2Time, "active", mainOfAllRepos, deletion)),
+			qualifies: hasDeletionRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "bypass actor removed",
+			ruleset: current,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				version(3, v3Time, "active", mainOnly, deletion),
+				bypassableVersion(2, v2Time, deletion, admins)),
+			qualifies: hasDeletionRule,
+			expected:  v3Time,
+		},
+		{
+			name:    "bypass actor added",
+			ruleset: &bypassableCurrent,
+			responses: history("/repos/owner/repo/rulesets/1/history",
+				bypassableVersion(3, v3Time, deletion, admins),
+				version(2, v2Time, "active", mainOnly, deletion)),
+			qualifies: hasDeletionRule,
+			expected:  v2Time,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, tt.responses)
+			since, err := ghc.qualifyingSince(context.Background(), tt.ruleset, tt.qualifies)
+			if err != nil {
+				t.Fatalf("qualifyingSince() error = %v", err)
+			}
+			if !since.Equal(tt.expected) {
+				t.Errorf("qualifyingSince() = %v, want %v", since, tt.expected)
+			}
+		})
+	}
+}
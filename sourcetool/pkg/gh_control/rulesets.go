@@ -0,0 +1,154 @@
+package gh_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+// Rulesets are identified by where they're configured and their id.
+type rulesetKey struct {
+	sourceType github.RulesetSourceType
+	id         int64
+}
+
+// Gets the full ruleset with 'id' from where it's configured: the repo, its organization
+// or its enterprise ('source' names the org or enterprise). Each ruleset is fetched at most
+// once per connection.
+func (ghc *GitHubConnection) getRuleset(ctx context.Context, sourceType github.RulesetSourceType, source string, id int64) (*github.RepositoryRuleset, error) {
+	if sourceType == "" {
+		sourceType = github.RulesetSourceTypeRepository
+	}
+	key := rulesetKey{sourceType: sourceType, id: id}
+
+	ghc.rulesetsMu.Lock()
+	ruleset, ok := ghc.rulesets[key]
+	ghc.rulesetsMu.Unlock()
+	if ok {
+		return ruleset, nil
+	}
+
+	var err error
+	switch sourceType {
+	case github.RulesetSourceTypeRepository:
+		ruleset, _, err = ghc.Client().Repositories.GetRuleset(ctx, ghc.Owner(), ghc.Repo(), id, false)
+	case github.RulesetSourceTypeOrganization:
+		ruleset, _, err = ghc.Client().Organizations.GetRepositoryRuleset(ctx, source, id)
+	case github.RulesetSourceTypeEnterprise:
+		ruleset, _, err = ghc.Client().Enterprise.GetRepositoryRuleset(ctx, source, id)
+	default:
+		return nil, fmt.Errorf("ruleset %d has unknown source type %q", id, sourceType)
+	}
+
+	// Reading org and enterprise rulesets needs admin access to them, without it we can
+	// still read them through the repo they apply to.
+	if err != nil && sourceType != github.RulesetSourceTypeRepository && isForbiddenOrNotFound(err) {
+		ruleset, _, err = ghc.Client().Repositories.GetRuleset(ctx, ghc.Owner(), ghc.Repo(), id, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s ruleset %d: %w", sourceType, id, err)
+	}
+	ghc.rulesetsMu.Lock()
+	ghc.rulesets[key] = ruleset
+	ghc.rulesetsMu.Unlock()
+	return ruleset, nil
+}
+
+// Gets the full version of a ruleset returned (only partially) when listing rulesets.
+func (ghc *GitHubConnection) getFullRuleset(ctx context.Context, partial *github.RepositoryRuleset) (*github.RepositoryRuleset, error) {
+	var sourceType github.RulesetSourceType
+	if partial.SourceType != nil {
+		sourceType = *partial.SourceType
+	}
+	return ghc.getRuleset(ctx, sourceType, partial.Source, partial.GetID())
+}
+
+func isForbiddenOrNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+	return errResp.Response.StatusCode == http.StatusForbidden || errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// Gets the actively enforced rulesets 'rules' come from.
+func (ghc *GitHubConnection) getActiveRulesets(ctx context.Context, rules []*github.BranchRuleMetadata) ([]*github.RepositoryRuleset, error) {
+	var active []*github.RepositoryRuleset
+	for _, rule := range rules {
+		ruleset, err := ghc.getRuleset(ctx, rule.RulesetSourceType, rule.RulesetSource, rule.RulesetID)
+		if err != nil {
+			return nil, err
+		}
+		if ruleset.Enforcement == github.RulesetEnforcementActive {
+			active = append(active, ruleset)
+		}
+	}
+	return active, nil
+}
+
+// Returns the bypass actors of a single ruleset, ignoring any that can never bypass it.
+func rulesetBypassActors(ruleset *github.RepositoryRuleset) []slsa_types.BypassActor {
+	var actors []slsa_types.BypassActor
+	for _, actor := range ruleset.BypassActors {
+		mode := github.BypassModeAlways
+		if actor.BypassMode != nil {
+			mode = *actor.BypassMode
+		}
+		if mode == github.BypassModeNever {
+			continue
+		}
+		var actorType github.BypassActorType
+		if actor.ActorType != nil {
+			actorType = *actor.ActorType
+		}
+		actors = append(actors, slsa_types.BypassActor{ActorType: string(actorType), ActorId: actor.GetActorID(), BypassMode: string(mode)})
+	}
+	return actors
+}
+
+// Returns who can bypass a rule that every one of 'rulesets' enforces. Only actors that
+// can bypass all of them can, and only in the most restrictive mode they're allowed.
+func effectiveBypassActors(rulesets []*github.RepositoryRuleset) []slsa_types.BypassActor {
+	if len(rulesets) == 0 {
+		return nil
+	}
+	effective := rulesetBypassActors(rulesets[0])
+	for _, ruleset := range rulesets[1:] {
+		effective = intersectBypassActors(effective, rulesetBypassActors(ruleset))
+	}
+	return effective
+}
+
+// Returns the actors in both lists, in the more restrictive mode of the two.
+func intersectBypassActors(actors, others []slsa_types.BypassActor) []slsa_types.BypassActor {
+	var both []slsa_types.BypassActor
+	for _, actor := range actors {
+		idx := slices.IndexFunc(others, func(other slsa_types.BypassActor) bool {
+			return other.ActorType == actor.ActorType && other.ActorId == actor.ActorId
+		})
+		if idx < 0 {
+			continue
+		}
+		if others[idx].BypassMode != string(github.BypassModeAlways) {
+			actor.BypassMode = others[idx].BypassMode
+		}
+		both = append(both, actor)
+	}
+	return both
+}
+
+// Returns the actors in either list, without duplicates.
+func mergeBypassActors(actors, others []slsa_types.BypassActor) []slsa_types.BypassActor {
+	merged := slices.Clone(actors)
+	for _, other := range others {
+		if !slices.Contains(merged, other) {
+			merged = append(merged, other)
+		}
+	}
+	return merged
+}
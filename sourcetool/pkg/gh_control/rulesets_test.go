@@ -0,0 +1,231 @@
+package gh_control
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+func TestGetRuleset(t *testing.T) {
+	// Serves a ruleset named after the endpoint it was fetched from.
+	named := func(name string) func(http.ResponseWriter, *http.Request) any {
+		return func(http.ResponseWriter, *http.Request) any {
+			return map[string]any{"id": 7, "name": name}
+		}
+	}
+	forbidden := func(w http.ResponseWriter, _ *http.Request) any {
+		w.WriteHeader(http.StatusForbidden)
+		return map[string]any{"message": "Must have admin rights"}
+	}
+
+	tests := []struct {
+		name         string
+		sourceType   github.RulesetSourceType
+		source       string
+		responses    map[string]any
+		expectedName string
+	}{
+		{
+			name:         "repository",
+			sourceType:   github.RulesetSourceTypeRepository,
+			source:       "owner/repo",
+			responses:    map[string]any{"/repos/owner/repo/rulesets/7": named("repo")},
+			expectedName: "repo",
+		},
+		{
+			name:         "unset source type",
+			responses:    map[string]any{"/repos/owner/repo/rulesets/7": named("repo")},
+			expectedName: "repo",
+		},
+		{
+			name:         "organization",
+			sourceType:   github.RulesetSourceTypeOrganization,
+			source:       "my-org",
+			responses:    map[string]any{"/orgs/my-org/rulesets/7": named("org")},
+			expectedName: "org",
+		},
+		{
+			name:         "enterprise",
+			sourceType:   github.RulesetSourceTypeEnterprise,
+			source:       "my-enterprise",
+			responses:    map[string]any{"/enterprises/my-enterprise/rulesets/7": named("enterprise")},
+			expectedName: "enterprise",
+		},
+		{
+			name:       "organization without admin access",
+			sourceType: github.RulesetSourceTypeOrganization,
+			source:     "my-org",
+			responses: map[string]any{
+				"/orgs/my-org/rulesets/7":      forbidden,
+				"/repos/owner/repo/rulesets/7": named("via repo"),
+			},
+			expectedName: "via repo",
+		},
+		{
+			name:       "missing",
+			sourceType: github.RulesetSourceTypeRepository,
+			responses:  map[string]any{"/orgs/owner/rulesets/7": named("org")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := newTestGhConnection(t, tt.responses)
+			ruleset, err := ghc.getRuleset(context.Background(), tt.sourceType, tt.source, 7)
+			if tt.expectedName == "" {
+				if err == nil {
+					t.Errorf("getRuleset() = %v, want error", ruleset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getRuleset() error = %v", err)
+			}
+			if ruleset.Name != tt.expectedName {
+				t.Errorf("getRuleset() = %q, want %q", ruleset.Name, tt.expectedName)
+			}
+		})
+	}
+}
+
+func TestGetRuleset_Memoized(t *testing.T) {
+	fetches := 0
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/rulesets/7": func(http.ResponseWriter, *http.Request) any {
+			fetches++
+			return map[string]any{"id": 7, "name": "main"}
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		ruleset, err := ghc.getRuleset(context.Background(), github.RulesetSourceTypeRepository, "owner/repo", 7)
+		if err != nil {
+			t.Fatalf("getRuleset() error = %v", err)
+		}
+		if ruleset.Name != "main" {
+			t.Errorf("getRuleset() = %q, want %q", ruleset.Name, "main")
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("ruleset fetched %d times, want 1", fetches)
+	}
+}
+
+func TestGetActiveRulesets_Inherited(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ghc := newTestGhConnection(t, map[string]any{
+		"/repos/owner/repo/rulesets/1":   map[string]any{"id": 1, "enforcement": "active", "updated_at": newer},
+		"/orgs/owner/rulesets/2":         map[string]any{"id": 2, "enforcement": "active", "updated_at": older},
+		"/enterprises/my-ent/rulesets/3": map[string]any{"id": 3, "enforcement": "evaluate", "updated_at": older.Add(-time.Hour)},
+	})
+
+	rules := []*github.BranchRuleMetadata{
+		{RulesetSourceType: github.RulesetSourceTypeRepository, RulesetSource: "owner/repo", RulesetID: 1},
+		{RulesetSourceType: github.RulesetSourceTypeOrganization, RulesetSource: "owner", RulesetID: 2},
+		{RulesetSourceType: github.RulesetSourceTypeEnterprise, RulesetSource: "my-ent", RulesetID: 3},
+	}
+	active, err := ghc.getActiveRulesets(context.Background(), rules)
+	if err != nil {
+		t.Fatalf("getActiveRulesets() error = %v", err)
+	}
+	if len(active) != 2 {
+		t.Errorf("getActiveRulesets() returned %d rulesets, want the 2 active ones", len(active))
+	}
+}
+
+func TestEffectiveBypassActors(t *testing.T) {
+	bypass := func(actorType github.BypassActorType, id int64, mode github.BypassMode) *github.BypassActor {
+		return &github.BypassActor{ActorType: &actorType, ActorID: github.Ptr(id), BypassMode: &mode}
+	}
+	admin := bypass(github.BypassActorTypeOrganizationAdmin, 1, github.BypassModeAlways)
+	team := bypass(github.BypassActorTypeTeam, 5, github.BypassModeAlways)
+	teamViaPr := bypass(github.BypassActorTypeTeam, 5, github.BypassModePullRequest)
+	app := bypass(github.BypassActorTypeIntegration, 9, github.BypassModeNever)
+
+	tests := []struct {
+		name     string
+		rulesets [][]*github.BypassActor
+		expected []slsa_types.BypassActor
+	}{
+		{
+			name: "no rulesets",
+		},
+		{
+			name:     "single ruleset",
+			rulesets: [][]*github.BypassActor{{admin, team, app}},
+			expected: []slsa_types.BypassActor{
+				{ActorType: "OrganizationAdmin", ActorId: 1, BypassMode: "always"},
+				{ActorType: "Team", ActorId: 5, BypassMode: "always"},
+			},
+		},
+		{
+			name:     "must bypass every ruleset",
+			rulesets: [][]*github.BypassActor{{admin, team}, {team}},
+			expected: []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "always"}},
+		},
+		{
+			name:     "most restrictive mode",
+			rulesets: [][]*github.BypassActor{{team}, {teamViaPr}},
+			expected: []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "pull_request"}},
+		},
+		{
+			name:     "one ruleset nobody can bypass",
+			rulesets: [][]*github.BypassActor{{admin, team}, {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rulesets []*github.RepositoryRuleset
+			for _, actors := range tt.rulesets {
+				rulesets = append(rulesets, &github.RepositoryRuleset{BypassActors: actors})
+			}
+			got := effectiveBypassActors(rulesets)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("effectiveBypassActors() = %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("effectiveBypassActors()[%d] = %v, want %v", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAppliesToRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		ref      string
+		expected bool
+	}{
+		{name: "all tags", include: []string{"~ALL"}, ref: "refs/tags/latest", expected: true},
+		{name: "release tag", include: []string{"refs/tags/v*"}, ref: "refs/tags/v1.2.3", expected: true},
+		{name: "not a release tag", include: []string{"refs/tags/v*"}, ref: "refs/tags/nightly", expected: false},
+		{name: "star doesn't match slash", include: []string{"refs/tags/v*"}, ref: "refs/tags/v1/rc", expected: false},
+		{name: "double star matches directories", include: []string{"refs/tags/**/v*"}, ref: "refs/tags/sub/dir/v1", expected: true},
+		{name: "character set", include: []string{"refs/tags/v[0-9]*"}, ref: "refs/tags/v2", expected: true},
+		{name: "negated character set", include: []string{"refs/tags/v[!0-9]*"}, ref: "refs/tags/v2", expected: false},
+		{name: "excluded", include: []string{"~ALL"}, exclude: []string{"refs/tags/latest", "refs/tags/nightly"}, ref: "refs/tags/latest", expected: false},
+		{name: "not excluded", include: []string{"~ALL"}, exclude: []string{"refs/tags/latest", "refs/tags/nightly"}, ref: "refs/tags/v1", expected: true},
+		{name: "escaped", include: []string{`refs/tags/\*`}, ref: "refs/tags/v1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &github.RepositoryRuleset{Conditions: &github.RepositoryRulesetConditions{
+				RefName: &github.RepositoryRulesetRefConditionParameters{Include: tt.include, Exclude: tt.exclude},
+			}}
+			if got := appliesToRef(ruleset, tt.ref); got != tt.expected {
+				t.Errorf("appliesToRef(%v, %v, %q) = %v, want %v", tt.include, tt.exclude, tt.ref, got, tt.expected)
+			}
+		})
+	}
+}
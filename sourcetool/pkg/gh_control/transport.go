@@ -0,0 +1,365 @@
+package gh_control
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// How many times a rate limited request is retried.
+	maxRateLimitRetries = 3
+	// The longest we'll wait for a rate limit to reset before giving up.
+	DefaultMaxRateLimitWait = 5 * time.Minute
+	// Cache entries that haven't been used for this long are pruned.
+	DefaultCacheMaxAge = 30 * 24 * time.Hour
+	// The cache is pruned to this many bytes, least recently used entries first.
+	DefaultCacheMaxSize = 100 << 20
+)
+
+// A response stored in the on-disk cache.
+type cachedResponse struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// The SHA-256 of Body, entries that don't match are never served.
+	Digest string `json:"digest"`
+}
+
+// An http.RoundTripper for the GitHub API that
+//   - makes GET requests conditional on the ETag of the last response, which GitHub
+//     doesn't count against the rate limit when nothing changed, and
+//   - waits and retries when GitHub responds with a primary or secondary rate limit.
+//
+// A cached body is only served when GitHub says it's still current for the credentials
+// of the request, the ETag GitHub sent with that matches the cached one, and the body is
+// unchanged since it was stored. The cache dir must only be accessible by its owner.
+type CachingTransport struct {
+	base          http.RoundTripper
+	cacheDir      string
+	maxAge        time.Duration
+	maxSize       int64
+	maxWait       time.Duration
+	sleep         func(*http.Request, time.Duration) error
+	now           func() time.Time
+	prepareCache  sync.Once
+	cacheDisabled bool
+}
+
+// Creates a transport that caches responses in cacheDir (not at all if empty)
+// and sends requests using base (http.DefaultTransport if nil).
+func NewCachingTransport(base http.RoundTripper, cacheDir string) *CachingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &CachingTransport{
+		base:     base,
+		cacheDir: cacheDir,
+		maxAge:   DefaultCacheMaxAge,
+		maxSize:  DefaultCacheMaxSize,
+		maxWait:  DefaultMaxRateLimitWait,
+		sleep:    sleepContext,
+		now:      time.Now}
+}
+
+// Prunes cache entries that haven't been used for maxAge, and the least recently used
+// entries when the cache is bigger than maxSize bytes.
+func (ct *CachingTransport) WithCacheLimits(maxAge time.Duration, maxSize int64) *CachingTransport {
+	ct.maxAge = maxAge
+	ct.maxSize = maxSize
+	return ct
+}
+
+// Waits at most maxWait for a rate limit to reset.
+func (ct *CachingTransport) WithMaxRateLimitWait(maxWait time.Duration) *CachingTransport {
+	ct.maxWait = maxWait
+	return ct
+}
+
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (ct *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cacheable := ct.cacheDir != "" && req.Method == http.MethodGet && ct.cacheUsable()
+	var cached *cachedResponse
+	if cacheable {
+		cached = ct.load(req)
+	}
+
+	if cached != nil {
+		conditional := req.Clone(req.Context())
+		conditional.Header.Set("If-None-Match", cached.ETag)
+		resp, err := ct.sendWithRetries(conditional)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusNotModified {
+			return ct.maybeStore(req, resp)
+		}
+		resp.Body.Close()
+		// ETags are per credentials, only serve the body if it's the one GitHub has in mind.
+		if etag := resp.Header.Get("ETag"); etag == "" || etag == cached.ETag {
+			ct.touch(req)
+			return cached.toResponse(req), nil
+		}
+	}
+
+	resp, err := ct.sendWithRetries(req)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheable {
+		return resp, nil
+	}
+	return ct.maybeStore(req, resp)
+}
+
+// Caches the response if it can be, returning it with its body still readable.
+func (ct *CachingTransport) maybeStore(req *http.Request, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		ct.store(req, &cachedResponse{ETag: resp.Header.Get("ETag"), Header: resp.Header, Body: body, Digest: digest(body)})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
+
+// Sends the request, waiting and retrying if it's rate limited.
+func (ct *CachingTransport) sendWithRetries(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := ct.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		wait, limited := ct.rateLimitWait(resp, attempt)
+		if !limited || attempt >= maxRateLimitRetries {
+			return resp, nil
+		}
+		if wait > ct.maxWait {
+			log.Printf("rate limited by %s, not waiting %v for it to reset", req.URL.Host, wait)
+			return resp, nil
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// Can't resend the body.
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		log.Printf("rate limited by %s, retrying in %v", req.URL.Host, wait)
+		if err := ct.sleep(req, wait); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// Reports if the response is a rate limit and how long to wait before retrying.
+func (ct *CachingTransport) rateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	// Secondary rate limits say how long to wait.
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	// Primary rate limits say when they reset.
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait := time.Unix(reset, 0).Sub(ct.now())
+			if wait < time.Second {
+				wait = time.Second
+			}
+			return wait, true
+		}
+	}
+
+	// 403s are also used for permission problems, only retry those that
+	// say they're secondary rate limits.
+	if resp.StatusCode == http.StatusForbidden && !ct.isSecondaryRateLimit(resp) {
+		return 0, false
+	}
+
+	// Otherwise GitHub suggests waiting at least a minute, backing off exponentially.
+	return time.Minute << attempt, true
+}
+
+// Peeks at the body of a 403 to see if it's a secondary rate limit, leaving the body readable.
+func (ct *CachingTransport) isSecondaryRateLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+// Returns the path of the cache entry for the request. The credentials aren't part of the
+// key: GitHub checks them for every conditional request and ETags differ between them.
+func (ct *CachingTransport) cachePath(req *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s", req.URL.String(), req.Header.Get("Accept"), req.Header.Get("X-GitHub-Api-Version"))
+	return filepath.Join(ct.cacheDir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Creates the cache dir if needed and prunes it, once per transport. Reports if the cache
+// can be used, which it can't if others can access the cache dir.
+func (ct *CachingTransport) cacheUsable() bool {
+	ct.prepareCache.Do(func() {
+		if err := os.MkdirAll(ct.cacheDir, 0700); err != nil {
+			log.Printf("could not create cache dir, not caching: %v", err)
+			ct.cacheDisabled = true
+			return
+		}
+		info, err := os.Stat(ct.cacheDir)
+		if err != nil {
+			log.Printf("could not check cache dir, not caching: %v", err)
+			ct.cacheDisabled = true
+			return
+		}
+		// Windows doesn't report meaningful permissions.
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+			log.Printf("cache dir %s is accessible by others (%v), not caching", ct.cacheDir, info.Mode().Perm())
+			ct.cacheDisabled = true
+			return
+		}
+		ct.prune()
+	})
+	return !ct.cacheDisabled
+}
+
+// Removes entries that haven't been used for maxAge, then the least recently used
+// entries until the cache is no bigger than maxSize.
+func (ct *CachingTransport) prune() {
+	dirEntries, err := os.ReadDir(ct.cacheDir)
+	if err != nil {
+		log.Printf("could not prune cache: %v", err)
+		return
+	}
+	var entries []os.FileInfo
+	var size int64
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if ct.now().Sub(info.ModTime()) > ct.maxAge {
+			os.Remove(filepath.Join(ct.cacheDir, info.Name()))
+			continue
+		}
+		entries = append(entries, info)
+		size += info.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, info := range entries {
+		if size <= ct.maxSize {
+			break
+		}
+		os.Remove(filepath.Join(ct.cacheDir, info.Name()))
+		size -= info.Size()
+	}
+}
+
+// Marks the cache entry for the request as used, so it's pruned last.
+func (ct *CachingTransport) touch(req *http.Request) {
+	now := ct.now()
+	_ = os.Chtimes(ct.cachePath(req), now, now)
+}
+
+// Returns the cache entry for the request, nil if there isn't a valid one.
+func (ct *CachingTransport) load(req *http.Request) *cachedResponse {
+	path := ct.cachePath(req)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(contents, &cached); err != nil || cached.ETag == "" || cached.Digest != digest(cached.Body) {
+		log.Printf("discarding invalid cache entry %s", path)
+		os.Remove(path)
+		return nil
+	}
+	return &cached
+}
+
+// Failing to cache a response isn't fatal, we'll just have to fetch it again.
+func (ct *CachingTransport) store(req *http.Request, cached *cachedResponse) {
+	contents, err := json.Marshal(cached)
+	if err != nil {
+		log.Printf("could not encode response for cache: %v", err)
+		return
+	}
+	// Write then rename so concurrent runs never see partial entries.
+	tmp, err := os.CreateTemp(ct.cacheDir, "entry-*")
+	if err != nil {
+		log.Printf("could not write cache entry: %v", err)
+		return
+	}
+	_, err = tmp.Write(contents)
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), ct.cachePath(req))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("could not write cache entry: %v", err)
+	}
+}
+
+func (cr *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
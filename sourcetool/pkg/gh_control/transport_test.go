@@ -0,0 +1,349 @@
+package gh_control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Makes a transport that records how long it would have slept instead of sleeping.
+func newTestCachingTransport(t *testing.T, cacheDir string) (*CachingTransport, *[]time.Duration) {
+	t.Helper()
+	var slept []time.Duration
+	ct := NewCachingTransport(nil, cacheDir)
+	ct.sleep = func(_ *http.Request, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return ct, &slept
+}
+
+func get(t *testing.T, client *http.Client, url, auth string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", auth)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// Starts a server that serves "version N for <auth>" with an ETag that, like GitHub's,
+// depends on the credentials. Returns the server, the version to serve and how many full
+// responses it sent.
+func newVersionedServer(t *testing.T) (*httptest.Server, *int, *int) {
+	version := 1
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"v%d-%s"`, version, r.Header.Get("Authorization"))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		fmt.Fprintf(w, "version %d for %s", version, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &version, &fetches
+}
+
+func TestCachingTransport_ConditionalRequests(t *testing.T) {
+	server, version, fetches := newVersionedServer(t)
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	ct, _ := newTestCachingTransport(t, cacheDir)
+	client := &http.Client{Transport: ct}
+
+	if _, body := get(t, client, server.URL, "token a"); body != "version 1 for token a" {
+		t.Errorf("first GET = %q, want version 1", body)
+	}
+	// A fresh transport (i.e. a later run) should use the same cache.
+	ct, _ = newTestCachingTransport(t, cacheDir)
+	client = &http.Client{Transport: ct}
+	status, body := get(t, client, server.URL, "token a")
+	if status != http.StatusOK || body != "version 1 for token a" {
+		t.Errorf("cached GET = %d %q, want 200 version 1", status, body)
+	}
+	if *fetches != 1 {
+		t.Errorf("server sent %d full responses, want the second to be not modified", *fetches)
+	}
+
+	// Other credentials get their own response, which replaces the cached one.
+	if _, body := get(t, client, server.URL, "token b"); body != "version 1 for token b" {
+		t.Errorf("GET with other token = %q, want its own response", body)
+	}
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Errorf("cache has %d entries, want one per url regardless of credentials", len(entries))
+	}
+
+	*version = 2
+	if _, body := get(t, client, server.URL, "token a"); body != "version 2 for token a" {
+		t.Errorf("GET after change = %q, want version 2", body)
+	}
+}
+
+func TestCachingTransport_TamperedEntry(t *testing.T) {
+	server, _, fetches := newVersionedServer(t)
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	ct, _ := newTestCachingTransport(t, cacheDir)
+	client := &http.Client{Transport: ct}
+	get(t, client, server.URL, "token a")
+
+	// Change the cached body without updating its digest.
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("cache has %v (%v), want one entry", entries, err)
+	}
+	path := filepath.Join(cacheDir, entries[0].Name())
+	contents, _ := os.ReadFile(path)
+	var cached cachedResponse
+	if err := json.Unmarshal(contents, &cached); err != nil {
+		t.Fatalf("failed to read cache entry: %v", err)
+	}
+	cached.Body = []byte("something else")
+	contents, _ = json.Marshal(cached)
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatalf("failed to write cache entry: %v", err)
+	}
+
+	status, body := get(t, client, server.URL, "token a")
+	if status != http.StatusOK || body != "version 1 for token a" {
+		t.Errorf("GET = %d %q, want the response from the server", status, body)
+	}
+	if *fetches != 2 {
+		t.Errorf("server sent %d full responses, want the tampered entry to be refetched", *fetches)
+	}
+}
+
+func TestCachingTransport_NotModifiedWithOtherETag(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			// Claims nothing changed, but about some other version.
+			w.Header().Set("ETag", `"other"`)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, fetches))
+		fmt.Fprintf(w, "response %d", fetches)
+	}))
+	t.Cleanup(server.Close)
+
+	ct, _ := newTestCachingTransport(t, filepath.Join(t.TempDir(), "cache"))
+	client := &http.Client{Transport: ct}
+	get(t, client, server.URL, "token a")
+	if _, body := get(t, client, server.URL, "token a"); body != "response 2" {
+		t.Errorf("GET = %q, want a fresh response rather than the cached one", body)
+	}
+}
+
+func TestCachingTransport_InsecureCacheDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions aren't checked on Windows")
+	}
+	server, _, fetches := newVersionedServer(t)
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	if err := os.Mkdir(cacheDir, 0777); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	if err := os.Chmod(cacheDir, 0777); err != nil {
+		t.Fatalf("failed to make cache dir world writable: %v", err)
+	}
+	ct, _ := newTestCachingTransport(t, cacheDir)
+	client := &http.Client{Transport: ct}
+	get(t, client, server.URL, "token a")
+	get(t, client, server.URL, "token a")
+
+	if *fetches != 2 {
+		t.Errorf("server sent %d full responses, want the shared cache dir to be ignored", *fetches)
+	}
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 0 {
+		t.Errorf("cache has %d entries, want none", len(entries))
+	}
+}
+
+func TestCachingTransport_Prune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	if err := os.Mkdir(cacheDir, 0700); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	// Each entry is 10 bytes, last used the given time ago.
+	for name, age := range map[string]time.Duration{
+		"expired":     31 * 24 * time.Hour,
+		"oldest":      3 * time.Hour,
+		"older":       2 * time.Hour,
+		"recent":      time.Hour,
+		"most-recent": time.Minute,
+	} {
+		path := filepath.Join(cacheDir, name)
+		if err := os.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+			t.Fatalf("failed to write entry: %v", err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("failed to set entry time: %v", err)
+		}
+	}
+
+	ct, _ := newTestCachingTransport(t, cacheDir)
+	ct.now = func() time.Time { return now }
+	ct = ct.WithCacheLimits(30*24*time.Hour, 25)
+	if !ct.cacheUsable() {
+		t.Fatalf("cacheUsable() = false, want true")
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("failed to read cache dir: %v", err)
+	}
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	expected := []string{"most-recent", "recent"}
+	if fmt.Sprint(remaining) != fmt.Sprint(expected) {
+		t.Errorf("after pruning cache has %v, want %v", remaining, expected)
+	}
+}
+
+func TestCachingTransport_RateLimits(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name        string
+		status      int
+		header      map[string]string
+		body        string
+		expectRetry bool
+		expectWait  time.Duration
+	}{
+		{
+			name:        "secondary rate limit with retry-after",
+			status:      http.StatusForbidden,
+			header:      map[string]string{"Retry-After": "30"},
+			expectRetry: true,
+			expectWait:  30 * time.Second,
+		},
+		{
+			name:        "too many requests",
+			status:      http.StatusTooManyRequests,
+			header:      map[string]string{"Retry-After": "5"},
+			expectRetry: true,
+			expectWait:  5 * time.Second,
+		},
+		{
+			name:   "primary rate limit",
+			status: http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(2*time.Minute).Unix(), 10),
+			},
+			expectRetry: true,
+			expectWait:  2 * time.Minute,
+		},
+		{
+			name:        "secondary rate limit without retry-after",
+			status:      http.StatusForbidden,
+			body:        `{"message": "You have exceeded a secondary rate limit."}`,
+			expectRetry: true,
+			expectWait:  time.Minute,
+		},
+		{
+			name:   "reset too far away",
+			status: http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(time.Hour).Unix(), 10),
+			},
+		},
+		{
+			name:   "permission denied",
+			status: http.StatusForbidden,
+			body:   `{"message": "Resource not accessible by integration"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					for k, v := range tt.header {
+						w.Header().Set(k, v)
+					}
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.body)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}))
+			t.Cleanup(server.Close)
+
+			ct, slept := newTestCachingTransport(t, "")
+			ct.now = func() time.Time { return now }
+			status, body := get(t, &http.Client{Transport: ct}, server.URL, "")
+
+			if !tt.expectRetry {
+				if status != tt.status || requests != 1 {
+					t.Errorf("GET = %d after %d requests, want %d without retrying", status, requests, tt.status)
+				}
+				if body != tt.body {
+					t.Errorf("GET body = %q, want %q", body, tt.body)
+				}
+				return
+			}
+			if status != http.StatusOK || body != "ok" {
+				t.Errorf("GET = %d %q, want it to succeed after retrying", status, body)
+			}
+			if len(*slept) != 1 || (*slept)[0] != tt.expectWait {
+				t.Errorf("waited %v, want [%v]", *slept, tt.expectWait)
+			}
+		})
+	}
+}
+
+func TestCachingTransport_GivesUpAfterRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, "You have exceeded a secondary rate limit.")
+	}))
+	t.Cleanup(server.Close)
+
+	ct, slept := newTestCachingTransport(t, "")
+	status, _ := get(t, &http.Client{Transport: ct}, server.URL, "")
+	if status != http.StatusTooManyRequests {
+		t.Errorf("GET = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if requests != maxRateLimitRetries+1 {
+		t.Errorf("sent %d requests, want %d", requests, maxRateLimitRetries+1)
+	}
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	if fmt.Sprint(*slept) != fmt.Sprint(expected) {
+		t.Errorf("waited %v, want %v", *slept, expected)
+	}
+}
@@ -0,0 +1,245 @@
+package gitea_control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.UncheckedCarryForward = (*GiteaConnection)(nil)
+
+type actor struct {
+	Login string `json:"login"`
+}
+
+type activity struct {
+	OpType  string    `json:"op_type"`
+	ActUser actor     `json:"act_user"`
+	RefName string    `json:"ref_name"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+}
+
+type pushCommit struct {
+	Sha1 string `json:"Sha1"`
+}
+
+// The content Gitea stores with 'commit_repo' activities.
+type pushCommits struct {
+	HeadCommit *pushCommit   `json:"HeadCommit"`
+	Commits    []*pushCommit `json:"Commits"`
+}
+
+// Returns the commit the branch was updated to by this push.
+func (a *activity) headCommit() string {
+	var content pushCommits
+	err := json.Unmarshal([]byte(a.Content), &content)
+	if err != nil {
+		return ""
+	}
+	if content.HeadCommit != nil {
+		return content.HeadCommit.Sha1
+	}
+	if len(content.Commits) > 0 {
+		// Commits are listed newest first.
+		return content.Commits[0].Sha1
+	}
+	return ""
+}
+
+func (a *activity) fullRef() string {
+	// Older versions of Gitea only record the branch name.
+	if strings.HasPrefix(a.RefName, "refs/") {
+		return a.RefName
+	}
+	return source_control.BranchToFullRef(a.RefName)
+}
+
+func (gc *GiteaConnection) commitActivity(ctx context.Context, commit, targetRef string) (*activity, error) {
+	// Page through the feed (newest first) until we find the commit.
+	var found *activity
+	err := visitPaged(ctx, gc, "activities/feeds", nil, func(activities []*activity) bool {
+		for _, activity := range activities {
+			if activity.OpType != "commit_repo" {
+				continue
+			}
+			if activity.headCommit() == commit && activity.fullRef() == targetRef {
+				// Found it
+				found = activity
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("could not find repo activity for commit %s and ref %s", commit, targetRef)
+	}
+	return found, nil
+}
+
+type branchProtection struct {
+	RuleName              string    `json:"rule_name"`
+	EnablePush            bool      `json:"enable_push"`
+	EnableForcePush       bool      `json:"enable_force_push"`
+	RequiredApprovals     int64     `json:"required_approvals"`
+	DismissStaleApprovals bool      `json:"dismiss_stale_approvals"`
+	Updated               time.Time `json:"updated_at"`
+}
+
+type tagProtection struct {
+	NamePattern string    `json:"name_pattern"`
+	Updated     time.Time `json:"updated_at"`
+}
+
+// Gets the protection rule Gitea applies to the branch, nil if it's not protected.
+// Rules that name the branch exactly take priority over glob rules.
+func (gc *GiteaConnection) getBranchProtection(ctx context.Context, branch string) (*branchProtection, error) {
+	var protections []*branchProtection
+	err := gc.get(ctx, "branch_protections", nil, &protections)
+	if err != nil {
+		return nil, err
+	}
+
+	var globMatch *branchProtection
+	for _, bp := range protections {
+		if bp.RuleName == branch {
+			return bp, nil
+		}
+		if globMatch == nil && source_control.MatchesGlob(source_control.DoubleStarGlob, bp.RuleName, branch) {
+			globMatch = bp
+		}
+	}
+	return globMatch, nil
+}
+
+// Computes the continuity control returning nil if it's not enabled.
+// Protected branches can't be deleted, so we just need to make sure force pushes are disabled.
+func computeContinuityControl(commit string, protection *branchProtection, activity *activity) (*slsa_types.Control, error) {
+	if protection == nil || protection.EnableForcePush {
+		log.Printf("branch protection (%v) does not prevent force pushes, cannot be L2+", protection)
+		return nil, nil
+	}
+
+	// Check that the commit was created after the rule was enabled...
+	// to be sure folks aren't somehow sneaking something through...
+	if activity.Created.Before(protection.Updated) {
+		return nil, fmt.Errorf("commit %s created before (%v) the rule was enabled (%v), that shouldn't happen", commit, activity.Created, protection.Updated)
+	}
+
+	return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: protection.Updated}, nil
+}
+
+// Computes the review control returning nil if it's not enabled.
+// Nobody may push to the branch directly, changes must be approved,
+// and approvals must be dismissed when new commits are pushed.
+func computeReviewControl(protection *branchProtection) *slsa_types.Control {
+	if protection == nil ||
+		protection.EnablePush ||
+		protection.RequiredApprovals <= 0 ||
+		!protection.DismissStaleApprovals {
+		return nil
+	}
+	return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: protection.Updated}
+}
+
+// Computes the immutable tags control returning nil if it's not enabled.
+// Protected tags can only be created, updated, or deleted by the users and teams
+// listed in the rule, so the rule needs to cover all tags.
+func (gc *GiteaConnection) computeImmutableTagsControl(ctx context.Context, activityTime time.Time) (*slsa_types.Control, error) {
+	var protections []*tagProtection
+	err := gc.get(ctx, "tag_protections", nil, &protections)
+	if err != nil {
+		return nil, err
+	}
+
+	var validProtection *tagProtection
+	for _, tp := range protections {
+		if tp.NamePattern != "*" && tp.NamePattern != "**" {
+			continue
+		}
+		if validProtection == nil || validProtection.Updated.After(tp.Updated) {
+			validProtection = tp
+		}
+	}
+
+	if validProtection == nil {
+		return nil, nil
+	}
+
+	// Check that the commit was created after this rule was enabled.
+	if activityTime.Before(validProtection.Updated) {
+		return nil, nil
+	}
+
+	return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: validProtection.Updated}, nil
+}
+
+// Gitea can't tell us when a branch was force pushed, but the protection we report controls
+// for applies to everyone and its start time moves whenever it's changed.
+func (gc *GiteaConnection) CarryForwardUnchecked() bool {
+	return true
+}
+
+// Determines the controls that are in place for a branch using Gitea's APIs
+// This is necessarily only as good as Gitea's controls and existing APIs.
+func (gc *GiteaConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	// We want to know when this commit was pushed to ensure the rules were active _then_.
+	activity, err := gc.commitActivity(ctx, commit, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: activity.Created,
+		ActivityType:   "push",
+		ActorLogin:     activity.ActUser.Login,
+		Controls:       slsa_types.Controls{}}
+
+	branch := source_control.GetBranchFromRef(ref)
+	if branch == "" {
+		return nil, fmt.Errorf("ref %s is not a branch", ref)
+	}
+	protection, err := gc.getBranchProtection(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	continuityControl, err := computeContinuityControl(commit, protection, activity)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ContinuityControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(continuityControl)
+
+	controlStatus.Controls.AddControl(computeReviewControl(protection))
+
+	immutableTagsControl, err := gc.computeImmutableTagsControl(ctx, activity.Created)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
+
+func (gc *GiteaConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		Controls:       slsa_types.Controls{}}
+
+	immutableTagsControl, err := gc.computeImmutableTagsControl(ctx, controlStatus.CommitPushTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
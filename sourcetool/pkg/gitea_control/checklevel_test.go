@@ -0,0 +1,255 @@
+package gitea_control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+var pushTime = time.Unix(1678886400, 0).UTC() // March 15, 2023 00:00:00 UTC
+var ruleTime = pushTime.Add(-24 * time.Hour)
+var tagRuleTime = pushTime.Add(-48 * time.Hour)
+
+const testRepoPath = "/api/v1/repos/owner/repo/"
+
+// Starts a stand-in for the Gitea REST API that serves 'responses' as JSON
+// keyed by the path relative to the repo.
+// A response may be a func that gets the request and returns the result to serve.
+func newTestGiteaConnection(t *testing.T, responses map[string]any) *GiteaConnection {
+	t.Helper()
+	server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{
+		PathPrefix: testRepoPath,
+		Authenticated: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "token test-token"
+		},
+	}, responses)
+
+	gc, err := NewGiteaConnectionWithClient(server.URL, "owner", "repo", "refs/heads/main", nil, server.Client())
+	if err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+	return gc.WithAuthToken("test-token")
+}
+
+func pushActivities() []activity {
+	return []activity{
+		{OpType: "merge_pull_request", ActUser: actor{Login: "merger"}, RefName: "main",
+			Content: "1|some pr", Created: pushTime},
+		{OpType: "commit_repo", ActUser: actor{Login: "someone-else"}, RefName: "refs/heads/other",
+			Content: `{"HeadCommit":{"Sha1":"abc123"}}`, Created: pushTime},
+		{OpType: "commit_repo", ActUser: actor{Login: "the-pusher"}, RefName: "refs/heads/main",
+			Content: `{"Commits":[{"Sha1":"abc123"},{"Sha1":"prev123"}],"HeadCommit":{"Sha1":"abc123"}}`, Created: pushTime},
+	}
+}
+
+// Serves 'pages' of activities, reporting their total count like Gitea does.
+func pagedActivities(pages [][]activity) func(http.ResponseWriter, *http.Request) any {
+	return func(w http.ResponseWriter, r *http.Request) any {
+		total := 0
+		for _, page := range pages {
+			total += len(page)
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 || page > len(pages) {
+			return []activity{}
+		}
+		return pages[page-1]
+	}
+}
+
+func TestCommitActivity(t *testing.T) {
+	filler := func(count int) []activity {
+		var page []activity
+		for i := 0; i < count; i++ {
+			page = append(page, activity{OpType: "commit_repo", ActUser: actor{Login: "someone-else"}, RefName: "refs/heads/main",
+				Content: fmt.Sprintf(`{"HeadCommit":{"Sha1":"other%d"}}`, i), Created: pushTime})
+		}
+		return page
+	}
+
+	tests := []struct {
+		name      string
+		pages     [][]activity
+		wantFound bool
+	}{
+		{
+			name:      "found on a later page",
+			pages:     [][]activity{filler(pageSize), filler(pageSize), pushActivities()},
+			wantFound: true,
+		},
+		{
+			name:  "never pushed",
+			pages: [][]activity{filler(pageSize), filler(10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newTestGiteaConnection(t, map[string]any{
+				"activities/feeds": pagedActivities(tt.pages),
+			})
+
+			got, err := gc.commitActivity(context.Background(), "abc123", "refs/heads/main")
+			if !tt.wantFound {
+				if err == nil {
+					t.Fatalf("commitActivity() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commitActivity() error = %v", err)
+			}
+			if got.ActUser.Login != "the-pusher" {
+				t.Errorf("commitActivity() pushed by %s, want the-pusher", got.ActUser.Login)
+			}
+		})
+	}
+}
+
+func TestGetBranchControls(t *testing.T) {
+	tests := []struct {
+		name             string
+		protections      []branchProtection
+		tagProtections   []tagProtection
+		expectedControls slsa_types.Controls
+	}{
+		{
+			name:             "unprotected branch",
+			protections:      []branchProtection{},
+			tagProtections:   []tagProtection{},
+			expectedControls: slsa_types.Controls{},
+		},
+		{
+			name: "force push allowed",
+			protections: []branchProtection{
+				{RuleName: "main", EnablePush: true, EnableForcePush: true, Updated: ruleTime},
+			},
+			tagProtections:   []tagProtection{},
+			expectedControls: slsa_types.Controls{},
+		},
+		{
+			name: "glob rule with direct pushes",
+			protections: []branchProtection{
+				{RuleName: "release/**", Updated: ruleTime.Add(-time.Hour)},
+				{RuleName: "ma*", EnablePush: true, RequiredApprovals: 1, DismissStaleApprovals: true, Updated: ruleTime},
+			},
+			tagProtections: []tagProtection{{NamePattern: "v*", Updated: tagRuleTime}},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: ruleTime},
+			},
+		},
+		{
+			name: "stale approvals kept",
+			protections: []branchProtection{
+				{RuleName: "main", RequiredApprovals: 1, Updated: ruleTime},
+			},
+			tagProtections: []tagProtection{},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: ruleTime},
+			},
+		},
+		{
+			name: "exact rule takes priority over glob",
+			protections: []branchProtection{
+				{RuleName: "*", EnablePush: true, EnableForcePush: true, Updated: ruleTime},
+				{RuleName: "main", RequiredApprovals: 2, DismissStaleApprovals: true, Updated: ruleTime},
+			},
+			tagProtections: []tagProtection{
+				{NamePattern: "*", Updated: pushTime.Add(time.Hour)},
+				{NamePattern: "**", Updated: tagRuleTime},
+			},
+			expectedControls: slsa_types.Controls{
+				{Name: slsa_types.ContinuityEnforced, Since: ruleTime},
+				{Name: slsa_types.ReviewEnforced, Since: ruleTime},
+				{Name: slsa_types.ImmutableTags, Since: tagRuleTime},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newTestGiteaConnection(t, map[string]any{
+				"activities/feeds":   pushActivities(),
+				"branch_protections": tt.protections,
+				"tag_protections":    tt.tagProtections,
+			})
+
+			status, err := gc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("GetBranchControls() error = %v", err)
+			}
+			if !status.CommitPushTime.Equal(pushTime) {
+				t.Errorf("CommitPushTime = %v, want %v", status.CommitPushTime, pushTime)
+			}
+			if status.ActorLogin != "the-pusher" {
+				t.Errorf("ActorLogin = %q, want %q", status.ActorLogin, "the-pusher")
+			}
+			if len(status.Controls) != len(tt.expectedControls) {
+				t.Fatalf("Controls = %v, want %v", status.Controls, tt.expectedControls)
+			}
+			for i, expected := range tt.expectedControls {
+				if status.Controls[i].Name != expected.Name || !status.Controls[i].Since.Equal(expected.Since) {
+					t.Errorf("Controls[%d] = %v, want %v", i, status.Controls[i], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBranchControls_RuleChangedAfterPush(t *testing.T) {
+	gc := newTestGiteaConnection(t, map[string]any{
+		"activities/feeds": pushActivities(),
+		"branch_protections": []branchProtection{
+			{RuleName: "main", Updated: pushTime.Add(time.Hour)},
+		},
+		"tag_protections": []tagProtection{},
+	})
+
+	_, err := gc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+	if err == nil {
+		t.Errorf("GetBranchControls() error = nil, want error for rule updated after the push")
+	}
+}
+
+func TestGetNotesForCommit(t *testing.T) {
+	gc := newTestGiteaConnection(t, map[string]any{
+		"git/notes/abc123": map[string]string{"message": "line1\nline2\n"},
+	})
+
+	notes, err := gc.GetNotesForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v", err)
+	}
+	if notes != "line1\nline2\n" {
+		t.Errorf("GetNotesForCommit() = %q, want %q", notes, "line1\nline2\n")
+	}
+
+	notes, err = gc.GetNotesForCommit(context.Background(), "def456")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v for missing notes", err)
+	}
+	if notes != "" {
+		t.Errorf("GetNotesForCommit() = %q, want empty notes", notes)
+	}
+}
+
+func TestPriorCommit(t *testing.T) {
+	gc := newTestGiteaConnection(t, map[string]any{
+		"git/commits/abc123": commit{Sha: "abc123", Parents: []*commitMeta{{Sha: "prev123"}}},
+	})
+
+	prev, err := source_control.PriorCommit(context.Background(), gc, "abc123")
+	if err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if prev != "prev123" {
+		t.Errorf("PriorCommit() = %q, want %q", prev, "prev123")
+	}
+}
@@ -0,0 +1,181 @@
+package gitea_control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.SourceControlPlatform = (*GiteaConnection)(nil)
+
+// How many results we ask for per page, Gitea's default maximum.
+const pageSize = 50
+
+// Limits how many pages of results we'll fetch from a paged API.
+const maxPages = 20
+
+// Manages a connection to a repository on a Gitea or Forgejo instance.
+type GiteaConnection struct {
+	client           *http.Client
+	baseUrl          *url.URL
+	token            string
+	owner, repo, ref string
+	policySource     source_control.PolicySource
+}
+
+// Creates a connection to owner/repo hosted on the instance at baseUrl.
+// Policies are fetched from policySource.
+func NewGiteaConnection(baseUrl, owner, repo, ref string, policySource source_control.PolicySource) (*GiteaConnection, error) {
+	return NewGiteaConnectionWithClient(baseUrl, owner, repo, ref, policySource, http.DefaultClient)
+}
+
+func NewGiteaConnectionWithClient(baseUrl, owner, repo, ref string, policySource source_control.PolicySource, client *http.Client) (*GiteaConnection, error) {
+	if baseUrl == "" {
+		return nil, errors.New("the url of the Gitea instance must be set")
+	}
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Gitea url %s: %w", baseUrl, err)
+	}
+	return &GiteaConnection{
+		client:       client,
+		baseUrl:      parsedUrl,
+		owner:        owner,
+		repo:         repo,
+		ref:          ref,
+		policySource: policySource}, nil
+}
+
+// Uses the provided access token for auth.
+// If the token is the empty string this is a no-op.
+func (gc *GiteaConnection) WithAuthToken(token string) *GiteaConnection {
+	if token != "" {
+		gc.token = token
+	}
+	return gc
+}
+
+func (gc *GiteaConnection) Owner() string {
+	return gc.owner
+}
+
+func (gc *GiteaConnection) Repo() string {
+	return gc.repo
+}
+
+func (gc *GiteaConnection) GetFullRef() string {
+	return gc.ref
+}
+
+// Returns the URI of the repo this connection tracks.
+func (gc *GiteaConnection) GetRepoUri() string {
+	return fmt.Sprintf("%s/%s/%s", gc.baseUrl.String(), gc.Owner(), gc.Repo())
+}
+
+func (gc *GiteaConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	if gc.policySource == nil {
+		return "", "", fmt.Errorf("no policy source configured for %s", gc.GetRepoUri())
+	}
+	return gc.policySource.GetPolicyFile(ctx, path)
+}
+
+// Returns the url for 'path' within the repo API.
+func (gc *GiteaConnection) repoUrl(path string, query url.Values) string {
+	reqUrl := fmt.Sprintf("%s/api/v1/repos/%s/%s/%s", gc.baseUrl.String(), url.PathEscape(gc.Owner()), url.PathEscape(gc.Repo()), path)
+	if len(query) > 0 {
+		reqUrl = fmt.Sprintf("%s?%s", reqUrl, query.Encode())
+	}
+	return reqUrl
+}
+
+// Returns the headers to send with every request.
+func (gc *GiteaConnection) header() http.Header {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if gc.token != "" {
+		header.Set("Authorization", fmt.Sprintf("token %s", gc.token))
+	}
+	return header
+}
+
+// Issues a GET request for 'path' within the repo API and decodes the JSON result into 'result'.
+// Returns source_control.ErrNotFound if Gitea returns a 404.
+func (gc *GiteaConnection) get(ctx context.Context, path string, query url.Values, result any) error {
+	return source_control.HttpGetJson(ctx, gc.client, gc.repoUrl(path, query), gc.header(), result)
+}
+
+// Calls visit with each page of values from the paged repo API 'path', in order,
+// until it returns false or there are no more pages.
+func visitPaged[T any](ctx context.Context, gc *GiteaConnection, path string, query url.Values, visit func([]T) bool) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	seen := 0
+	for page := 1; page <= maxPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		body, header, err := source_control.HttpGet(ctx, gc.client, gc.repoUrl(path, query), gc.header())
+		if err != nil {
+			return err
+		}
+		var values []T
+		if err := json.Unmarshal(body, &values); err != nil {
+			return err
+		}
+		if len(values) == 0 || !visit(values) {
+			return nil
+		}
+
+		// Gitea reports the total, servers may cap the page size below what we ask for.
+		seen += len(values)
+		total, err := strconv.Atoi(header.Get("X-Total-Count"))
+		if err == nil && seen >= total {
+			return nil
+		}
+	}
+	return fmt.Errorf("too many pages of results from %s", path)
+}
+
+type commitMeta struct {
+	Sha string `json:"sha"`
+}
+
+type commit struct {
+	Sha     string        `json:"sha"`
+	Parents []*commitMeta `json:"parents"`
+}
+
+// Gets the parents of 'sha', in order.
+func (gc *GiteaConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
+	var c commit
+	err := gc.get(ctx, fmt.Sprintf("git/commits/%s", url.PathEscape(sha)), url.Values{"stat": {"false"}}, &c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
+	}
+
+	var parents []string
+	for _, parent := range c.Parents {
+		parents = append(parents, parent.Sha)
+	}
+	return parents, nil
+}
+
+func (gc *GiteaConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
+	var branch struct {
+		Commit struct {
+			Id string `json:"id"`
+		} `json:"commit"`
+	}
+	err := gc.get(ctx, fmt.Sprintf("branches/%s", url.PathEscape(targetBranch)), nil, &branch)
+	if err != nil {
+		return "", fmt.Errorf("could not get info on specified branch %s: %w", targetBranch, err)
+	}
+	return branch.Commit.Id, nil
+}
@@ -0,0 +1,27 @@
+package gitea_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+func (gc *GiteaConnection) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	// Gitea reads notes from `refs/notes/commits` for us.
+	var note struct {
+		Message string `json:"message"`
+	}
+	err := gc.get(ctx, fmt.Sprintf("git/notes/%s", url.PathEscape(commit)), nil, &note)
+	if errors.Is(err, source_control.ErrNotFound) {
+		// Don't freak out if it's not there.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot get note contents for commit %s: %w", commit, err)
+	}
+
+	return note.Message, nil
+}
@@ -0,0 +1,288 @@
+package gitlab_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.UncheckedCarryForward = (*GitLabConnection)(nil)
+
+// GitLab access level that indicates nobody is allowed.
+const noAccess = 0
+
+type pushData struct {
+	Action     string `json:"action"`
+	RefType    string `json:"ref_type"`
+	Ref        string `json:"ref"`
+	CommitFrom string `json:"commit_from"`
+	CommitTo   string `json:"commit_to"`
+}
+
+type event struct {
+	CreatedAt      time.Time `json:"created_at"`
+	AuthorUsername string    `json:"author_username"`
+	PushData       pushData  `json:"push_data"`
+}
+
+// Maps GitLab push actions to the activity types reported by GitHub.
+var activityTypes = map[string]string{
+	"pushed":  "push",
+	"created": "branch_creation",
+	"removed": "branch_deletion",
+}
+
+func (glc *GitLabConnection) commitPushEvent(ctx context.Context, commit, targetRef string) (*event, error) {
+	// Only look at push events within the look-back window, paging through
+	// the results (newest first) until we find the commit or run out of window.
+	// 'after' only has day granularity so we still check the time of each event.
+	oldest := time.Now().Add(-glc.activityLookback)
+	query := url.Values{
+		"action": {"pushed"},
+		"after":  {oldest.AddDate(0, 0, -1).UTC().Format(time.DateOnly)},
+	}
+	var found *event
+	err := visitPaged(ctx, glc, "events", query, func(events []*event) bool {
+		for _, event := range events {
+			if event.CreatedAt.Before(oldest) {
+				return false
+			}
+			if event.PushData.RefType != "branch" {
+				continue
+			}
+			if event.PushData.CommitTo == commit && source_control.BranchToFullRef(event.PushData.Ref) == targetRef {
+				// Found it
+				found = event
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("could not find push event for commit %s and ref %s in the last %v", commit, targetRef, glc.activityLookback)
+	}
+	return found, nil
+}
+
+type accessLevel struct {
+	AccessLevel int `json:"access_level"`
+}
+
+type protectedBranch struct {
+	Name                      string        `json:"name"`
+	PushAccessLevels          []accessLevel `json:"push_access_levels"`
+	AllowForcePush            bool          `json:"allow_force_push"`
+	CodeOwnerApprovalRequired bool          `json:"code_owner_approval_required"`
+}
+
+type approvalRule struct {
+	Name                          string             `json:"name"`
+	ApprovalsRequired             int                `json:"approvals_required"`
+	AppliesToAllProtectedBranches bool               `json:"applies_to_all_protected_branches"`
+	ProtectedBranches             []*protectedBranch `json:"protected_branches"`
+}
+
+type approvalSettings struct {
+	ResetApprovalsOnPush                   bool `json:"reset_approvals_on_push"`
+	MergeRequestsAuthorApproval            bool `json:"merge_requests_author_approval"`
+	MergeRequestsDisableCommittersApproval bool `json:"merge_requests_disable_committers_approval"`
+}
+
+type protectedTag struct {
+	Name string `json:"name"`
+}
+
+// Returns all the protected branch rules that match the branch.
+func (glc *GitLabConnection) getProtectedBranches(ctx context.Context, branch string) ([]*protectedBranch, error) {
+	all, err := getPaged[*protectedBranch](ctx, glc, "protected_branches", nil)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*protectedBranch
+	for _, pb := range all {
+		if source_control.MatchesGlob(source_control.WildcardGlob, pb.Name, branch) {
+			matching = append(matching, pb)
+		}
+	}
+	return matching, nil
+}
+
+// Computes the continuity control returning nil if it's not enabled.
+//
+// Protected branches cannot be deleted with a push, so we just need to
+// make sure force pushes are disabled. When multiple rules match a branch
+// GitLab applies the most restrictive force push setting.
+//
+// GitLab doesn't report when protections were configured, so we can only say
+// the control has been enforced since the push.
+func computeContinuityControl(protectedBranches []*protectedBranch, pushTime time.Time) *slsa_types.Control {
+	for _, pb := range protectedBranches {
+		if !pb.AllowForcePush {
+			return &slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: pushTime}
+		}
+	}
+	if len(protectedBranches) == 0 {
+		log.Printf("branch is not protected, cannot be L2+")
+	}
+	return nil
+}
+
+// Reports if nobody can push directly to the branch, so all changes must come via merge requests.
+// When multiple rules match a branch GitLab applies the most permissive push access.
+func requiresMergeRequests(protectedBranches []*protectedBranch) bool {
+	if len(protectedBranches) == 0 {
+		return false
+	}
+	for _, pb := range protectedBranches {
+		for _, level := range pb.PushAccessLevels {
+			if level.AccessLevel != noAccess {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func requiresCodeOwnerApproval(protectedBranches []*protectedBranch) bool {
+	for _, pb := range protectedBranches {
+		if pb.CodeOwnerApprovalRequired {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleAppliesToBranch(rule *approvalRule, branch string) bool {
+	if rule.AppliesToAllProtectedBranches {
+		return true
+	}
+	for _, pb := range rule.ProtectedBranches {
+		if source_control.MatchesGlob(source_control.WildcardGlob, pb.Name, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// Computes the review control returning nil if it's not enabled.
+//
+// This mirrors what we require of GitHub: changes must come via merge request,
+// at least one approval is required, approvals are reset when new commits are
+// pushed, code owners must approve, and whoever pushed the changes can't approve them.
+func (glc *GitLabConnection) computeReviewControl(ctx context.Context, branch string, protectedBranches []*protectedBranch, pushTime time.Time) (*slsa_types.Control, error) {
+	if !requiresMergeRequests(protectedBranches) || !requiresCodeOwnerApproval(protectedBranches) {
+		return nil, nil
+	}
+
+	var settings approvalSettings
+	err := glc.get(ctx, "approvals", nil, &settings)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.ResetApprovalsOnPush || settings.MergeRequestsAuthorApproval || !settings.MergeRequestsDisableCommittersApproval {
+		return nil, nil
+	}
+
+	rules, err := getPaged[*approvalRule](ctx, glc, "approval_rules", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.ApprovalsRequired > 0 && ruleAppliesToBranch(rule, branch) {
+			return &slsa_types.Control{Name: slsa_types.ReviewEnforced, Since: pushTime}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Computes the immutable tags control returning nil if it's not enabled.
+//
+// Protected tags can't be updated or deleted with a push, so we just need
+// a protected tag rule that covers all tags.
+func (glc *GitLabConnection) computeImmutableTagsControl(ctx context.Context, activityTime time.Time) (*slsa_types.Control, error) {
+	tags, err := getPaged[*protectedTag](ctx, glc, "protected_tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		if tag.Name == "*" {
+			return &slsa_types.Control{Name: slsa_types.ImmutableTags, Since: activityTime}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GitLab can't tell us when protections were bypassed or a branch was force pushed, but the
+// protections we report controls for apply to everyone, so getting around them means changing
+// them. Controls only start at the push here, so without carrying them forward no policy
+// with an earlier start could be met.
+func (glc *GitLabConnection) CarryForwardUnchecked() bool {
+	return true
+}
+
+// Determines the controls that are in place for a branch using GitLab's APIs
+// This is necessarily only as good as GitLab's controls and existing APIs.
+func (glc *GitLabConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	// We want to know when this commit was pushed to ensure the rules were active _then_.
+	event, err := glc.commitPushEvent(ctx, commit, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: event.CreatedAt,
+		ActivityType:   activityTypes[event.PushData.Action],
+		ActorLogin:     event.AuthorUsername,
+		BeforeCommit:   event.PushData.CommitFrom,
+		Controls:       slsa_types.Controls{}}
+
+	branch := source_control.GetBranchFromRef(ref)
+	if branch == "" {
+		return nil, fmt.Errorf("ref %s is not a branch", ref)
+	}
+	protectedBranches, err := glc.getProtectedBranches(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	controlStatus.Controls.AddControl(computeContinuityControl(protectedBranches, event.CreatedAt))
+
+	reviewControl, err := glc.computeReviewControl(ctx, branch, protectedBranches, event.CreatedAt)
+	// Approval settings aren't available on all tiers, if they're missing review isn't enforced.
+	if err != nil && !errors.Is(err, source_control.ErrNotFound) {
+		return nil, fmt.Errorf("could not populate ReviewControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(reviewControl)
+
+	immutableTagsControl, err := glc.computeImmutableTagsControl(ctx, event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
+
+func (glc *GitLabConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	controlStatus := source_control.ControlStatus{
+		CommitPushTime: time.Now(),
+		Controls:       slsa_types.Controls{}}
+
+	immutableTagsControl, err := glc.computeImmutableTagsControl(ctx, controlStatus.CommitPushTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not populate ImmutableTagsControl: %w", err)
+	}
+	controlStatus.Controls.AddControl(immutableTagsControl)
+
+	return &controlStatus, nil
+}
@@ -0,0 +1,320 @@
+package gitlab_control
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+// Push events are only looked for within the look-back window, so this has to be recent.
+var pushTime = time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+
+const testProjectPath = "/api/v4/projects/group%2Fproject/"
+
+// Starts a stand-in for the GitLab REST API that serves 'responses' keyed by
+// the path relative to the project. Strings are served as-is, everything else as JSON.
+// A response may be a func that gets the request and returns the result to serve.
+func newTestGitLabConnection(t *testing.T, responses map[string]any) *GitLabConnection {
+	t.Helper()
+	server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{
+		PathPrefix: testProjectPath,
+		Authenticated: func(r *http.Request) bool {
+			return r.Header.Get("PRIVATE-TOKEN") == "test-token"
+		},
+	}, responses)
+
+	glc, err := NewGitLabConnectionWithClient(server.URL, "group/project", "refs/heads/main", nil, server.Client())
+	if err != nil {
+		t.Fatalf("failed to create connection: %v", err)
+	}
+	return glc.WithAuthToken("test-token")
+}
+
+func pushEvents() []event {
+	return []event{
+		{CreatedAt: pushTime.Add(time.Hour), AuthorUsername: "someone-else",
+			PushData: pushData{Action: "pushed", RefType: "branch", Ref: "other", CommitTo: "abc123"}},
+		{CreatedAt: pushTime, AuthorUsername: "the-pusher",
+			PushData: pushData{Action: "pushed", RefType: "branch", Ref: "main", CommitFrom: "prev123", CommitTo: "abc123"}},
+	}
+}
+
+// Serves 'pages' of results, linking each to the next with X-Next-Page.
+func paged[T any](pages [][]T) func(http.ResponseWriter, *http.Request) any {
+	return func(w http.ResponseWriter, r *http.Request) any {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		if page < len(pages) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		return pages[page-1]
+	}
+}
+
+func TestCommitPushEvent(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	filler := func(count int, at time.Time) []event {
+		var page []event
+		for i := 0; i < count; i++ {
+			page = append(page, event{CreatedAt: at, AuthorUsername: "someone-else",
+				PushData: pushData{Action: "pushed", RefType: "branch", Ref: "main", CommitTo: fmt.Sprintf("other%d", i)}})
+		}
+		return page
+	}
+	target := event{CreatedAt: now.Add(-48 * time.Hour), AuthorUsername: "the-pusher",
+		PushData: pushData{Action: "pushed", RefType: "branch", Ref: "main", CommitTo: "abc123"}}
+
+	tests := []struct {
+		name      string
+		pages     [][]event
+		lookback  time.Duration
+		wantFound bool
+	}{
+		{
+			name:      "found on a later page",
+			pages:     [][]event{filler(100, now), filler(100, now.Add(-time.Hour)), append(filler(3, now.Add(-47*time.Hour)), target)},
+			wantFound: true,
+		},
+		{
+			name:     "older than the look-back window",
+			pages:    [][]event{filler(100, now), {target}},
+			lookback: 24 * time.Hour,
+		},
+		{
+			name:  "never pushed",
+			pages: [][]event{filler(100, now), filler(10, now.Add(-time.Hour))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			glc := newTestGitLabConnection(t, map[string]any{
+				"events": paged(tt.pages),
+			}).WithActivityLookback(tt.lookback)
+
+			got, err := glc.commitPushEvent(context.Background(), "abc123", "refs/heads/main")
+			if !tt.wantFound {
+				if err == nil {
+					t.Fatalf("commitPushEvent() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("commitPushEvent() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, target) {
+				t.Errorf("commitPushEvent() = %v, want %v", *got, target)
+			}
+		})
+	}
+}
+
+func TestGetProtectedBranches_Paged(t *testing.T) {
+	var firstPage []protectedBranch
+	for i := 0; i < 100; i++ {
+		firstPage = append(firstPage, protectedBranch{Name: fmt.Sprintf("release-%d", i)})
+	}
+	pages := [][]protectedBranch{firstPage, {{Name: "main", AllowForcePush: true}}}
+	glc := newTestGitLabConnection(t, map[string]any{
+		"protected_branches": paged(pages),
+	})
+
+	got, err := glc.getProtectedBranches(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("getProtectedBranches() error = %v", err)
+	}
+	want := []*protectedBranch{{Name: "main", AllowForcePush: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getProtectedBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestGetBranchControls(t *testing.T) {
+	fullyProtected := protectedBranch{
+		Name:                      "main",
+		PushAccessLevels:          []accessLevel{{AccessLevel: noAccess}},
+		CodeOwnerApprovalRequired: true,
+	}
+	reviewSettings := approvalSettings{
+		ResetApprovalsOnPush:                   true,
+		MergeRequestsDisableCommittersApproval: true,
+	}
+	requiredApproval := []approvalRule{{Name: "All", ApprovalsRequired: 1, AppliesToAllProtectedBranches: true}}
+
+	tests := []struct {
+		name             string
+		responses        map[string]any
+		expectedControls []string
+	}{
+		{
+			name: "unprotected branch",
+			responses: map[string]any{
+				"events":             pushEvents(),
+				"protected_branches": []protectedBranch{},
+				"protected_tags":     []protectedTag{},
+			},
+			expectedControls: []string{},
+		},
+		{
+			name: "force push allowed",
+			responses: map[string]any{
+				"events":             pushEvents(),
+				"protected_branches": []protectedBranch{{Name: "main", AllowForcePush: true}},
+				"protected_tags":     []protectedTag{},
+			},
+			expectedControls: []string{},
+		},
+		{
+			name: "wildcard protection without review",
+			responses: map[string]any{
+				"events": pushEvents(),
+				"protected_branches": []protectedBranch{
+					{Name: "ma*", PushAccessLevels: []accessLevel{{AccessLevel: 40}}},
+				},
+				"protected_tags": []protectedTag{{Name: "v*"}},
+			},
+			expectedControls: []string{slsa_types.ContinuityEnforced},
+		},
+		{
+			name: "review settings allow author approval",
+			responses: map[string]any{
+				"events":             pushEvents(),
+				"protected_branches": []protectedBranch{fullyProtected},
+				"approvals": approvalSettings{
+					ResetApprovalsOnPush:                   true,
+					MergeRequestsAuthorApproval:            true,
+					MergeRequestsDisableCommittersApproval: true,
+				},
+				"approval_rules": requiredApproval,
+				"protected_tags": []protectedTag{},
+			},
+			expectedControls: []string{slsa_types.ContinuityEnforced},
+		},
+		{
+			name: "approval rules unavailable",
+			responses: map[string]any{
+				"events":             pushEvents(),
+				"protected_branches": []protectedBranch{fullyProtected},
+				"approvals":          reviewSettings,
+				"protected_tags":     []protectedTag{},
+			},
+			expectedControls: []string{slsa_types.ContinuityEnforced},
+		},
+		{
+			name: "all controls",
+			responses: map[string]any{
+				"events":             pushEvents(),
+				"protected_branches": []protectedBranch{fullyProtected},
+				"approvals":          reviewSettings,
+				"approval_rules":     requiredApproval,
+				"protected_tags":     []protectedTag{{Name: "*"}},
+			},
+			expectedControls: []string{slsa_types.ContinuityEnforced, slsa_types.ReviewEnforced, slsa_types.ImmutableTags},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			glc := newTestGitLabConnection(t, tt.responses)
+
+			status, err := glc.GetBranchControls(context.Background(), "abc123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("GetBranchControls() error = %v", err)
+			}
+			if !status.CommitPushTime.Equal(pushTime) {
+				t.Errorf("CommitPushTime = %v, want %v", status.CommitPushTime, pushTime)
+			}
+			if status.ActorLogin != "the-pusher" {
+				t.Errorf("ActorLogin = %q, want %q", status.ActorLogin, "the-pusher")
+			}
+			if status.ActivityType != "push" {
+				t.Errorf("ActivityType = %q, want %q", status.ActivityType, "push")
+			}
+			if status.BeforeCommit != "prev123" {
+				t.Errorf("BeforeCommit = %q, want %q", status.BeforeCommit, "prev123")
+			}
+			if len(status.Controls) != len(tt.expectedControls) {
+				t.Fatalf("Controls = %v, want %v", status.Controls, tt.expectedControls)
+			}
+			for i, name := range tt.expectedControls {
+				if status.Controls[i].Name != name {
+					t.Errorf("Controls[%d] = %v, want %v", i, status.Controls[i].Name, name)
+				}
+				if !status.Controls[i].Since.Equal(pushTime) {
+					t.Errorf("Controls[%d].Since = %v, want %v", i, status.Controls[i].Since, pushTime)
+				}
+			}
+		})
+	}
+}
+
+func TestGetBranchControls_NoPushEvent(t *testing.T) {
+	glc := newTestGitLabConnection(t, map[string]any{"events": pushEvents()})
+
+	_, err := glc.GetBranchControls(context.Background(), "def456", "refs/heads/main")
+	if err == nil {
+		t.Errorf("GetBranchControls() error = nil, want error for missing push event")
+	}
+}
+
+func TestGetNotesForCommit(t *testing.T) {
+	glc := newTestGitLabConnection(t, map[string]any{
+		"repository/files/abc123/raw": "line1\nline2\n",
+	})
+
+	notes, err := glc.GetNotesForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v", err)
+	}
+	if notes != "line1\nline2\n" {
+		t.Errorf("GetNotesForCommit() = %q, want %q", notes, "line1\nline2\n")
+	}
+
+	notes, err = glc.GetNotesForCommit(context.Background(), "def456")
+	if err != nil {
+		t.Fatalf("GetNotesForCommit() error = %v for missing notes", err)
+	}
+	if notes != "" {
+		t.Errorf("GetNotesForCommit() = %q, want empty notes", notes)
+	}
+}
+
+func TestPriorCommit(t *testing.T) {
+	glc := newTestGitLabConnection(t, map[string]any{
+		"repository/commits/abc123": commit{Id: "abc123", ParentIds: []string{"prev123"}},
+		"repository/commits/merge1": commit{Id: "merge1", ParentIds: []string{"p1", "p2"}},
+	})
+
+	prev, err := source_control.PriorCommit(context.Background(), glc, "abc123")
+	if err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if prev != "prev123" {
+		t.Errorf("PriorCommit() = %q, want %q", prev, "prev123")
+	}
+
+	// Merge commits follow the branch they were merged into.
+	prev, err = source_control.PriorCommit(context.Background(), glc, "merge1")
+	if err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if prev != "p1" {
+		t.Errorf("PriorCommit() = %q, want %q", prev, "p1")
+	}
+	parents, err := glc.GetParents(context.Background(), "merge1")
+	if err != nil {
+		t.Fatalf("GetParents() error = %v", err)
+	}
+	if !reflect.DeepEqual(parents, []string{"p1", "p2"}) {
+		t.Errorf("GetParents() = %v, want %v", parents, []string{"p1", "p2"})
+	}
+}
@@ -0,0 +1,192 @@
+package gitlab_control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+const DefaultBaseUrl = "https://gitlab.com"
+
+// How far back we look for the event that pushed a commit by default.
+const DefaultActivityLookback = 7 * 24 * time.Hour
+
+// Limits how many pages of results we'll fetch from a paged API.
+const maxPages = 20
+
+var _ source_control.SourceControlPlatform = (*GitLabConnection)(nil)
+
+// Manages a connection to a GitLab project.
+type GitLabConnection struct {
+	client           *http.Client
+	baseUrl          *url.URL
+	token            string
+	project, ref     string
+	policySource     source_control.PolicySource
+	activityLookback time.Duration
+}
+
+// Creates a connection to 'project' (e.g. "group/subgroup/project") hosted at baseUrl.
+// Policies are fetched from policySource.
+func NewGitLabConnection(baseUrl, project, ref string, policySource source_control.PolicySource) (*GitLabConnection, error) {
+	return NewGitLabConnectionWithClient(baseUrl, project, ref, policySource, http.DefaultClient)
+}
+
+func NewGitLabConnectionWithClient(baseUrl, project, ref string, policySource source_control.PolicySource, client *http.Client) (*GitLabConnection, error) {
+	if baseUrl == "" {
+		baseUrl = DefaultBaseUrl
+	}
+	parsedUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitLab url %s: %w", baseUrl, err)
+	}
+	return &GitLabConnection{
+		client:           client,
+		baseUrl:          parsedUrl,
+		project:          project,
+		ref:              ref,
+		policySource:     policySource,
+		activityLookback: DefaultActivityLookback}, nil
+}
+
+// Uses the provided personal/project access token for auth.
+// If the token is the empty string this is a no-op.
+func (glc *GitLabConnection) WithAuthToken(token string) *GitLabConnection {
+	if token != "" {
+		glc.token = token
+	}
+	return glc
+}
+
+// Looks this far back for the event that pushed a commit.
+// If lookback isn't positive this is a no-op.
+func (glc *GitLabConnection) WithActivityLookback(lookback time.Duration) *GitLabConnection {
+	if lookback > 0 {
+		glc.activityLookback = lookback
+	}
+	return glc
+}
+
+func (glc *GitLabConnection) Project() string {
+	return glc.project
+}
+
+func (glc *GitLabConnection) GetFullRef() string {
+	return glc.ref
+}
+
+// Returns the URI of the project this connection tracks.
+func (glc *GitLabConnection) GetRepoUri() string {
+	return fmt.Sprintf("%s/%s", glc.baseUrl.String(), glc.project)
+}
+
+func (glc *GitLabConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	if glc.policySource == nil {
+		return "", "", fmt.Errorf("no policy source configured for %s", glc.GetRepoUri())
+	}
+	return glc.policySource.GetPolicyFile(ctx, path)
+}
+
+// Returns the API url for 'path' within this project.
+func (glc *GitLabConnection) projectUrl(path string, query url.Values) string {
+	reqUrl := fmt.Sprintf("%s/api/v4/projects/%s/%s", glc.baseUrl.String(), url.PathEscape(glc.project), path)
+	if len(query) > 0 {
+		reqUrl = fmt.Sprintf("%s?%s", reqUrl, query.Encode())
+	}
+	return reqUrl
+}
+
+// Returns the headers to authenticate requests with.
+func (glc *GitLabConnection) authHeader() http.Header {
+	header := http.Header{}
+	if glc.token != "" {
+		header.Set("PRIVATE-TOKEN", glc.token)
+	}
+	return header
+}
+
+// Issues a GET request for the project API 'path', returning the raw body.
+// Returns source_control.ErrNotFound if GitLab returns a 404.
+func (glc *GitLabConnection) getRaw(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	body, _, err := source_control.HttpGet(ctx, glc.client, glc.projectUrl(path, query), glc.authHeader())
+	return body, err
+}
+
+// Issues a GET request for the project API 'path' and decodes the JSON result into 'result'.
+func (glc *GitLabConnection) get(ctx context.Context, path string, query url.Values, result any) error {
+	return source_control.HttpGetJson(ctx, glc.client, glc.projectUrl(path, query), glc.authHeader(), result)
+}
+
+// Calls visit with each page of values from the paged project API 'path', in order,
+// until it returns false or there are no more pages.
+func visitPaged[T any](ctx context.Context, glc *GitLabConnection, path string, query url.Values, visit func([]T) bool) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", "100")
+	for i := 0; i < maxPages; i++ {
+		body, header, err := source_control.HttpGet(ctx, glc.client, glc.projectUrl(path, query), glc.authHeader())
+		if err != nil {
+			return err
+		}
+		var values []T
+		if err := json.Unmarshal(body, &values); err != nil {
+			return err
+		}
+		if !visit(values) {
+			return nil
+		}
+
+		next := header.Get("X-Next-Page")
+		if next == "" {
+			return nil
+		}
+		query.Set("page", next)
+	}
+	return fmt.Errorf("too many pages of results from %s", path)
+}
+
+// Gets all the values from the paged project API 'path'.
+func getPaged[T any](ctx context.Context, glc *GitLabConnection, path string, query url.Values) ([]T, error) {
+	var all []T
+	err := visitPaged(ctx, glc, path, query, func(values []T) bool {
+		all = append(all, values...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+type commit struct {
+	Id        string   `json:"id"`
+	ParentIds []string `json:"parent_ids"`
+}
+
+// Gets the parents of 'sha', in order.
+func (glc *GitLabConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
+	var c commit
+	err := glc.get(ctx, fmt.Sprintf("repository/commits/%s", url.PathEscape(sha)), nil, &c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
+	}
+	return c.ParentIds, nil
+}
+
+func (glc *GitLabConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
+	var branch struct {
+		Commit commit `json:"commit"`
+	}
+	err := glc.get(ctx, fmt.Sprintf("repository/branches/%s", url.PathEscape(targetBranch)), nil, &branch)
+	if err != nil {
+		return "", fmt.Errorf("could not get info on specified branch %s: %w", targetBranch, err)
+	}
+	return branch.Commit.Id, nil
+}
@@ -0,0 +1,26 @@
+package gitlab_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+func (glc *GitLabConnection) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	// Notes for a given commit are stored at the path <commit> within ref `refs/notes/commits`.
+	contents, err := glc.getRaw(ctx,
+		fmt.Sprintf("repository/files/%s/raw", url.PathEscape(commit)),
+		url.Values{"ref": {"refs/notes/commits"}})
+	if errors.Is(err, source_control.ErrNotFound) {
+		// Don't freak out if it's not there.
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot get note contents for commit %s: %w", commit, err)
+	}
+
+	return string(contents), nil
+}
@@ -0,0 +1,21 @@
+package local_control
+
+import (
+	"context"
+	"errors"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// Returned when asked for information only the forge hosting the repo knows.
+var ErrControlsUnavailable = errors.New("controls can't be determined from a local clone, use the platform hosting the repo")
+
+// A local clone has no idea what the forge enforces, so this always fails.
+func (lc *LocalConnection) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	return nil, ErrControlsUnavailable
+}
+
+// A local clone has no idea what the forge enforces, so this always fails.
+func (lc *LocalConnection) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	return nil, ErrControlsUnavailable
+}
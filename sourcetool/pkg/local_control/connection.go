@@ -0,0 +1,129 @@
+package local_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var _ source_control.SourceControlPlatform = (*LocalConnection)(nil)
+
+// Operates on a local clone of a repo using go-git, without talking to any forge API.
+type LocalConnection struct {
+	repo         *git.Repository
+	repoUri, ref string
+	policySource source_control.PolicySource
+}
+
+// Opens the clone at 'path'. If 'repoUri' is empty it's derived from the 'origin' remote.
+// Policies are fetched from policySource, which may be nil when working offline
+// with a local policy.
+func NewLocalConnection(path, repoUri, ref string, policySource source_control.PolicySource) (*LocalConnection, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("could not open git repo at %s: %w", path, err)
+	}
+
+	if repoUri == "" {
+		remote, err := repo.Remote(git.DefaultRemoteName)
+		if err != nil {
+			return nil, fmt.Errorf("could not determine the repo uri from the %s remote: %w", git.DefaultRemoteName, err)
+		}
+		repoUri, err = remoteToRepoUri(remote.Config().URLs[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &LocalConnection{
+		repo:         repo,
+		repoUri:      repoUri,
+		ref:          ref,
+		policySource: policySource}, nil
+}
+
+// Converts a remote url like git@github.com:owner/repo.git to https://github.com/owner/repo.
+func remoteToRepoUri(remoteUrl string) (string, error) {
+	remoteUrl = strings.TrimSuffix(strings.TrimSuffix(remoteUrl, "/"), ".git")
+
+	// scp-like syntax, user@host:path
+	if !strings.Contains(remoteUrl, "://") {
+		host, path, found := strings.Cut(remoteUrl, ":")
+		if !found {
+			return "", fmt.Errorf("cannot determine repo uri from remote %s", remoteUrl)
+		}
+		if idx := strings.LastIndex(host, "@"); idx >= 0 {
+			host = host[idx+1:]
+		}
+		return fmt.Sprintf("https://%s/%s", host, strings.TrimPrefix(path, "/")), nil
+	}
+
+	parsed, err := url.Parse(remoteUrl)
+	if err != nil {
+		return "", fmt.Errorf("cannot determine repo uri from remote %s: %w", remoteUrl, err)
+	}
+	if parsed.Scheme == "file" || parsed.Host == "" {
+		return "", fmt.Errorf("cannot determine repo uri from local remote %s", remoteUrl)
+	}
+	host := parsed.Host
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		// Ports for other protocols (e.g. ssh) don't carry over.
+		host = parsed.Hostname()
+	}
+	return fmt.Sprintf("https://%s%s", host, parsed.Path), nil
+}
+
+func (lc *LocalConnection) GetFullRef() string {
+	return lc.ref
+}
+
+// Returns the URI of the repo this clone is of.
+func (lc *LocalConnection) GetRepoUri() string {
+	return lc.repoUri
+}
+
+func (lc *LocalConnection) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	if lc.policySource == nil {
+		return "", "", errors.New("no policy source configured for the local repo, use a local policy instead")
+	}
+	return lc.policySource.GetPolicyFile(ctx, path)
+}
+
+// Gets the parents of 'sha', in order.
+func (lc *LocalConnection) GetParents(ctx context.Context, sha string) ([]string, error) {
+	c, err := lc.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit data for %s: %w", sha, err)
+	}
+
+	var parents []string
+	for _, parent := range c.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+	return parents, nil
+}
+
+// Gets the commit at the tip of the local branch, falling back to the
+// remote-tracking branch if it hasn't been checked out.
+func (lc *LocalConnection) GetLatestCommit(ctx context.Context, targetBranch string) (string, error) {
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(targetBranch),
+		plumbing.NewRemoteReferenceName(git.DefaultRemoteName, targetBranch),
+	} {
+		ref, err := lc.repo.Reference(name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not resolve %s: %w", name, err)
+		}
+		return ref.Hash().String(), nil
+	}
+	return "", fmt.Errorf("could not find branch %s", targetBranch)
+}
@@ -0,0 +1,215 @@
+package local_control
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+var testSignature = &object.Signature{Name: "tester", Email: "tester@example.com", When: time.Unix(1678886400, 0)}
+
+// Creates a repo with two commits on main and an origin remote, returning the path and the commits.
+func newTestRepo(t *testing.T) (string, *git.Repository, []plumbing.Hash) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:owner/repo.git"}})
+	if err != nil {
+		t.Fatalf("failed to create remote: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %v", err)
+	}
+
+	var commits []plumbing.Hash
+	for _, contents := range []string{"first", "second"} {
+		if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := worktree.Add("file.txt"); err != nil {
+			t.Fatalf("failed to add file: %v", err)
+		}
+		hash, err := worktree.Commit(contents, &git.CommitOptions{Author: testSignature})
+		if err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+		commits = append(commits, hash)
+	}
+	return dir, repo, commits
+}
+
+// Stores 'notes' (keyed by path in the notes tree) as the notes ref 'name'.
+func addNotes(t *testing.T, repo *git.Repository, name string, notes map[string]string) {
+	t.Helper()
+	storeObject := func(obj interface {
+		Encode(plumbing.EncodedObject) error
+	}) plumbing.Hash {
+		encoded := repo.Storer.NewEncodedObject()
+		if err := obj.Encode(encoded); err != nil {
+			t.Fatalf("failed to encode object: %v", err)
+		}
+		hash, err := repo.Storer.SetEncodedObject(encoded)
+		if err != nil {
+			t.Fatalf("failed to store object: %v", err)
+		}
+		return hash
+	}
+
+	// Build the tree bottom up, only one level of directories is needed here.
+	subtrees := map[string]*object.Tree{}
+	root := &object.Tree{}
+	for path, contents := range notes {
+		blob := repo.Storer.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, _ := blob.Writer()
+		_, _ = w.Write([]byte(contents))
+		w.Close()
+		blobHash, err := repo.Storer.SetEncodedObject(blob)
+		if err != nil {
+			t.Fatalf("failed to store blob: %v", err)
+		}
+
+		dir, file := filepath.Split(path)
+		entry := object.TreeEntry{Name: file, Mode: filemode.Regular, Hash: blobHash}
+		if dir == "" {
+			root.Entries = append(root.Entries, entry)
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if subtrees[dir] == nil {
+			subtrees[dir] = &object.Tree{}
+		}
+		subtrees[dir].Entries = append(subtrees[dir].Entries, entry)
+	}
+	for dir, tree := range subtrees {
+		root.Entries = append(root.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: storeObject(tree)})
+	}
+
+	// Git requires tree entries to be sorted.
+	sort.Slice(root.Entries, func(i, j int) bool { return root.Entries[i].Name < root.Entries[j].Name })
+
+	commitHash := storeObject(&object.Commit{
+		Author: *testSignature, Committer: *testSignature, Message: "notes", TreeHash: storeObject(root)})
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), commitHash)); err != nil {
+		t.Fatalf("failed to set notes ref: %v", err)
+	}
+}
+
+func TestGetPriorAndLatestCommit(t *testing.T) {
+	dir, _, commits := newTestRepo(t)
+	lc, err := NewLocalConnection(dir, "", "refs/heads/main", nil)
+	if err != nil {
+		t.Fatalf("NewLocalConnection() error = %v", err)
+	}
+
+	if uri := lc.GetRepoUri(); uri != "https://github.com/owner/repo" {
+		t.Errorf("GetRepoUri() = %q, want %q", uri, "https://github.com/owner/repo")
+	}
+
+	latest, err := lc.GetLatestCommit(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("GetLatestCommit() error = %v", err)
+	}
+	if latest != commits[1].String() {
+		t.Errorf("GetLatestCommit() = %q, want %q", latest, commits[1])
+	}
+
+	prior, err := source_control.PriorCommit(context.Background(), lc, latest)
+	if err != nil {
+		t.Fatalf("PriorCommit() error = %v", err)
+	}
+	if prior != commits[0].String() {
+		t.Errorf("PriorCommit() = %q, want %q", prior, commits[0])
+	}
+
+	if _, err := source_control.PriorCommit(context.Background(), lc, prior); err == nil {
+		t.Errorf("PriorCommit() error = nil, want error for the root commit")
+	}
+	if _, err := lc.GetLatestCommit(context.Background(), "missing"); err == nil {
+		t.Errorf("GetLatestCommit() error = nil, want error for a missing branch")
+	}
+}
+
+func TestGetNotesForCommit(t *testing.T) {
+	dir, repo, commits := newTestRepo(t)
+	first, second := commits[0].String(), commits[1].String()
+	addNotes(t, repo, "refs/notes/commits", map[string]string{first: "line1\n"})
+	addNotes(t, repo, "refs/notes/a-mirror", map[string]string{
+		first:                         "line2\n",
+		second[:2] + "/" + second[2:]: "fanned out\n",
+	})
+
+	lc, err := NewLocalConnection(dir, "https://example.com/repo", "refs/heads/main", nil)
+	if err != nil {
+		t.Fatalf("NewLocalConnection() error = %v", err)
+	}
+
+	tests := []struct {
+		commit, expected string
+	}{
+		{first, "line1\nline2\n"},
+		{second, "fanned out\n"},
+		{"0123456789012345678901234567890123456789", ""},
+	}
+	for _, tt := range tests {
+		notes, err := lc.GetNotesForCommit(context.Background(), tt.commit)
+		if err != nil {
+			t.Fatalf("GetNotesForCommit(%s) error = %v", tt.commit, err)
+		}
+		if notes != tt.expected {
+			t.Errorf("GetNotesForCommit(%s) = %q, want %q", tt.commit, notes, tt.expected)
+		}
+	}
+}
+
+func TestGetBranchControls(t *testing.T) {
+	dir, _, commits := newTestRepo(t)
+	lc, err := NewLocalConnection(dir, "", "refs/heads/main", nil)
+	if err != nil {
+		t.Fatalf("NewLocalConnection() error = %v", err)
+	}
+	_, err = lc.GetBranchControls(context.Background(), commits[1].String(), "refs/heads/main")
+	if !errors.Is(err, ErrControlsUnavailable) {
+		t.Errorf("GetBranchControls() error = %v, want %v", err, ErrControlsUnavailable)
+	}
+}
+
+func TestRemoteToRepoUri(t *testing.T) {
+	tests := []struct {
+		remote, expected string
+	}{
+		{"git@github.com:owner/repo.git", "https://github.com/owner/repo"},
+		{"https://github.com/owner/repo.git", "https://github.com/owner/repo"},
+		{"https://user@gitlab.example.com:8443/group/sub/project", "https://gitlab.example.com:8443/group/sub/project"},
+		{"ssh://git@bitbucket.org:7999/workspace/repo.git", "https://bitbucket.org/workspace/repo"},
+	}
+	for _, tt := range tests {
+		uri, err := remoteToRepoUri(tt.remote)
+		if err != nil {
+			t.Fatalf("remoteToRepoUri(%q) error = %v", tt.remote, err)
+		}
+		if uri != tt.expected {
+			t.Errorf("remoteToRepoUri(%q) = %q, want %q", tt.remote, uri, tt.expected)
+		}
+	}
+	if _, err := remoteToRepoUri("/some/local/path"); err == nil {
+		t.Errorf("remoteToRepoUri() error = nil, want error for a local path")
+	}
+}
@@ -0,0 +1,96 @@
+package local_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// The notes ref attestations are normally stored in.
+const defaultNotesRef = "refs/notes/commits"
+
+// Gets the notes stored for the commit in every refs/notes/* ref, starting with
+// refs/notes/commits. The notes are concatenated so that attestations copied
+// into other notes refs (e.g. when fetched from a mirror) are also found.
+func (lc *LocalConnection) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	refs, err := lc.notesRefs()
+	if err != nil {
+		return "", err
+	}
+
+	var notes []string
+	for _, ref := range refs {
+		note, err := lc.getNote(ref, commit)
+		if err != nil {
+			return "", fmt.Errorf("cannot get note contents for commit %s from %s: %w", commit, ref.Name(), err)
+		}
+		if note != "" {
+			notes = append(notes, strings.TrimSuffix(note, "\n"))
+		}
+	}
+	if len(notes) == 0 {
+		return "", nil
+	}
+	return strings.Join(notes, "\n") + "\n", nil
+}
+
+// Lists the notes refs with refs/notes/commits first.
+func (lc *LocalConnection) notesRefs() ([]*plumbing.Reference, error) {
+	iter, err := lc.repo.References()
+	if err != nil {
+		return nil, fmt.Errorf("could not list refs: %w", err)
+	}
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(ref.Name().String(), "refs/notes/") {
+			refs = append(refs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list refs: %w", err)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if (refs[i].Name() == defaultNotesRef) != (refs[j].Name() == defaultNotesRef) {
+			return refs[i].Name() == defaultNotesRef
+		}
+		return refs[i].Name() < refs[j].Name()
+	})
+	return refs, nil
+}
+
+// Gets the note for 'commit' from the notes ref, "" if there isn't one.
+func (lc *LocalConnection) getNote(ref *plumbing.Reference, commit string) (string, error) {
+	notesCommit, err := lc.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return "", err
+	}
+	tree, err := notesCommit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	// Git fans notes out into directories once there are lots of them.
+	paths := []string{commit}
+	if len(commit) > 4 {
+		paths = append(paths,
+			fmt.Sprintf("%s/%s", commit[:2], commit[2:]),
+			fmt.Sprintf("%s/%s/%s", commit[:2], commit[2:4], commit[4:]))
+	}
+	for _, path := range paths {
+		file, err := tree.File(path)
+		if errors.Is(err, object.ErrFileNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return file.Contents()
+	}
+	return "", nil
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	spb "github.com/in-toto/attestation/go/v1"
@@ -15,14 +17,15 @@ import (
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
 
 	"github.com/go-git/go-git/v5"
 )
 
 const (
 	SourcePolicyUri       = "github.com/slsa-framework/slsa-source-poc"
-	SourcePolicyRepoOwner = "slsa-framework"
-	SourcePolicyRepo      = "slsa-source-poc"
+	SourcePolicyRepoOwner = gh_control.SourcePolicyRepoOwner
+	SourcePolicyRepo      = gh_control.SourcePolicyRepo
 )
 
 // When a branch requires multiple controls, they must all be enabled
@@ -32,12 +35,45 @@ type ProtectedBranch struct {
 	Since                 time.Time
 	TargetSlsaSourceLevel slsa_types.SlsaSourceLevel `json:"target_slsa_source_level"`
 	RequireReview         bool                       `json:"require_review"`
+	// The status checks that must be required before commits are pushed to the branch.
+	// A check without an integration_id may come from any app.
+	RequiredStatusChecks []slsa_types.StatusCheck `json:"required_status_checks"`
+	// If the required status checks must have passed on each commit according to its provenance.
+	RequirePassingChecks bool `json:"require_passing_checks"`
+	// If the branch must require signed commits, and each commit's provenance must show
+	// its signature verified. Commits signed with gitsign only pass if their signature
+	// embeds its Rekor entry.
+	RequireSignedCommits bool `json:"require_signed_commits"`
+	// If the branch must require a linear history (i.e. no merge commits).
+	RequireLinearHistory bool `json:"require_linear_history"`
+	// If changes must be merged to the branch through a merge queue.
+	RequireMergeQueue bool `json:"require_merge_queue"`
+	// If each commit must have been merged from a pull request approved by someone other
+	// than its author and the authors of its commits, according to its provenance.
+	RequireIndependentApproval bool `json:"require_independent_approval"`
+	// If each commit must have been merged from a pull request where every changed file
+	// has code owners and one of them approved, according to its provenance.
+	RequireCodeOwnerApproval bool `json:"require_code_owner_approval"`
 }
 
 // The controls required for protected tags.
 type ProtectedTag struct {
 	Since         time.Time
 	ImmutableTags bool `json:"immutable_tags"`
+	// The tags (e.g. 'v*') the controls are required for, using the same fnmatch
+	// syntax as GitHub rulesets. If unset they're required for all tags.
+	TagPatterns []string `json:"tag_patterns"`
+}
+
+// Reports if the tag policy applies to 'ref'.
+func (pt *ProtectedTag) coversTag(ref string) bool {
+	if len(pt.TagPatterns) == 0 {
+		return true
+	}
+	tag := source_control.GetTagFromRef(ref)
+	return slices.ContainsFunc(pt.TagPatterns, func(pattern string) bool {
+		return source_control.MatchesGlob(source_control.FnmatchGlob, pattern, tag)
+	})
 }
 
 type RepoPolicy struct {
@@ -45,6 +81,9 @@ type RepoPolicy struct {
 	CanonicalRepo     string            `json:"canonical_repo"`
 	ProtectedBranches []ProtectedBranch `json:"protected_branches"`
 	ProtectedTag      *ProtectedTag     `json:"protected_tag"`
+	// The only actors that may be able to bypass the rules enforcing controls
+	// (e.g. the role used for safe expunging). If unset anyone may.
+	AllowedBypassActors []slsa_types.BypassActor `json:"allowed_bypass_actors"`
 }
 
 // Returns the policy for the branch or nil if the branch doesn't have one.
@@ -57,6 +96,28 @@ func (rp *RepoPolicy) getBranchPolicy(branch string) *ProtectedBranch {
 	return nil
 }
 
+// Returns the controls that only actors allowed by the policy can bypass.
+// Controls others could bypass aren't enforced as far as the policy is concerned.
+func (rp *RepoPolicy) removeBypassableControls(controls slsa_types.Controls) slsa_types.Controls {
+	if rp.AllowedBypassActors == nil {
+		return controls
+	}
+	var enforced slsa_types.Controls
+	for _, control := range controls {
+		disallowed := slices.IndexFunc(control.BypassActors, func(actor slsa_types.BypassActor) bool {
+			return !slices.ContainsFunc(rp.AllowedBypassActors, func(allowed slsa_types.BypassActor) bool {
+				return allowed.Permits(actor)
+			})
+		})
+		if disallowed >= 0 {
+			log.Printf("ignoring control %s, it can be bypassed by %+v which the policy doesn't allow", control.Name, control.BypassActors[disallowed])
+			continue
+		}
+		enforced = append(enforced, control)
+	}
+	return enforced
+}
+
 func createDefaultBranchPolicy(branch string) *ProtectedBranch {
 	return &ProtectedBranch{
 		Name:                  branch,
@@ -65,37 +126,35 @@ func createDefaultBranchPolicy(branch string) *ProtectedBranch {
 		RequireReview:         false}
 }
 
-func getPolicyPath(gh_connection *gh_control.GitHubConnection) string {
-	return fmt.Sprintf("policy/github.com/%s/%s/source-policy.json", gh_connection.Owner(), gh_connection.Repo())
+// Policies are stored at a path based on the repo's URI,
+// e.g. policy/github.com/owner/repo/source-policy.json
+func getPolicyPath(platform source_control.SourceControlPlatform) string {
+	repoPath := strings.TrimPrefix(platform.GetRepoUri(), "https://")
+	return fmt.Sprintf("policy/%s/source-policy.json", repoPath)
 }
 
-func getPolicyRepoPath(pathToClone string, gh_connection *gh_control.GitHubConnection) string {
-	return fmt.Sprintf("%s/%s", pathToClone, getPolicyPath(gh_connection))
+func getPolicyRepoPath(pathToClone string, platform source_control.SourceControlPlatform) string {
+	return fmt.Sprintf("%s/%s", pathToClone, getPolicyPath(platform))
 }
 
 // If we can't find a policy we return a nil policy.
-func getRemotePolicy(ctx context.Context, gh_connection *gh_control.GitHubConnection) (*RepoPolicy, string, error) {
-	path := getPolicyPath(gh_connection)
-
-	policyContents, _, resp, err := gh_connection.Client().Repositories.GetContents(ctx, SourcePolicyRepoOwner, SourcePolicyRepo, path, nil)
-	if resp != nil && resp.StatusCode == http.StatusNotFound {
-		return nil, "", nil
-	}
+func getRemotePolicy(ctx context.Context, platform source_control.SourceControlPlatform) (*RepoPolicy, string, error) {
+	path := getPolicyPath(platform)
 
+	content, policyUri, err := platform.GetPolicyFile(ctx, path)
 	if err != nil {
 		return nil, "", err
 	}
-
-	content, err := policyContents.GetContent()
-	if err != nil {
-		return nil, "", err
+	if content == "" {
+		return nil, "", nil
 	}
+
 	var p RepoPolicy
 	err = json.Unmarshal([]byte(content), &p)
 	if err != nil {
 		return nil, "", err
 	}
-	return &p, *policyContents.HTMLURL, nil
+	return &p, policyUri, nil
 }
 
 func getLocalPolicy(path string) (*RepoPolicy, string, error) {
@@ -112,16 +171,16 @@ func getLocalPolicy(path string) (*RepoPolicy, string, error) {
 	return &p, path, nil
 }
 
-func (pe PolicyEvaluator) getPolicy(ctx context.Context, gh_connection *gh_control.GitHubConnection) (*RepoPolicy, string, error) {
+func (pe PolicyEvaluator) getPolicy(ctx context.Context, platform source_control.SourceControlPlatform) (*RepoPolicy, string, error) {
 	if pe.UseLocalPolicy == "" {
-		return getRemotePolicy(ctx, gh_connection)
+		return getRemotePolicy(ctx, platform)
 	}
 	return getLocalPolicy(pe.UseLocalPolicy)
 }
 
 // Check to see if the local directory is a clean clone or not
 // TODO: Check if the policy exists remotely.
-func checkLocalDir(ctx context.Context, gh_connection *gh_control.GitHubConnection, pathToClone string) error {
+func checkLocalDir(ctx context.Context, platform source_control.SourceControlPlatform, pathToClone string) error {
 	repo, err := git.PlainOpen(pathToClone)
 	if err != nil {
 		return err
@@ -138,7 +197,7 @@ func checkLocalDir(ctx context.Context, gh_connection *gh_control.GitHubConnecti
 		return fmt.Errorf("you must run this command in a clean clone of %s", SourcePolicyUri)
 	}
 
-	path := getPolicyRepoPath(pathToClone, gh_connection)
+	path := getPolicyRepoPath(pathToClone, platform)
 	// Is there already a local policy?
 	_, err = os.Stat(path)
 	if err != nil {
@@ -152,34 +211,34 @@ func checkLocalDir(ctx context.Context, gh_connection *gh_control.GitHubConnecti
 
 	// Is there a remote policy?
 	// TODO: Look for errors that _aren't_ 404.
-	rp, _, _ := getRemotePolicy(ctx, gh_connection)
+	rp, _, _ := getRemotePolicy(ctx, platform)
 	if rp != nil {
-		return fmt.Errorf("policy already exists remotely for %s", getPolicyPath(gh_connection))
+		return fmt.Errorf("policy already exists remotely for %s", getPolicyPath(platform))
 	}
 	return nil
 }
 
-func CreateLocalPolicy(ctx context.Context, gh_connection *gh_control.GitHubConnection, pathToClone string) (string, error) {
+func CreateLocalPolicy(ctx context.Context, platform source_control.SourceControlPlatform, pathToClone string) (string, error) {
 	// First make sure they're in the right state...
-	err := checkLocalDir(ctx, gh_connection, pathToClone)
+	err := checkLocalDir(ctx, platform, pathToClone)
 	if err != nil {
 		return "", err
 	}
 
-	path := getPolicyRepoPath(pathToClone, gh_connection)
+	path := getPolicyRepoPath(pathToClone, platform)
 
 	// What's their latest commit (needed for checking control status)
-	branch := gh_control.GetBranchFromRef(gh_connection.GetFullRef())
+	branch := source_control.GetBranchFromRef(platform.GetFullRef())
 	if branch == "" {
-		return "", fmt.Errorf("cannot create local policy, ref %s isn't a branch", gh_connection.GetFullRef())
+		return "", fmt.Errorf("cannot create local policy, ref %s isn't a branch", platform.GetFullRef())
 	}
-	latestCommit, err := gh_connection.GetLatestCommit(ctx, branch)
+	latestCommit, err := platform.GetLatestCommit(ctx, branch)
 	if err != nil {
 		return "", fmt.Errorf("could not get latest commit: %w", err)
 	}
 
-	pa := attest.NewProvenanceAttestor(gh_connection, attest.GetDefaultVerifier())
-	_, provPred, err := pa.GetProvenance(ctx, latestCommit, gh_connection.GetFullRef())
+	pa := attest.NewProvenanceAttestor(platform, attest.GetDefaultVerifier())
+	_, provPred, err := pa.GetProvenance(ctx, latestCommit, platform.GetFullRef())
 	if err != nil {
 		return "", fmt.Errorf("could not get provenance for latest commit: %w", err)
 	}
@@ -318,6 +377,163 @@ func computeReviewEnforced(branchPolicy *ProtectedBranch, controls slsa_types.Co
 	return true, nil
 }
 
+func computeRequiredStatusChecks(branchPolicy *ProtectedBranch, controls slsa_types.Controls) (bool, error) {
+	if len(branchPolicy.RequiredStatusChecks) == 0 {
+		return false, nil
+	}
+
+	statusChecksControl := controls.GetControl(slsa_types.RequiredStatusChecks)
+	if statusChecksControl == nil {
+		return false, fmt.Errorf("policy requires status checks, but that control is not enabled")
+	}
+
+	for _, check := range branchPolicy.RequiredStatusChecks {
+		if !slices.ContainsFunc(statusChecksControl.RequiredChecks, check.SatisfiedBy) {
+			return false, fmt.Errorf("policy requires status check %+v, but only %+v are required", check, statusChecksControl.RequiredChecks)
+		}
+	}
+
+	if branchPolicy.Since.Before(statusChecksControl.Since) {
+		return false, fmt.Errorf("policy requires status checks since %v, but that control has only been enabled since %v", branchPolicy.Since, statusChecksControl.Since)
+	}
+
+	return true, nil
+}
+
+// Computes whether a control the branch policy may require is enforced. 'description'
+// describes what the control enforces, for errors.
+func computeRequiredControl(branchPolicy *ProtectedBranch, controls slsa_types.Controls, required bool, controlName, description string) (bool, error) {
+	if !required {
+		return false, nil
+	}
+
+	control := controls.GetControl(controlName)
+	if control == nil {
+		return false, fmt.Errorf("policy requires %s, but that control is not enabled", description)
+	}
+
+	if branchPolicy.Since.Before(control.Since) {
+		return false, fmt.Errorf("policy requires %s since %v, but that control has only been enabled since %v", description, branchPolicy.Since, control.Since)
+	}
+
+	return true, nil
+}
+
+func computeSignedCommits(branchPolicy *ProtectedBranch, controls slsa_types.Controls) (bool, error) {
+	return computeRequiredControl(branchPolicy, controls, branchPolicy.RequireSignedCommits, slsa_types.SignedCommits, "signed commits")
+}
+
+func computeLinearHistory(branchPolicy *ProtectedBranch, controls slsa_types.Controls) (bool, error) {
+	return computeRequiredControl(branchPolicy, controls, branchPolicy.RequireLinearHistory, slsa_types.RequiredLinearHistory, "linear history")
+}
+
+func computeMergeQueue(branchPolicy *ProtectedBranch, controls slsa_types.Controls) (bool, error) {
+	return computeRequiredControl(branchPolicy, controls, branchPolicy.RequireMergeQueue, slsa_types.MergeQueue, "a merge queue")
+}
+
+// Checks that the commit's signature verified if the branch policy requires signed commits.
+func checkCommitSignature(branchPolicy *ProtectedBranch, signature *slsa_types.CommitSignature) error {
+	if !branchPolicy.RequireSignedCommits {
+		return nil
+	}
+	if signature == nil {
+		return fmt.Errorf("policy requires signed commits, but the commit isn't signed")
+	}
+	if !signature.Verified && signature.Type == source_control.SignatureTypeX509 {
+		// GitHub only verifies S/MIME signatures, and we only verify gitsign's that embed their Rekor entry.
+		return fmt.Errorf("policy requires signed commits, but the commit's x509 signature by %q didn't verify against trusted CAs or Sigstore: %s", signature.Signer, signature.Reason)
+	}
+	if !signature.Verified {
+		return fmt.Errorf("policy requires signed commits, but the commit's %s signature by %q didn't verify: %s", signature.Type, signature.Signer, signature.Reason)
+	}
+	return nil
+}
+
+// Checks that someone independent approved the commit's pull request if the branch policy
+// requires it.
+func checkIndependentApproval(branchPolicy *ProtectedBranch, review *slsa_types.ReviewEvidence) error {
+	if !branchPolicy.RequireIndependentApproval {
+		return nil
+	}
+	if review == nil {
+		return fmt.Errorf("policy requires independent approval, but the commit wasn't merged from a reviewed pull request")
+	}
+	if !review.IndependentlyApproved() {
+		return fmt.Errorf("policy requires independent approval, but %s wasn't approved by anyone other than its authors", review.PullRequest)
+	}
+	return nil
+}
+
+// Checks that the code owners of every file the commit's pull request changed approved
+// it if the branch policy requires it.
+func checkCodeOwnerApproval(branchPolicy *ProtectedBranch, review *slsa_types.ReviewEvidence) error {
+	if !branchPolicy.RequireCodeOwnerApproval {
+		return nil
+	}
+	if review == nil || review.CodeOwners == nil {
+		return fmt.Errorf("policy requires code owner approval, but there's no record of code owner review for the commit")
+	}
+	codeOwners := review.CodeOwners
+	if codeOwners.File == "" {
+		return fmt.Errorf("policy requires code owner approval, but there was no CODEOWNERS file for %s", review.PullRequest)
+	}
+	if len(codeOwners.UncoveredPaths) > 0 {
+		return fmt.Errorf("policy requires code owner approval, but no one owns %v changed by %s", codeOwners.UncoveredPaths, review.PullRequest)
+	}
+	if len(codeOwners.UnapprovedPaths) > 0 {
+		return fmt.Errorf("policy requires code owner approval, but the owners of %v didn't approve %s", codeOwners.UnapprovedPaths, review.PullRequest)
+	}
+	return nil
+}
+
+// Checks that the commits a merge commit merged in came from an approved pull request if
+// the branch policy requires review. Otherwise the merge could bring in unreviewed changes.
+func checkMergedParents(branchPolicy *ProtectedBranch, parents []string, review *slsa_types.ReviewEvidence) error {
+	if !branchPolicy.RequireReview || len(parents) < 2 {
+		return nil
+	}
+	if review == nil {
+		return fmt.Errorf("policy requires review, but merge commit merged %v without a pull request", parents[1:])
+	}
+	if len(review.Approvals) == 0 {
+		return fmt.Errorf("policy requires review, but %s wasn't approved", review.PullRequest)
+	}
+	for _, parent := range parents[1:] {
+		if parent != review.Head {
+			return fmt.Errorf("policy requires review, but merge commit merged %s, not the head of %s (%s)", parent, review.PullRequest, review.Head)
+		}
+	}
+	return nil
+}
+
+// Checks that each status check the branch policy requires passed, and never failed,
+// according to 'results'.
+func checkRequiredChecksPassed(branchPolicy *ProtectedBranch, results []slsa_types.CheckResult) error {
+	if !branchPolicy.RequirePassingChecks {
+		return nil
+	}
+	if len(branchPolicy.RequiredStatusChecks) == 0 {
+		return fmt.Errorf("policy requires status checks to pass, but doesn't list any in required_status_checks")
+	}
+
+	for _, check := range branchPolicy.RequiredStatusChecks {
+		var checkResults []slsa_types.CheckResult
+		for _, result := range results {
+			if check.SatisfiedBy(slsa_types.StatusCheck{Context: result.Name, IntegrationId: result.AppId}) {
+				checkResults = append(checkResults, result)
+			}
+		}
+		// A pass on the pull request's head doesn't make up for a failure on the commit.
+		if failed := slices.IndexFunc(checkResults, slsa_types.CheckResult.Failed); failed >= 0 {
+			return fmt.Errorf("policy requires status check %+v to pass, but it concluded %q", check, checkResults[failed].Conclusion)
+		}
+		if !slices.ContainsFunc(checkResults, slsa_types.CheckResult.Passed) {
+			return fmt.Errorf("policy requires status check %+v to pass, but it didn't", check)
+		}
+	}
+	return nil
+}
+
 func computeImmutableTags(tagPolicy *ProtectedTag, controls slsa_types.Controls) (bool, error) {
 	if tagPolicy == nil {
 		// There is no tag policy, so the control isn't met, but it's not an error.
@@ -357,6 +573,42 @@ func evaluateBranchControls(branchPolicy *ProtectedBranch, tagPolicy *ProtectedT
 		verifiedLevels = append(verifiedLevels, slsa_types.ReviewEnforced)
 	}
 
+	signedCommits, err := computeSignedCommits(branchPolicy, controls)
+	if err != nil {
+		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing signed commits: %w", err)
+	}
+	if signedCommits {
+		verifiedLevels = append(verifiedLevels, slsa_types.SignedCommits)
+	}
+
+	linearHistory, err := computeLinearHistory(branchPolicy, controls)
+	if err != nil {
+		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing linear history: %w", err)
+	}
+	if linearHistory {
+		verifiedLevels = append(verifiedLevels, slsa_types.RequiredLinearHistory)
+	}
+
+	mergeQueue, err := computeMergeQueue(branchPolicy, controls)
+	if err != nil {
+		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing merge queue: %w", err)
+	}
+	if mergeQueue {
+		verifiedLevels = append(verifiedLevels, slsa_types.MergeQueue)
+	}
+
+	statusChecks, err := computeRequiredStatusChecks(branchPolicy, controls)
+	if err != nil {
+		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing required status checks: %w", err)
+	}
+	if statusChecks {
+		verifiedLevels = append(verifiedLevels, slsa_types.RequiredStatusChecks)
+	}
+
+	if tagPolicy != nil && len(tagPolicy.TagPatterns) > 0 && controls.GetControl(slsa_types.ImmutableTags) == nil {
+		// Only some tags must be immutable, which their own controls will show.
+		return verifiedLevels, nil
+	}
 	immutableTags, err := computeImmutableTags(tagPolicy, controls)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing tag immutability enforced: %w", err)
@@ -375,14 +627,21 @@ func evaluateTagProv(tagPolicy *ProtectedTag, tagProvPred *attest.TagProvenanceP
 	// As long as all the controls for tag protection are currently in force then we'll
 	// include the verifiedLevels.
 
-	// TODO: handle tag policy?
+	if tagPolicy != nil && !tagPolicy.coversTag(tagProvPred.Tag) {
+		// Tags the policy doesn't protect may be moved, so nothing carries over from the commit.
+		return slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel1)}, nil
+	}
+
 	immutableTags, err := computeImmutableTags(tagPolicy, tagProvPred.Controls)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, fmt.Errorf("error computing tag immutability enforced: %w", err)
 	}
 	if immutableTags {
-		// TODO: should we include the immutable tag field specifically?
-		return tagProvPred.VsaSummaries[0].VerifiedLevels, nil
+		verifiedLevels := slices.Clone(tagProvPred.VsaSummaries[0].VerifiedLevels)
+		if !slices.Contains(verifiedLevels, slsa_types.ImmutableTags) {
+			verifiedLevels = append(verifiedLevels, slsa_types.ImmutableTags)
+		}
+		return verifiedLevels, nil
 	}
 
 	// If tag immutability isn't enabled then we just return level 1.
@@ -400,15 +659,15 @@ func NewPolicyEvaluator() *PolicyEvaluator {
 }
 
 // Evaluates the control against the policy and returns the resulting source level and policy path.
-func (pe PolicyEvaluator) EvaluateControl(ctx context.Context, gh_connection *gh_control.GitHubConnection, controlStatus *gh_control.GhControlStatus) (slsa_types.SourceVerifiedLevels, string, error) {
+func (pe PolicyEvaluator) EvaluateControl(ctx context.Context, platform source_control.SourceControlPlatform, controlStatus *source_control.ControlStatus) (slsa_types.SourceVerifiedLevels, string, error) {
 	// We want to check to ensure the repo hasn't enabled/disabled the rules since
 	// setting the 'since' field in their policy.
-	rp, policyPath, err := pe.getPolicy(ctx, gh_connection)
+	rp, policyPath, err := pe.getPolicy(ctx, platform)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, "", err
 	}
 
-	branch := gh_control.GetBranchFromRef(gh_connection.GetFullRef())
+	branch := source_control.GetBranchFromRef(platform.GetFullRef())
 	branchPolicy := rp.getBranchPolicy(branch)
 	if branchPolicy == nil {
 		branchPolicy = createDefaultBranchPolicy(branch)
@@ -420,7 +679,7 @@ func (pe PolicyEvaluator) EvaluateControl(ctx context.Context, gh_connection *gh
 		return slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel1)}, policyPath, nil
 	}
 
-	verifiedLevels, err := evaluateBranchControls(branchPolicy, rp.ProtectedTag, controlStatus.Controls)
+	verifiedLevels, err := evaluateBranchControls(branchPolicy, rp.ProtectedTag, rp.removeBypassableControls(controlStatus.Controls))
 	if err != nil {
 		return verifiedLevels, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
 	}
@@ -428,8 +687,8 @@ func (pe PolicyEvaluator) EvaluateControl(ctx context.Context, gh_connection *gh
 }
 
 // Evaluates the provenance against the policy and returns the resulting source level and policy path
-func (pe PolicyEvaluator) EvaluateSourceProv(ctx context.Context, gh_connection *gh_control.GitHubConnection, prov *spb.Statement) (slsa_types.SourceVerifiedLevels, string, error) {
-	rp, policyPath, err := pe.getPolicy(ctx, gh_connection)
+func (pe PolicyEvaluator) EvaluateSourceProv(ctx context.Context, platform source_control.SourceControlPlatform, prov *spb.Statement) (slsa_types.SourceVerifiedLevels, string, error) {
+	rp, policyPath, err := pe.getPolicy(ctx, platform)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, "", err
 	}
@@ -439,25 +698,41 @@ func (pe PolicyEvaluator) EvaluateSourceProv(ctx context.Context, gh_connection
 		return slsa_types.SourceVerifiedLevels{}, "", err
 	}
 
-	branch := gh_control.GetBranchFromRef(gh_connection.GetFullRef())
+	branch := source_control.GetBranchFromRef(platform.GetFullRef())
 	branchPolicy := rp.getBranchPolicy(branch)
 	if branchPolicy == nil {
 		branchPolicy = createDefaultBranchPolicy(branch)
 		policyPath = "DEFAULT"
 	}
 
-	verifiedLevels, err := evaluateBranchControls(branchPolicy, rp.ProtectedTag, provPred.Controls)
+	verifiedLevels, err := evaluateBranchControls(branchPolicy, rp.ProtectedTag, rp.removeBypassableControls(provPred.Controls))
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
 	}
 
+	if err := checkRequiredChecksPassed(branchPolicy, provPred.Checks); err != nil {
+		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
+	}
+	if err := checkCommitSignature(branchPolicy, provPred.Signature); err != nil {
+		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
+	}
+	if err := checkIndependentApproval(branchPolicy, provPred.Review); err != nil {
+		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
+	}
+	if err := checkCodeOwnerApproval(branchPolicy, provPred.Review); err != nil {
+		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
+	}
+	if err := checkMergedParents(branchPolicy, provPred.Parents, provPred.Review); err != nil {
+		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
+	}
+
 	// Looks good!
 	return verifiedLevels, policyPath, nil
 }
 
 // Evaluates the provenance against the policy and returns the resulting source level and policy path
-func (pe PolicyEvaluator) EvaluateTagProv(ctx context.Context, gh_connection *gh_control.GitHubConnection, prov *spb.Statement) (slsa_types.SourceVerifiedLevels, string, error) {
-	rp, policyPath, err := pe.getPolicy(ctx, gh_connection)
+func (pe PolicyEvaluator) EvaluateTagProv(ctx context.Context, platform source_control.SourceControlPlatform, prov *spb.Statement) (slsa_types.SourceVerifiedLevels, string, error) {
+	rp, policyPath, err := pe.getPolicy(ctx, platform)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, "", err
 	}
@@ -468,7 +743,10 @@ func (pe PolicyEvaluator) EvaluateTagProv(ctx context.Context, gh_connection *gh
 	}
 
 	// TODO: get the levels we want to use from the prov predicate...
-	outputVerifiedLevels, err := evaluateTagProv(rp.ProtectedTag, provPred)
+	// Evaluate a copy so the predicate still has every control the provenance recorded.
+	enforcedPred := *provPred
+	enforcedPred.Controls = rp.removeBypassableControls(provPred.Controls)
+	outputVerifiedLevels, err := evaluateTagProv(rp.ProtectedTag, &enforcedPred)
 	if err != nil {
 		return slsa_types.SourceVerifiedLevels{}, policyPath, fmt.Errorf("error evaluating policy %s: %w", policyPath, err)
 	}
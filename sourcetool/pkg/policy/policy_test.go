@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"reflect" // Ensure reflect is imported
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -21,7 +22,10 @@ import (
 
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/attest"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gh_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/gitlab_control"
 	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
 )
 
 var fixedTime = time.Unix(1678886400, 0) // March 15, 2023 00:00:00 UTC
@@ -76,7 +80,7 @@ func createStatementForTest(t *testing.T, predicateContent interface{}, predType
 
 // Helper to create a test GH Branch connection with no client.
 func newTestGhBranchConnection(owner, repo, branch string) *gh_control.GitHubConnection {
-	return gh_control.NewGhConnectionWithClient(owner, repo, gh_control.BranchToFullRef(branch), nil)
+	return gh_control.NewGhConnectionWithClient(owner, repo, source_control.BranchToFullRef(branch), nil)
 }
 
 // createTempPolicyFile creates a temporary file with the given policy data.
@@ -290,6 +294,110 @@ func TestEvaluateSourceProv_Failure(t *testing.T) {
 	}
 }
 
+func TestEvaluateSourceProv_GitLab(t *testing.T) {
+	pushTime := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	prevPred := attest.SourceProvenancePred{
+		Branch:    "refs/heads/main",
+		CreatedOn: pushTime.Add(-24 * time.Hour),
+		Controls: slsa_types.Controls{
+			{Name: slsa_types.ContinuityEnforced, Since: earlierFixedTime},
+			{Name: slsa_types.ReviewEnforced, Since: earlierFixedTime},
+			{Name: slsa_types.ImmutableTags, Since: earlierFixedTime},
+			{Name: slsa_types.ProvenanceAvailable, Since: earlierFixedTime},
+		},
+	}
+	prevStmt := createStatementForTest(t, prevPred, attest.SourceProvPredicateType)
+	prevStmt.Subject = []*spb.ResourceDescriptor{{Digest: map[string]string{"gitCommit": "prev123"}}}
+	prevNote, err := protojson.Marshal(prevStmt)
+	if err != nil {
+		t.Fatalf("failed to marshal previous provenance: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		prevNote       string
+		commitFrom     string
+		expectedLevels slsa_types.SourceVerifiedLevels
+		expectError    bool
+	}{
+		{
+			name:           "carried forward from the previous provenance",
+			prevNote:       string(prevNote),
+			commitFrom:     "prev123",
+			expectedLevels: slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel3), slsa_types.ReviewEnforced, slsa_types.ImmutableTags},
+		},
+		{
+			name:        "no previous provenance",
+			commitFrom:  "prev123",
+			expectError: true,
+		},
+		{
+			name:        "push didn't start from the previous commit",
+			prevNote:    string(prevNote),
+			commitFrom:  "other123",
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]any{
+				"events": []map[string]any{{
+					"created_at":      pushTime,
+					"author_username": "the-pusher",
+					"push_data":       map[string]any{"action": "pushed", "ref_type": "branch", "ref": "main", "commit_from": tt.commitFrom, "commit_to": "abc123"},
+				}},
+				"protected_branches": []map[string]any{{
+					"name":                         "main",
+					"push_access_levels":           []map[string]any{{"access_level": 0}},
+					"code_owner_approval_required": true,
+				}},
+				"approvals":                 map[string]any{"reset_approvals_on_push": true, "merge_requests_disable_committers_approval": true},
+				"approval_rules":            []map[string]any{{"name": "All", "approvals_required": 1, "applies_to_all_protected_branches": true}},
+				"protected_tags":            []map[string]any{{"name": "*"}},
+				"repository/commits/abc123": map[string]any{"id": "abc123", "parent_ids": []string{"prev123"}},
+			}
+			if tt.prevNote != "" {
+				responses["repository/files/prev123/raw"] = tt.prevNote
+			}
+			server := testsupport.NewApiServer(t, testsupport.ApiServerOptions{PathPrefix: "/api/v4/projects/group%2Fproject/"}, responses)
+			glc, err := gitlab_control.NewGitLabConnectionWithClient(server.URL, "group/project", "refs/heads/main", nil, server.Client())
+			if err != nil {
+				t.Fatalf("failed to create connection: %v", err)
+			}
+
+			pa := attest.NewProvenanceAttestor(glc, testsupport.NewMockVerifier())
+			prov, err := pa.CreateSourceProvenance(context.Background(), "", "abc123", "prev123", "refs/heads/main")
+			if err != nil {
+				t.Fatalf("CreateSourceProvenance() error = %v", err)
+			}
+
+			rp := createTestPolicy(ProtectedBranch{
+				Name:                  "main",
+				TargetSlsaSourceLevel: slsa_types.SlsaSourceLevel3,
+				RequireReview:         true,
+				Since:                 fixedTime,
+			})
+			policyPath := createTempPolicyFile(t, rp)
+			defer os.Remove(policyPath)
+			pe := &PolicyEvaluator{UseLocalPolicy: policyPath}
+
+			verifiedLevels, _, err := pe.EvaluateSourceProv(context.Background(), glc, prov)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("EvaluateSourceProv() = %v, want error", verifiedLevels)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvaluateSourceProv() error = %v", err)
+			}
+			if !reflect.DeepEqual(verifiedLevels, tt.expectedLevels) {
+				t.Errorf("EvaluateSourceProv() verifiedLevels = %v, want %v", verifiedLevels, tt.expectedLevels)
+			}
+		})
+	}
+}
+
 func TestEvaluateControl_Success(t *testing.T) {
 	// Controls
 	continuityEnforcedEarlier := slsa_types.Control{Name: slsa_types.ContinuityEnforced, Since: earlierFixedTime}
@@ -316,7 +424,7 @@ func TestEvaluateControl_Success(t *testing.T) {
 	tests := []struct {
 		name               string
 		policyContent      interface{} // RepoPolicy or string for malformed
-		controlStatus      *gh_control.GhControlStatus
+		controlStatus      *source_control.ControlStatus
 		ghConnBranch       string // Branch for GitHub connection
 		expectedLevels     slsa_types.SourceVerifiedLevels
 		expectedPolicyPath string
@@ -324,7 +432,7 @@ func TestEvaluateControl_Success(t *testing.T) {
 		{
 			name:          "Commit time before policy Since -> SLSA Level 1",
 			policyContent: policyL3ReviewTagsNow,
-			controlStatus: &gh_control.GhControlStatus{
+			controlStatus: &source_control.ControlStatus{
 				CommitPushTime: earlierFixedTime, // Commit time before policyL3ReviewTagsNow.Since (now)
 				Controls:       slsa_types.Controls{continuityEnforcedEarlier, provenanceAvailableEarlier, reviewEnforcedEarlier, immutableTagsEarlier},
 			},
@@ -335,7 +443,7 @@ func TestEvaluateControl_Success(t *testing.T) {
 		{
 			name:          "Commit time after policy Since, controls meet policy -> Expected levels",
 			policyContent: policyL3ReviewTagsNow,
-			controlStatus: &gh_control.GhControlStatus{
+			controlStatus: &source_control.ControlStatus{
 				CommitPushTime: laterFixedTime,
 				Controls:       slsa_types.Controls{continuityEnforcedEarlier, provenanceAvailableEarlier, reviewEnforcedEarlier, immutableTagsEarlier},
 			},
@@ -346,7 +454,7 @@ func TestEvaluateControl_Success(t *testing.T) {
 		{
 			name:          "Branch not in policy, commit after default policy since -> Default policy (SLSA L1)",
 			policyContent: policyL1NoExtrasNow, // main is in policy, but we test "develop"
-			controlStatus: &gh_control.GhControlStatus{
+			controlStatus: &source_control.ControlStatus{
 				CommitPushTime: laterFixedTime,
 				Controls:       slsa_types.Controls{continuityEnforcedEarlier, provenanceAvailableEarlier, reviewEnforcedEarlier, immutableTagsEarlier},
 			},
@@ -410,14 +518,14 @@ func TestEvaluateControl_Failure(t *testing.T) {
 	tests := []struct {
 		name                  string
 		policyContent         interface{} // RepoPolicy or string for malformed
-		controlStatus         *gh_control.GhControlStatus
+		controlStatus         *source_control.ControlStatus
 		ghConnBranch          string // Branch for GitHub connection
 		expectedErrorContains string
 	}{
 		{
 			name:          "Commit time after policy Since, controls DO NOT meet policy -> Error",
 			policyContent: policyL3Review, // Requires L3, Review, Tags
-			controlStatus: &gh_control.GhControlStatus{
+			controlStatus: &source_control.ControlStatus{
 				CommitPushTime: later,                                          // Commit time after policy.Since
 				Controls:       slsa_types.Controls{continuityEnforcedEarlier}, // Only meets L2
 			},
@@ -427,7 +535,7 @@ func TestEvaluateControl_Failure(t *testing.T) {
 		{
 			name:          "Malformed JSON -> Error",
 			policyContent: "not json",
-			controlStatus: &gh_control.GhControlStatus{
+			controlStatus: &source_control.ControlStatus{
 				CommitPushTime: later,
 				Controls:       slsa_types.Controls{},
 			},
@@ -505,7 +613,7 @@ func assertProtectedBranchEquals(t *testing.T, got *ProtectedBranch, expected Pr
 		// A more sophisticated check could see if 'expected' is a zero-value struct,
 		// implying that a nil 'got' might be acceptable. However, for this helper,
 		// we assume if 'expected' is provided, 'got' should be non-nil.
-		if expected != (ProtectedBranch{}) {
+		if !reflect.DeepEqual(expected, ProtectedBranch{}) {
 			t.Fatalf("Expected a non-nil ProtectedBranch, but got nil. Expected: %+v.", expected)
 		}
 		// If 'expected' is also a zero-value struct, then a nil 'got' is considered a match.
@@ -688,6 +796,14 @@ func TestEvaluateBranchControls(t *testing.T) {
 			expectError:           true,
 			expectedErrorContains: "error computing tag immutability enforced: policy requires immutable tags since", // ... but that control has only been enabled since ...
 		},
+		{
+			name:           "Success - Release tags, Tag control not on the branch (L2)",
+			branchPolicy:   &policyL2NoReview,
+			tagPolicy:      &ProtectedTag{Since: fixedTime, ImmutableTags: true, TagPatterns: []string{"v*"}},
+			controls:       slsa_types.Controls{continuityEnforcedEarlier},
+			expectedLevels: slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel2)},
+			expectError:    false,
+		},
 		{
 			name:         "Success - Mixed Requirements (L3, Review, No Tags)",
 			branchPolicy: &policyL3Review,
@@ -783,6 +899,14 @@ func TestComputeImmutableTags(t *testing.T) {
 			expectError:               true,
 			expectedErrorContains:     "policy requires immutable tags, but that control is not enabled",
 		},
+		{
+			name:                      "Policy requires immutable release tags, control not present: fail",
+			tagPolicy:                 &ProtectedTag{ImmutableTags: true, Since: now, TagPatterns: []string{"v*"}},
+			controls:                  slsa_types.Controls{},
+			expectedImmutableEnforced: false,
+			expectError:               true,
+			expectedErrorContains:     "policy requires immutable tags, but that control is not enabled",
+		},
 		{
 			name:                      "Policy requires immutable tags, control enabled, Policy.Since < Control.Since: fail",
 			tagPolicy:                 &policyRequiresImmutableTagsEarlier,                 // Policy.Since is 'earlier'
@@ -816,6 +940,380 @@ func TestComputeImmutableTags(t *testing.T) {
 	}
 }
 
+func TestEvaluateTagProv(t *testing.T) {
+	now := time.Now()
+	branchLevels := slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel3)}
+	releaseTagPolicy := ProtectedTag{ImmutableTags: true, Since: now, TagPatterns: []string{"v*"}}
+	immutableTags := slsa_types.Controls{{Name: slsa_types.ImmutableTags, Since: now}}
+
+	tests := []struct {
+		name           string
+		tagPolicy      *ProtectedTag
+		tag            string
+		controls       slsa_types.Controls
+		expectedLevels slsa_types.SourceVerifiedLevels
+		expectError    bool
+	}{
+		{
+			name:           "all tags protected",
+			tagPolicy:      &ProtectedTag{ImmutableTags: true, Since: now},
+			tag:            "refs/tags/latest",
+			controls:       immutableTags,
+			expectedLevels: slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel3), slsa_types.ImmutableTags},
+		},
+		{
+			name:           "release tag protected",
+			tagPolicy:      &releaseTagPolicy,
+			tag:            "refs/tags/v1.2.3",
+			controls:       immutableTags,
+			expectedLevels: slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel3), slsa_types.ImmutableTags},
+		},
+		{
+			name:           "tag not covered by the policy",
+			tagPolicy:      &releaseTagPolicy,
+			tag:            "refs/tags/nightly",
+			controls:       immutableTags,
+			expectedLevels: slsa_types.SourceVerifiedLevels{string(slsa_types.SlsaSourceLevel1)},
+		},
+		{
+			name:        "release tag not protected by the platform",
+			tagPolicy:   &releaseTagPolicy,
+			tag:         "refs/tags/v1.2.3",
+			controls:    slsa_types.Controls{},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pred := &attest.TagProvenancePred{
+				Tag:          tt.tag,
+				Controls:     tt.controls,
+				VsaSummaries: []attest.VsaSummary{{VerifiedLevels: branchLevels}},
+			}
+			got, err := evaluateTagProv(tt.tagPolicy, pred)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("evaluateTagProv() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evaluateTagProv() error = %v", err)
+			}
+			if !slices.Equal(got, tt.expectedLevels) {
+				t.Errorf("evaluateTagProv() = %v, want %v", got, tt.expectedLevels)
+			}
+		})
+	}
+}
+
+func TestComputeRequiredStatusChecks(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	build := slsa_types.StatusCheck{Context: "build"}
+	buildByApp := slsa_types.StatusCheck{Context: "build", IntegrationId: 15368}
+	lint := slsa_types.StatusCheck{Context: "lint"}
+
+	tests := []struct {
+		name                  string
+		branchPolicy          ProtectedBranch
+		controls              slsa_types.Controls
+		expectedEnforced      bool
+		expectedErrorContains string
+	}{
+		{
+			name:         "Policy doesn't require checks",
+			branchPolicy: ProtectedBranch{Since: now},
+		},
+		{
+			name:             "Checks required by a specific app satisfy any app",
+			branchPolicy:     ProtectedBranch{Since: now, RequiredStatusChecks: []slsa_types.StatusCheck{build}},
+			controls:         slsa_types.Controls{{Name: slsa_types.RequiredStatusChecks, Since: earlier, RequiredChecks: []slsa_types.StatusCheck{buildByApp, lint}}},
+			expectedEnforced: true,
+		},
+		{
+			name:                  "Check from any app doesn't satisfy a specific app",
+			branchPolicy:          ProtectedBranch{Since: now, RequiredStatusChecks: []slsa_types.StatusCheck{buildByApp}},
+			controls:              slsa_types.Controls{{Name: slsa_types.RequiredStatusChecks, Since: earlier, RequiredChecks: []slsa_types.StatusCheck{build}}},
+			expectedErrorContains: "policy requires status check",
+		},
+		{
+			name:                  "Control not present",
+			branchPolicy:          ProtectedBranch{Since: now, RequiredStatusChecks: []slsa_types.StatusCheck{build}},
+			expectedErrorContains: "policy requires status checks, but that control is not enabled",
+		},
+		{
+			name:                  "Control enabled after the policy",
+			branchPolicy:          ProtectedBranch{Since: earlier, RequiredStatusChecks: []slsa_types.StatusCheck{build}},
+			controls:              slsa_types.Controls{{Name: slsa_types.RequiredStatusChecks, Since: now, RequiredChecks: []slsa_types.StatusCheck{build}}},
+			expectedErrorContains: "policy requires status checks since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEnforced, err := computeRequiredStatusChecks(&tt.branchPolicy, tt.controls)
+			if tt.expectedErrorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErrorContains) {
+					t.Errorf("computeRequiredStatusChecks() error = %v, want error containing %q", err, tt.expectedErrorContains)
+				}
+			} else if err != nil {
+				t.Errorf("computeRequiredStatusChecks() error = %v, want nil", err)
+			}
+			if gotEnforced != tt.expectedEnforced {
+				t.Errorf("computeRequiredStatusChecks() gotEnforced = %v, want %v", gotEnforced, tt.expectedEnforced)
+			}
+		})
+	}
+}
+
+func TestCheckRequiredChecksPassed(t *testing.T) {
+	build := slsa_types.StatusCheck{Context: "build", IntegrationId: 15368}
+	lint := slsa_types.StatusCheck{Context: "lint"}
+	requirePassing := ProtectedBranch{RequiredStatusChecks: []slsa_types.StatusCheck{build, lint}, RequirePassingChecks: true}
+
+	tests := []struct {
+		name         string
+		branchPolicy ProtectedBranch
+		results      []slsa_types.CheckResult
+		expectError  bool
+	}{
+		{
+			name:         "Passing not required",
+			branchPolicy: ProtectedBranch{RequiredStatusChecks: []slsa_types.StatusCheck{build}},
+		},
+		{
+			name:         "All passed",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 15368},
+				{Name: "lint", Conclusion: "skipped"},
+			},
+		},
+		{
+			name:         "One failed",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 15368},
+				{Name: "lint", Conclusion: "failure"},
+			},
+			expectError: true,
+		},
+		{
+			name:         "Passed, but from the wrong app",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 1},
+				{Name: "lint", Conclusion: "success"},
+			},
+			expectError: true,
+		},
+		{
+			name:         "Still running",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "in_progress", AppId: 15368},
+				{Name: "lint", Conclusion: "success"},
+			},
+			expectError: true,
+		},
+		{
+			name:         "No results",
+			branchPolicy: requirePassing,
+			expectError:  true,
+		},
+		{
+			name:         "Passed on the pull request, failed on the commit",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 15368},
+				{Name: "lint", Conclusion: "failure"},
+				{Name: "lint", Conclusion: "success", Commit: "head123"},
+			},
+			expectError: true,
+		},
+		{
+			name:         "Passed on the pull request, still running on the commit",
+			branchPolicy: requirePassing,
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 15368},
+				{Name: "lint", Conclusion: "in_progress"},
+				{Name: "lint", Conclusion: "success", Commit: "head123"},
+			},
+		},
+		{
+			name:         "No checks listed",
+			branchPolicy: ProtectedBranch{RequirePassingChecks: true},
+			results: []slsa_types.CheckResult{
+				{Name: "build", Conclusion: "success", AppId: 15368},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRequiredChecksPassed(&tt.branchPolicy, tt.results)
+			if (err != nil) != tt.expectError {
+				t.Errorf("checkRequiredChecksPassed() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestCheckCommitSignature(t *testing.T) {
+	requireSigned := ProtectedBranch{RequireSignedCommits: true}
+
+	tests := []struct {
+		name         string
+		branchPolicy ProtectedBranch
+		signature    *slsa_types.CommitSignature
+		expectError  bool
+	}{
+		{
+			name:         "Signatures not required",
+			branchPolicy: ProtectedBranch{},
+		},
+		{
+			name:         "Verified",
+			branchPolicy: requireSigned,
+			signature:    &slsa_types.CommitSignature{Type: "ssh", Verified: true, Reason: "valid", Signer: "SHA256:abc"},
+		},
+		{
+			name:         "Not verified",
+			branchPolicy: requireSigned,
+			signature:    &slsa_types.CommitSignature{Type: "gpg", Verified: false, Reason: "unknown_key", Signer: "ABCDEF"},
+			expectError:  true,
+		},
+		{
+			name:         "Signed with gitsign",
+			branchPolicy: requireSigned,
+			signature:    &slsa_types.CommitSignature{Type: "x509", Verified: true, Reason: "valid", Signer: "someone@example.com"},
+		},
+		{
+			name:         "gitsign not verified",
+			branchPolicy: requireSigned,
+			signature:    &slsa_types.CommitSignature{Type: "x509", Verified: false, Reason: "unknown_signature_type", Signer: "someone@example.com"},
+			expectError:  true,
+		},
+		{
+			name:         "Unsigned",
+			branchPolicy: requireSigned,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCommitSignature(&tt.branchPolicy, tt.signature)
+			if (err != nil) != tt.expectError {
+				t.Errorf("checkCommitSignature() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestComputeSignedCommits(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name             string
+		branchPolicy     ProtectedBranch
+		controls         slsa_types.Controls
+		expectedEnforced bool
+		expectError      bool
+	}{
+		{
+			name:         "Not required",
+			branchPolicy: ProtectedBranch{Since: now},
+		},
+		{
+			name:             "Enabled before the policy",
+			branchPolicy:     ProtectedBranch{Since: now, RequireSignedCommits: true},
+			controls:         slsa_types.Controls{{Name: slsa_types.SignedCommits, Since: earlier}},
+			expectedEnforced: true,
+		},
+		{
+			name:         "Enabled after the policy",
+			branchPolicy: ProtectedBranch{Since: earlier, RequireSignedCommits: true},
+			controls:     slsa_types.Controls{{Name: slsa_types.SignedCommits, Since: now}},
+			expectError:  true,
+		},
+		{
+			name:         "Not enabled",
+			branchPolicy: ProtectedBranch{Since: now, RequireSignedCommits: true},
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEnforced, err := computeSignedCommits(&tt.branchPolicy, tt.controls)
+			if (err != nil) != tt.expectError {
+				t.Errorf("computeSignedCommits() error = %v, expectError %v", err, tt.expectError)
+			}
+			if gotEnforced != tt.expectedEnforced {
+				t.Errorf("computeSignedCommits() gotEnforced = %v, want %v", gotEnforced, tt.expectedEnforced)
+			}
+		})
+	}
+}
+
+func TestComputeHistoryShape(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	controls := slsa_types.Controls{
+		{Name: slsa_types.RequiredLinearHistory, Since: earlier},
+		{Name: slsa_types.MergeQueue, Since: now},
+	}
+
+	tests := []struct {
+		name             string
+		compute          func(*ProtectedBranch, slsa_types.Controls) (bool, error)
+		branchPolicy     ProtectedBranch
+		expectedEnforced bool
+		expectError      bool
+	}{
+		{
+			name:             "Linear history enforced",
+			compute:          computeLinearHistory,
+			branchPolicy:     ProtectedBranch{Since: now, RequireLinearHistory: true},
+			expectedEnforced: true,
+		},
+		{
+			name:         "Linear history not required",
+			compute:      computeLinearHistory,
+			branchPolicy: ProtectedBranch{Since: now},
+		},
+		{
+			name:             "Merge queue enforced",
+			compute:          computeMergeQueue,
+			branchPolicy:     ProtectedBranch{Since: now, RequireMergeQueue: true},
+			expectedEnforced: true,
+		},
+		{
+			name:         "Merge queue enabled after the policy",
+			compute:      computeMergeQueue,
+			branchPolicy: ProtectedBranch{Since: earlier, RequireMergeQueue: true},
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEnforced, err := tt.compute(&tt.branchPolicy, controls)
+			if (err != nil) != tt.expectError {
+				t.Errorf("compute() error = %v, expectError %v", err, tt.expectError)
+			}
+			if gotEnforced != tt.expectedEnforced {
+				t.Errorf("compute() gotEnforced = %v, want %v", gotEnforced, tt.expectedEnforced)
+			}
+		})
+	}
+}
+
 func TestComputeReviewEnforced(t *testing.T) {
 	now := time.Now()
 	earlier := now.Add(-time.Hour)
@@ -1140,7 +1638,7 @@ func assertPolicyResultEquals(t *testing.T, ctx context.Context, ghConn *gh_cont
 
 	// TODO: check the rest of the contents of expectedPolicy?
 
-	gotPb := rp.getBranchPolicy(gh_control.GetBranchFromRef(ghConn.GetFullRef()))
+	gotPb := rp.getBranchPolicy(source_control.GetBranchFromRef(ghConn.GetFullRef()))
 
 	if expectedBranchPolicy == nil {
 		if gotPb != nil {
@@ -1474,3 +1972,264 @@ func TestGetPolicy_Remote_MalformedJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveBypassableControls(t *testing.T) {
+	admin := slsa_types.BypassActor{ActorType: "OrganizationAdmin", ActorId: 1, BypassMode: "always"}
+	expungers := slsa_types.BypassActor{ActorType: "RepositoryRole", ActorId: 7, BypassMode: "always"}
+	teamViaPr := slsa_types.BypassActor{ActorType: "Team", ActorId: 5, BypassMode: "pull_request"}
+	controls := slsa_types.Controls{
+		{Name: slsa_types.ContinuityEnforced, Since: fixedTime, BypassActors: []slsa_types.BypassActor{expungers}},
+		{Name: slsa_types.ReviewEnforced, Since: fixedTime, BypassActors: []slsa_types.BypassActor{expungers, admin}},
+		{Name: slsa_types.ImmutableTags, Since: fixedTime, BypassActors: []slsa_types.BypassActor{teamViaPr}},
+		{Name: slsa_types.ProvenanceAvailable, Since: fixedTime},
+	}
+
+	tests := []struct {
+		name          string
+		allowed       []slsa_types.BypassActor
+		expectedNames []string
+	}{
+		{
+			name:          "no allowlist",
+			expectedNames: []string{slsa_types.ContinuityEnforced, slsa_types.ReviewEnforced, slsa_types.ImmutableTags, slsa_types.ProvenanceAvailable},
+		},
+		{
+			name:          "nobody allowed",
+			allowed:       []slsa_types.BypassActor{},
+			expectedNames: []string{slsa_types.ProvenanceAvailable},
+		},
+		{
+			name:          "only the expunging role",
+			allowed:       []slsa_types.BypassActor{expungers},
+			expectedNames: []string{slsa_types.ContinuityEnforced, slsa_types.ProvenanceAvailable},
+		},
+		{
+			name:          "any mode matches",
+			allowed:       []slsa_types.BypassActor{expungers, {ActorType: "Team", ActorId: 5}},
+			expectedNames: []string{slsa_types.ContinuityEnforced, slsa_types.ImmutableTags, slsa_types.ProvenanceAvailable},
+		},
+		{
+			name:          "mode must match",
+			allowed:       []slsa_types.BypassActor{{ActorType: "Team", ActorId: 5, BypassMode: "always"}},
+			expectedNames: []string{slsa_types.ProvenanceAvailable},
+		},
+		{
+			name:          "any actor of a type",
+			allowed:       []slsa_types.BypassActor{expungers, {ActorType: "OrganizationAdmin"}},
+			expectedNames: []string{slsa_types.ContinuityEnforced, slsa_types.ReviewEnforced, slsa_types.ProvenanceAvailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp := RepoPolicy{AllowedBypassActors: tt.allowed}
+			var names []string
+			for _, control := range rp.removeBypassableControls(controls) {
+				names = append(names, control.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expectedNames) {
+				t.Errorf("removeBypassableControls() = %v, want %v", names, tt.expectedNames)
+			}
+		})
+	}
+}
+
+func TestCheckIndependentApproval(t *testing.T) {
+	requireApproval := ProtectedBranch{RequireIndependentApproval: true}
+	review := func(approvers ...string) *slsa_types.ReviewEvidence {
+		evidence := &slsa_types.ReviewEvidence{
+			PullRequest:   "https://github.com/owner/repo/pull/1",
+			Head:          "head123",
+			Author:        "author",
+			CommitAuthors: []string{"author", "author@example.com", "co-author", "co@example.com", "unlinked@example.com"},
+		}
+		for _, approver := range approvers {
+			evidence.Approvals = append(evidence.Approvals, slsa_types.Approval{Reviewer: approver, Commit: "head123"})
+		}
+		return evidence
+	}
+	approvedOlderCommit := review("reviewer")
+	approvedOlderCommit.Approvals[0].Commit = "older123"
+	approvedByUnlinkedAuthor := review("unlinked")
+	approvedByUnlinkedAuthor.Approvals[0].ReviewerEmails = []string{"Unlinked@example.com"}
+
+	tests := []struct {
+		name         string
+		branchPolicy ProtectedBranch
+		review       *slsa_types.ReviewEvidence
+		expectError  bool
+	}{
+		{
+			name:         "Approval not required",
+			branchPolicy: ProtectedBranch{},
+		},
+		{
+			name:         "Approved by a reviewer",
+			branchPolicy: requireApproval,
+			review:       review("co-author", "reviewer"),
+		},
+		{
+			name:         "Approved only by a commit author",
+			branchPolicy: requireApproval,
+			review:       review("co-author"),
+			expectError:  true,
+		},
+		{
+			name:         "Approved only by a commit author with an unlinked email",
+			branchPolicy: requireApproval,
+			review:       approvedByUnlinkedAuthor,
+			expectError:  true,
+		},
+		{
+			name:         "Approved an older commit",
+			branchPolicy: requireApproval,
+			review:       approvedOlderCommit,
+			expectError:  true,
+		},
+		{
+			name:         "Not approved",
+			branchPolicy: requireApproval,
+			review:       review(),
+			expectError:  true,
+		},
+		{
+			name:         "Not from a pull request",
+			branchPolicy: requireApproval,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkIndependentApproval(&tt.branchPolicy, tt.review)
+			if (err != nil) != tt.expectError {
+				t.Errorf("checkIndependentApproval() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestCheckCodeOwnerApproval(t *testing.T) {
+	requireApproval := ProtectedBranch{RequireCodeOwnerApproval: true}
+	review := func(codeOwners *slsa_types.CodeOwnerReview) *slsa_types.ReviewEvidence {
+		return &slsa_types.ReviewEvidence{PullRequest: "https://github.com/owner/repo/pull/1", Author: "author", CodeOwners: codeOwners}
+	}
+
+	tests := []struct {
+		name         string
+		branchPolicy ProtectedBranch
+		review       *slsa_types.ReviewEvidence
+		expectError  bool
+	}{
+		{
+			name:         "Approval not required",
+			branchPolicy: ProtectedBranch{},
+		},
+		{
+			name:         "Owners approved",
+			branchPolicy: requireApproval,
+			review:       review(&slsa_types.CodeOwnerReview{File: ".github/CODEOWNERS"}),
+		},
+		{
+			name:         "No CODEOWNERS",
+			branchPolicy: requireApproval,
+			review:       review(&slsa_types.CodeOwnerReview{UncoveredPaths: []string{"main.go"}}),
+			expectError:  true,
+		},
+		{
+			name:         "Uncovered paths",
+			branchPolicy: requireApproval,
+			review:       review(&slsa_types.CodeOwnerReview{File: "CODEOWNERS", UncoveredPaths: []string{"main.go"}}),
+			expectError:  true,
+		},
+		{
+			name:         "Unapproved paths",
+			branchPolicy: requireApproval,
+			review:       review(&slsa_types.CodeOwnerReview{File: "CODEOWNERS", UnapprovedPaths: []string{"main.go"}}),
+			expectError:  true,
+		},
+		{
+			name:         "Code owners not reviewed",
+			branchPolicy: requireApproval,
+			review:       review(nil),
+			expectError:  true,
+		},
+		{
+			name:         "Not from a pull request",
+			branchPolicy: requireApproval,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCodeOwnerApproval(&tt.branchPolicy, tt.review)
+			if (err != nil) != tt.expectError {
+				t.Errorf("checkCodeOwnerApproval() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestCheckMergedParents(t *testing.T) {
+	requireReview := ProtectedBranch{RequireReview: true}
+	approved := &slsa_types.ReviewEvidence{
+		PullRequest: "https://github.com/owner/repo/pull/1",
+		Head:        "head123",
+		Approvals:   []slsa_types.Approval{{Reviewer: "reviewer", Commit: "head123"}},
+	}
+
+	tests := []struct {
+		name         string
+		branchPolicy ProtectedBranch
+		parents      []string
+		review       *slsa_types.ReviewEvidence
+		expectError  bool
+	}{
+		{
+			name:         "Review not required",
+			branchPolicy: ProtectedBranch{},
+			parents:      []string{"main123", "head123"},
+		},
+		{
+			name:         "Not a merge commit",
+			branchPolicy: requireReview,
+			parents:      []string{"main123"},
+		},
+		{
+			name:         "Merged an approved pull request",
+			branchPolicy: requireReview,
+			parents:      []string{"main123", "head123"},
+			review:       approved,
+		},
+		{
+			name:         "Merged something else",
+			branchPolicy: requireReview,
+			parents:      []string{"main123", "other456"},
+			review:       approved,
+			expectError:  true,
+		},
+		{
+			name:         "Merged an unapproved pull request",
+			branchPolicy: requireReview,
+			parents:      []string{"main123", "head123"},
+			review:       &slsa_types.ReviewEvidence{PullRequest: "https://github.com/owner/repo/pull/1", Head: "head123"},
+			expectError:  true,
+		},
+		{
+			name:         "Merged without a pull request",
+			branchPolicy: requireReview,
+			parents:      []string{"main123", "head123"},
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMergedParents(&tt.branchPolicy, tt.parents, tt.review)
+			if (err != nil) != tt.expectError {
+				t.Errorf("checkMergedParents() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
@@ -1,17 +1,25 @@
 package slsa_types
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
 type SlsaSourceLevel string
 
 const (
-	SlsaSourceLevel1    SlsaSourceLevel = "SLSA_SOURCE_LEVEL_1"
-	SlsaSourceLevel2    SlsaSourceLevel = "SLSA_SOURCE_LEVEL_2"
-	SlsaSourceLevel3    SlsaSourceLevel = "SLSA_SOURCE_LEVEL_3"
-	ContinuityEnforced                  = "CONTINUITY_ENFORCED"
-	ProvenanceAvailable                 = "PROVENANCE_AVAILABLE"
-	ReviewEnforced                      = "REVIEW_ENFORCED"
-	ImmutableTags                       = "IMMUTABLE_TAGS"
+	SlsaSourceLevel1      SlsaSourceLevel = "SLSA_SOURCE_LEVEL_1"
+	SlsaSourceLevel2      SlsaSourceLevel = "SLSA_SOURCE_LEVEL_2"
+	SlsaSourceLevel3      SlsaSourceLevel = "SLSA_SOURCE_LEVEL_3"
+	ContinuityEnforced                    = "CONTINUITY_ENFORCED"
+	ProvenanceAvailable                   = "PROVENANCE_AVAILABLE"
+	ReviewEnforced                        = "REVIEW_ENFORCED"
+	ImmutableTags                         = "IMMUTABLE_TAGS"
+	RequiredStatusChecks                  = "REQUIRED_STATUS_CHECKS"
+	SignedCommits                         = "SIGNED_COMMITS"
+	RequiredLinearHistory                 = "REQUIRED_LINEAR_HISTORY"
+	MergeQueue                            = "MERGE_QUEUE"
 )
 
 func IsLevelHigherOrEqualTo(level1, level2 SlsaSourceLevel) bool {
@@ -25,10 +33,82 @@ type Control struct {
 	Name string `json:"name"`
 	// The time from which this control has been continuously enforced/observed.
 	Since time.Time `json:"since"`
+	// Who could skip the rules that enforce this control, if anyone.
+	BypassActors []BypassActor `json:"bypass_actors,omitempty"`
+	// The status checks that must pass before a commit is pushed, for REQUIRED_STATUS_CHECKS.
+	RequiredChecks []StatusCheck `json:"required_checks,omitempty"`
+	// If the checks must have run against the latest target branch, for REQUIRED_STATUS_CHECKS.
+	StrictChecks bool `json:"strict_checks,omitempty"`
+}
+
+// Reports if 'other' enforced at least what 'control' does, in which case 'control'
+// has been enforced for as long as 'other' was.
+func (control Control) CoveredBy(other Control) bool {
+	if control.StrictChecks && !other.StrictChecks {
+		return false
+	}
+	// Anyone who could bypass 'other' must still be able to bypass 'control', or they
+	// could have skipped what 'control' enforces.
+	for _, actor := range other.BypassActors {
+		if !slices.Contains(control.BypassActors, actor) {
+			return false
+		}
+	}
+	for _, check := range control.RequiredChecks {
+		if !slices.ContainsFunc(other.RequiredChecks, check.SatisfiedBy) {
+			return false
+		}
+	}
+	return true
+}
+
+// A status check that must pass.
+type StatusCheck struct {
+	// The name of the check (aka its context).
+	Context string `json:"context"`
+	// The app that must provide the check, 0 if any may.
+	IntegrationId int64 `json:"integration_id,omitempty"`
+}
+
+// Reports if requiring 'check' also requires 'required'. If 'required' doesn't name an
+// app the check can come from any app.
+func (required StatusCheck) SatisfiedBy(check StatusCheck) bool {
+	return required.Context == check.Context &&
+		(required.IntegrationId == 0 || required.IntegrationId == check.IntegrationId)
+}
+
+// An actor that's allowed to bypass the rules enforcing a control.
+type BypassActor struct {
+	// The kind of actor, e.g. a Team, an Integration or a RepositoryRole.
+	ActorType string `json:"actor_type"`
+	// Which actor of that type, may be 0 for types with only one actor (e.g. OrganizationAdmin).
+	ActorId int64 `json:"actor_id,omitempty"`
+	// When they can bypass the rules, e.g. 'always' or only via a 'pull_request'.
+	BypassMode string `json:"bypass_mode,omitempty"`
+}
+
+// Reports if 'allowed' permits 'actor' to bypass the rules. An empty allowed ActorId or
+// BypassMode matches any.
+func (allowed BypassActor) Permits(actor BypassActor) bool {
+	return allowed.ActorType == actor.ActorType &&
+		(allowed.ActorId == 0 || allowed.ActorId == actor.ActorId) &&
+		(allowed.BypassMode == "" || allowed.BypassMode == actor.BypassMode)
 }
 
 type Controls []Control
 
+// A time someone skipped the rules enforcing a control.
+type ControlBypass struct {
+	// The name of the control whose rules were bypassed.
+	Control string `json:"control"`
+	// Who bypassed them.
+	Actor string `json:"actor"`
+	// The commit they pushed.
+	Commit string `json:"commit"`
+	// When they pushed it.
+	Time time.Time `json:"time"`
+}
+
 // Adds the control to the list. Ignores nil controls.
 // Does not check for duplicate controls.
 func (controls *Controls) AddControl(control *Control) {
@@ -48,6 +128,124 @@ func (controls Controls) GetControl(name string) *Control {
 	return nil
 }
 
+// The result of a check (e.g. CI) that ran against a commit.
+type CheckResult struct {
+	// The name of the check run, or the context of the commit status.
+	Name string `json:"name"`
+	// How it concluded, e.g. 'success' or 'failure', or its status if it hasn't yet.
+	Conclusion string `json:"conclusion"`
+	// The app that reported it, if known.
+	App   string `json:"app,omitempty"`
+	AppId int64  `json:"app_id,omitempty"`
+	// The commit it ran against if that's not the commit itself (e.g. the head of a merged PR).
+	Commit string `json:"commit,omitempty"`
+}
+
+// Reports if the check passed. Like GitHub, neutral and skipped checks count as passing.
+func (result CheckResult) Passed() bool {
+	return result.Conclusion == "success" || result.Conclusion == "neutral" || result.Conclusion == "skipped"
+}
+
+// Reports if the check finished without passing. Checks that haven't finished yet haven't failed.
+func (result CheckResult) Failed() bool {
+	switch result.Conclusion {
+	case "queued", "in_progress", "pending", "requested", "waiting":
+		return false
+	}
+	return !result.Passed()
+}
+
+// The signature on a commit.
+type CommitSignature struct {
+	// The kind of signature, 'gpg', 'ssh' or 'x509' (e.g. gitsign).
+	Type string `json:"type"`
+	// Whether the platform verified the signature was made by a key belonging to the committer,
+	// or for gitsign, that the certificate was issued by Sigstore and the signature logged in Rekor.
+	Verified bool `json:"verified"`
+	// Why it was or wasn't verified, e.g. 'valid' or 'unknown_key'.
+	Reason string `json:"reason,omitempty"`
+	// The key or identity that made the signature, e.g. the GPG or SSH key fingerprint or
+	// the email in the certificate. Only trustworthy if it was verified.
+	Signer string `json:"signer,omitempty"`
+}
+
+// How the pull request that introduced a commit was reviewed.
+type ReviewEvidence struct {
+	// The pull request that was merged.
+	PullRequest string `json:"pull_request"`
+	// The commit at the head of the pull request when it was merged.
+	Head string `json:"head"`
+	// Who opened the pull request.
+	Author string `json:"author"`
+	// Who authored the commits in the pull request, by both their login and the email in
+	// the commit.
+	CommitAuthors []string `json:"commit_authors,omitempty"`
+	// The approvals that still stood when it was merged.
+	Approvals []Approval `json:"approvals,omitempty"`
+	// Whether the code owners of the changed files approved, if the platform can tell.
+	CodeOwners *CodeOwnerReview `json:"code_owners,omitempty"`
+}
+
+// Reports if someone other than the author of the pull request and of its commits
+// approved its head.
+func (review ReviewEvidence) IndependentlyApproved() bool {
+	for _, approval := range review.Approvals {
+		if approval.Commit != review.Head || approval.By(review.Author) {
+			continue
+		}
+		if !slices.ContainsFunc(review.CommitAuthors, approval.By) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the code owners of the files a pull request changed approved it.
+type CodeOwnerReview struct {
+	// The CODEOWNERS file that applied, "" if there wasn't one.
+	File string `json:"file"`
+	// The changed paths that no one owns.
+	UncoveredPaths []string `json:"uncovered_paths,omitempty"`
+	// The changed paths none of whose owners approved.
+	UnapprovedPaths []string `json:"unapproved_paths,omitempty"`
+}
+
+// An approving review of a pull request.
+type Approval struct {
+	Reviewer string `json:"reviewer"`
+	// The emails the platform knows belong to the reviewer, which identify their commits
+	// that aren't linked to their account.
+	ReviewerEmails []string `json:"reviewer_emails,omitempty"`
+	// The commit that was approved, which may be older than the head of the pull request.
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+}
+
+// Reports if 'identity' (a login or an email) is the reviewer.
+func (approval Approval) By(identity string) bool {
+	return strings.EqualFold(identity, approval.Reviewer) ||
+		slices.ContainsFunc(approval.ReviewerEmails, func(email string) bool { return strings.EqualFold(identity, email) })
+}
+
+// The kinds of break in a branch's history.
+const (
+	DiscontinuityForcePush      = "force_push"
+	DiscontinuityBranchDeletion = "branch_deletion"
+	DiscontinuityBranchCreation = "branch_creation"
+	// The previous provenance is for a commit other than the one the branch was at before the push.
+	DiscontinuityUnexpectedPrevCommit = "unexpected_prev_commit"
+)
+
+// A break in a branch's history, after which it needn't descend from what it was before.
+type Discontinuity struct {
+	Type  string    `json:"type"`
+	Actor string    `json:"actor,omitempty"`
+	Time  time.Time `json:"time"`
+	// The commits the branch was at before and after, if known.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
 // These can be any string, not just SlsaLevels
 type SourceVerifiedLevels []string
 
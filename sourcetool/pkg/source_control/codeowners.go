@@ -0,0 +1,75 @@
+package source_control
+
+import (
+	"path"
+	"strings"
+)
+
+// A line of a CODEOWNERS file.
+type CodeownersRule struct {
+	Pattern string
+	// The users ('@user'), teams ('@org/team') or emails that own the paths the pattern
+	// matches. A rule without owners makes the paths it matches unowned.
+	Owners []string
+}
+
+// The rules of a CODEOWNERS file, in the order they appear.
+type Codeowners []CodeownersRule
+
+// Parses a CODEOWNERS file. Lines that are blank or comments are skipped.
+func ParseCodeowners(content string) Codeowners {
+	var codeowners Codeowners
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := CodeownersRule{Pattern: fields[0]}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+		codeowners = append(codeowners, rule)
+	}
+	return codeowners
+}
+
+// Returns the owners of the file at 'filePath', nil if no one owns it. Like GitHub, the
+// last rule matching the path takes precedence.
+func (co Codeowners) OwnersOf(filePath string) []string {
+	for i := len(co) - 1; i >= 0; i-- {
+		if matchesCodeownersPattern(co[i].Pattern, filePath) {
+			return co[i].Owners
+		}
+	}
+	return nil
+}
+
+// Reports if 'pattern' matches 'filePath' using gitignore rules the way GitHub does: a
+// pattern without a '/' (other than a trailing one) matches at any depth, a pattern matching
+// a directory matches everything in it, and a trailing '/' only matches directories. Unlike
+// gitignore, 'dir/*' doesn't match files in subdirectories of 'dir'.
+func matchesCodeownersPattern(pattern, filePath string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		pattern = "**/" + pattern
+	}
+
+	if !dirOnly && MatchesGlob(FnmatchGlob, pattern, filePath) {
+		return true
+	}
+	if path.Base(pattern) == "*" {
+		return false
+	}
+	for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+		if MatchesGlob(FnmatchGlob, pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package source_control
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodeownersOwnersOf(t *testing.T) {
+	codeowners := ParseCodeowners(`
+# The default owners.
+*       @owner/everyone
+
+*.js    @js-owner # Trailing comments are ignored.
+/build/logs/ @logs-owner
+docs/*  docs@example.com
+apps/   @apps-owner
+**/vendor @vendor-owner
+/apps/github
+`)
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "README.md", expected: []string{"@owner/everyone"}},
+		{path: "src/app.js", expected: []string{"@js-owner"}},
+		{path: "build/logs/today.log", expected: []string{"@logs-owner"}},
+		{path: "build/logs/2025/today.log", expected: []string{"@logs-owner"}},
+		{path: "other/build/logs/today.log", expected: []string{"@owner/everyone"}},
+		{path: "docs/start.md", expected: []string{"docs@example.com"}},
+		{path: "docs/build/start.md", expected: []string{"@owner/everyone"}},
+		{path: "apps/main.go", expected: []string{"@apps-owner"}},
+		{path: "src/apps/main.go", expected: []string{"@apps-owner"}},
+		{path: "lib/vendor/dep/dep.go", expected: []string{"@vendor-owner"}},
+		{path: "apps/github/main.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := codeowners.OwnersOf(tt.path); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("OwnersOf(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package gh_control
+package source_control
 
 import (
 	"fmt"
@@ -24,3 +24,9 @@ func GetBranchFromRef(ref string) string {
 func GetTagFromRef(ref string) string {
 	return strings.TrimPrefix(ref, "refs/tags/")
 }
+
+// Reports if 'sha' is the all zero commit git uses for a ref that doesn't exist, e.g. the
+// commit a branch was at before it was created.
+func IsZeroCommit(sha string) bool {
+	return sha != "" && strings.Trim(sha, "0") == ""
+}
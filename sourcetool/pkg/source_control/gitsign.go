@@ -0,0 +1,124 @@
+package source_control
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/digitorus/pkcs7"
+	rekorv1 "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+	"google.golang.org/protobuf/proto"
+)
+
+// The unauthenticated attribute gitsign embeds the signature's Rekor entry in, as a
+// TransparencyLogEntry protobuf in an OCTET STRING.
+var gitsignRekorEntryOid = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 3, 1}
+
+// Verifies a gitsign signature over 'payload' (the commit without its signature) and returns
+// the email (or URI) in its certificate. The certificate must chain to a Fulcio CA in
+// 'trustedMaterial', and the Rekor entry gitsign embeds in the signature must be signed by a
+// log in it and show the signature was logged while the certificate was valid.
+// Signatures without an embedded Rekor entry (from older versions of gitsign) fail, since
+// we'd have to search Rekor for them.
+func VerifyGitsignSignature(signature, payload string, trustedMaterial root.TrustedMaterial) (string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(signature)))
+	if block == nil {
+		return "", errors.New("could not decode x509 signature")
+	}
+	p7, err := pkcs7.Parse(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse x509 signature: %w", err)
+	}
+	cert := p7.GetOnlySigner()
+	if cert == nil || len(p7.Signers[0].AuthenticatedAttributes) == 0 {
+		return "", errors.New("x509 signature isn't from gitsign, it must have a single signer and signed attributes")
+	}
+	// The signature is detached. This checks the signed attributes hold the payload's digest
+	// and the signature over them, but not who the certificate belongs to.
+	p7.Content = []byte(payload)
+	if err := p7.Verify(); err != nil {
+		return "", fmt.Errorf("x509 signature doesn't match the commit: %w", err)
+	}
+
+	// gitsign logs the signature over the signed attributes.
+	signer := p7.Signers[0]
+	signedAttributes, err := asn1.MarshalWithParams(signer.AuthenticatedAttributes, "set")
+	if err != nil {
+		return "", fmt.Errorf("could not marshal signed attributes: %w", err)
+	}
+	entry, err := gitsignRekorEntry(p7)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := verify.NewSignedEntityVerifier(trustedMaterial, verify.WithTransparencyLog(1), verify.WithIntegratedTimestamps(1))
+	if err != nil {
+		return "", fmt.Errorf("could not create Sigstore verifier: %w", err)
+	}
+	entity := &gitsignEntity{cert: cert, signature: signer.EncryptedDigest, entry: entry}
+	// Who may sign commits is up to the platform, we only report who did.
+	result, err := verifier.Verify(entity, verify.NewPolicy(verify.WithArtifact(strings.NewReader(string(signedAttributes))), verify.WithoutIdentitiesUnsafe()))
+	if err != nil {
+		return "", fmt.Errorf("could not verify gitsign signature: %w", err)
+	}
+	return result.Signature.Certificate.SubjectAlternativeName, nil
+}
+
+func gitsignRekorEntry(p7 *pkcs7.PKCS7) (*tlog.Entry, error) {
+	for _, attribute := range p7.Signers[0].UnauthenticatedAttributes {
+		if !attribute.Type.Equal(gitsignRekorEntryOid) {
+			continue
+		}
+		// The value is the SET of attribute values, holding just the OCTET STRING.
+		var raw []byte
+		if _, err := asn1.Unmarshal(attribute.Value.Bytes, &raw); err != nil {
+			return nil, fmt.Errorf("could not decode gitsign's Rekor entry: %w", err)
+		}
+		var protoEntry rekorv1.TransparencyLogEntry
+		if err := proto.Unmarshal(raw, &protoEntry); err != nil {
+			return nil, fmt.Errorf("could not decode gitsign's Rekor entry: %w", err)
+		}
+		entry, err := tlog.ParseEntry(&protoEntry)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse gitsign's Rekor entry: %w", err)
+		}
+		return entry, nil
+	}
+	return nil, errors.New("x509 signature doesn't include a Rekor entry, only gitsign signatures with one are verified")
+}
+
+// A gitsign signature as sigstore-go verifies it.
+type gitsignEntity struct {
+	verify.BaseSignedEntity
+	cert      *x509.Certificate
+	signature []byte
+	entry     *tlog.Entry
+}
+
+func (e *gitsignEntity) VerificationContent() (verify.VerificationContent, error) {
+	return bundle.NewCertificate(e.cert), nil
+}
+
+func (e *gitsignEntity) SignatureContent() (verify.SignatureContent, error) {
+	// Only the signature is checked when verifying with the artifact.
+	return bundle.NewMessageSignature(nil, "", e.signature), nil
+}
+
+func (e *gitsignEntity) TlogEntries() ([]*tlog.Entry, error) {
+	return []*tlog.Entry{e.entry}, nil
+}
+
+func (e *gitsignEntity) HasInclusionPromise() bool {
+	return e.entry.HasInclusionPromise()
+}
+
+func (e *gitsignEntity) HasInclusionProof() bool {
+	return e.entry.HasInclusionProof()
+}
@@ -0,0 +1,72 @@
+package source_control_test
+
+import (
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/testsupport"
+)
+
+func TestVerifyGitsignSignature(t *testing.T) {
+	sigstore, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatalf("could not create virtual Sigstore: %v", err)
+	}
+	otherSigstore, err := ca.NewVirtualSigstore()
+	if err != nil {
+		t.Fatalf("could not create virtual Sigstore: %v", err)
+	}
+	const payload = "tree abc\nauthor Dev <dev@example.com> 1700000000 +0000\n\nchange\n"
+
+	tests := []struct {
+		name           string
+		signature      string
+		payload        string
+		expectedSigner string
+		expectError    bool
+	}{
+		{
+			name:           "verified",
+			signature:      testsupport.GitsignSignature(t, sigstore, "dev@example.com", payload, true),
+			payload:        payload,
+			expectedSigner: "dev@example.com",
+		},
+		{
+			name:        "different commit",
+			signature:   testsupport.GitsignSignature(t, sigstore, "dev@example.com", payload, true),
+			payload:     payload + "more\n",
+			expectError: true,
+		},
+		{
+			name:        "not in Rekor",
+			signature:   testsupport.GitsignSignature(t, sigstore, "dev@example.com", payload, false),
+			payload:     payload,
+			expectError: true,
+		},
+		{
+			name:        "another Sigstore",
+			signature:   testsupport.GitsignSignature(t, otherSigstore, "dev@example.com", payload, true),
+			payload:     payload,
+			expectError: true,
+		},
+		{
+			name:        "not x509",
+			signature:   "-----BEGIN PGP SIGNATURE-----\n\nnope\n-----END PGP SIGNATURE-----",
+			payload:     payload,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := source_control.VerifyGitsignSignature(tt.signature, tt.payload, sigstore)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("VerifyGitsignSignature() error = %v, expectError %v", err, tt.expectError)
+			}
+			if got != tt.expectedSigner {
+				t.Errorf("VerifyGitsignSignature() = %q, want %q", got, tt.expectedSigner)
+			}
+		})
+	}
+}
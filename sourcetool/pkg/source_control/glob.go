@@ -0,0 +1,83 @@
+package source_control
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The glob syntaxes platforms use to match ref (and file) names.
+type GlobSyntax int
+
+const (
+	// fnmatch as GitHub uses it (Ruby's File.fnmatch with FNM_PATHNAME): '*' and '?' don't
+	// match '/', '**/' matches any number of directories, '[...]' matches a set of characters
+	// and '\' escapes.
+	FnmatchGlob GlobSyntax = iota
+	// As used by Gitea and Bitbucket: '*' and '?' don't match '/' but '**' does.
+	DoubleStarGlob
+	// As used by GitLab: '*' matches any string, including '/'.
+	WildcardGlob
+)
+
+// Reports if 'name' matches the glob 'pattern' written in 'syntax'.
+// Invalid patterns don't match anything.
+func MatchesGlob(syntax GlobSyntax, pattern, name string) bool {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case syntax == WildcardGlob && pattern[i] == '*':
+			re.WriteString(".*")
+		case syntax == WildcardGlob:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case syntax == DoubleStarGlob && strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case syntax == FnmatchGlob && strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:[^/]*/)*")
+			i += 2
+		case pattern[i] == '*':
+			for i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+			}
+			re.WriteString("[^/]*")
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+		case syntax == FnmatchGlob && pattern[i] == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			set := pattern[i+1 : i+1+end]
+			re.WriteString("[")
+			if strings.HasPrefix(set, "!") || strings.HasPrefix(set, "^") {
+				re.WriteString("^/")
+				set = set[1:]
+			}
+			for _, r := range set {
+				if r == '-' {
+					re.WriteRune(r)
+				} else {
+					re.WriteString(regexp.QuoteMeta(string(r)))
+				}
+			}
+			re.WriteString("]")
+			i += end + 1
+		case syntax == FnmatchGlob && pattern[i] == '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	matcher, err := regexp.Compile(re.String())
+	if err != nil {
+		return false
+	}
+	return matcher.MatchString(name)
+}
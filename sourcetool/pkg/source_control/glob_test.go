@@ -0,0 +1,49 @@
+package source_control
+
+import "testing"
+
+func TestMatchesGlob(t *testing.T) {
+	tests := []struct {
+		syntax        GlobSyntax
+		pattern, name string
+		expected      bool
+	}{
+		{FnmatchGlob, "main", "main", true},
+		{FnmatchGlob, "main", "mainline", false},
+		{FnmatchGlob, "release/*", "release/1.0", true},
+		{FnmatchGlob, "release/*", "release/1.0/hotfix", false},
+		{FnmatchGlob, "release/**/*", "release/1.0/hotfix", true},
+		{FnmatchGlob, "**/*.go", "main.go", true},
+		{FnmatchGlob, "v[0-9]*", "v1.2", true},
+		{FnmatchGlob, "v[!0-9]*", "vx", true},
+		{FnmatchGlob, "v[!0-9]*", "v1", false},
+		{FnmatchGlob, `\*`, "*", true},
+		{FnmatchGlob, `\*`, "x", false},
+
+		{DoubleStarGlob, "main", "main", true},
+		{DoubleStarGlob, "ma?n", "main", true},
+		{DoubleStarGlob, "release/*", "release/1.0", true},
+		{DoubleStarGlob, "release/*", "release/1.0/hotfix", false},
+		{DoubleStarGlob, "release/**", "release/1.0/hotfix", true},
+		{DoubleStarGlob, "*", "feature/x", false},
+		{DoubleStarGlob, "**", "feature/x", true},
+		{DoubleStarGlob, "v1.0", "v1x0", false},
+		{DoubleStarGlob, "v[1]", "v[1]", true},
+
+		{WildcardGlob, "main", "main", true},
+		{WildcardGlob, "main", "mainline", false},
+		{WildcardGlob, "*", "anything/at/all", true},
+		{WildcardGlob, "release/*", "release/1.0", true},
+		{WildcardGlob, "release/*", "releases/1.0", false},
+		{WildcardGlob, "*-stable", "1-0-stable", true},
+		{WildcardGlob, "*-stable", "1-0-stable-ish", false},
+		{WildcardGlob, "v*.*", "v1.2", true},
+		{WildcardGlob, "v*.*", "v12", false},
+		{WildcardGlob, "ma?n", "main", false},
+	}
+	for _, tt := range tests {
+		if got := MatchesGlob(tt.syntax, tt.pattern, tt.name); got != tt.expected {
+			t.Errorf("MatchesGlob(%v, %q, %q) = %v, want %v", tt.syntax, tt.pattern, tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package source_control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Returned by PriorCommit for a root commit.
+var ErrNoPriorCommit = errors.New("there is no earlier commit")
+
+// Returned by PushedCommits when the commit the branch was at before the push isn't in the
+// recent history of the new tip, e.g. because it was force pushed.
+var ErrBeforeNotFound = errors.New("commit before the push not found")
+
+// Gets the previous commit to 'sha': its first parent, which for a merge commit is the
+// commit it was merged into.
+func PriorCommit(ctx context.Context, platform SourceControlPlatform, sha string) (string, error) {
+	parents, err := platform.GetParents(ctx, sha)
+	if err != nil {
+		return "", err
+	}
+	if len(parents) == 0 {
+		return "", fmt.Errorf("%w than %s, that isn't yet supported", ErrNoPriorCommit, sha)
+	}
+	return parents[0], nil
+}
+
+// The most commits a single push may add to a branch.
+const maxPushedCommits = 1000
+
+// Lists the commits a push that moved a branch from 'before' to 'after' added, oldest first.
+// Like the branch's history they're found by following first parents from 'after' back to
+// 'before', so commits merged in along the way aren't included.
+func PushedCommits(ctx context.Context, platform SourceControlPlatform, before, after string) ([]string, error) {
+	commits := []string{after}
+	for len(commits) <= maxPushedCommits {
+		prev, err := PriorCommit(ctx, platform, commits[len(commits)-1])
+		if errors.Is(err, ErrNoPriorCommit) {
+			return nil, fmt.Errorf("%w: %s is not in the history of %s", ErrBeforeNotFound, before, after)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not find %s in the history of %s: %w", before, after, err)
+		}
+		if prev == before {
+			slices.Reverse(commits)
+			return commits, nil
+		}
+		commits = append(commits, prev)
+	}
+	return nil, fmt.Errorf("%w: %s is not within %d commits of %s", ErrBeforeNotFound, before, maxPushedCommits, after)
+}
@@ -0,0 +1,53 @@
+package source_control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Only knows the first parent of each commit.
+type historyPlatform struct {
+	SourceControlPlatform
+	parents map[string]string
+}
+
+func (hp historyPlatform) GetParents(ctx context.Context, sha string) ([]string, error) {
+	parent, ok := hp.parents[sha]
+	if !ok {
+		return nil, nil
+	}
+	return []string{parent}, nil
+}
+
+func TestPushedCommits(t *testing.T) {
+	platform := historyPlatform{parents: map[string]string{"c3": "c2", "c2": "c1", "c1": "c0"}}
+
+	tests := []struct {
+		name        string
+		before      string
+		after       string
+		expected    []string
+		expectError bool
+	}{
+		{name: "one commit", before: "c2", after: "c3", expected: []string{"c3"}},
+		{name: "several commits", before: "c0", after: "c3", expected: []string{"c1", "c2", "c3"}},
+		{name: "not an ancestor", before: "other", after: "c3", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PushedCommits(context.Background(), platform, tt.before, tt.after)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("PushedCommits() error = %v, expectError %v", err, tt.expectError)
+			}
+			if tt.expectError && !errors.Is(err, ErrBeforeNotFound) {
+				t.Errorf("PushedCommits() error = %v, want %v", err, ErrBeforeNotFound)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("PushedCommits() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
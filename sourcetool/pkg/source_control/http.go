@@ -0,0 +1,82 @@
+package source_control
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Returned when an API reports the requested resource doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Returns an http client that trusts the certificates in the PEM file at
+// caBundlePath in addition to the system roots, for self-hosted instances
+// using a private CA. Returns http.DefaultClient if caBundlePath is empty.
+func NewHttpClient(caBundlePath string) (*http.Client, error) {
+	if caBundlePath == "" {
+		return http.DefaultClient, nil
+	}
+
+	pem, err := os.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caBundlePath)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
+
+// Issues a GET request for 'reqUrl' with 'header' added, returning the body and the
+// response headers. Returns ErrNotFound on a 404 and an error for any other status but 200.
+func HttpGet(ctx context.Context, client *http.Client, reqUrl string, header http.Header) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("GET %s returned %d: %s", req.URL.Path, resp.StatusCode, string(body))
+	}
+	return body, resp.Header, nil
+}
+
+// Issues a GET request for 'reqUrl' with 'header' added and decodes the JSON result into 'result'.
+func HttpGetJson(ctx context.Context, client *http.Client, reqUrl string, header http.Header, result any) error {
+	body, _, err := HttpGet(ctx, client, reqUrl, header)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,98 @@
+package source_control
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+)
+
+type ControlStatus struct {
+	// The time the commit we're evaluating was pushed.
+	CommitPushTime time.Time
+	// The actor that pushed the commit.
+	ActorLogin string
+	// The type of activity that created the commit.
+	ActivityType string
+	// The commit the ref was at before the push, if the platform knows.
+	BeforeCommit string
+	// The controls that are enabled according to the platform's APIs.
+	// May not include other controls like if we have provenance.
+	Controls slsa_types.Controls
+}
+
+// Fetches source policies from wherever they are published.
+type PolicySource interface {
+	// Gets the contents of the policy file at 'path' along with a URI
+	// identifying where it was found.
+	// Returns "" if there is no policy at that path.
+	GetPolicyFile(ctx context.Context, path string) (string, string, error)
+}
+
+// A source control platform (GitHub, GitLab, etc...) that hosts
+// a single repository and reference we're evaluating.
+type SourceControlPlatform interface {
+	PolicySource
+
+	// Returns the URI of the repo this platform tracks.
+	GetRepoUri() string
+	// Returns the full ref (e.g. refs/heads/main) this platform tracks.
+	GetFullRef() string
+
+	// Determines the controls that are in place for a branch.
+	GetBranchControls(ctx context.Context, commit, ref string) (*ControlStatus, error)
+	// Determines the controls that are in place for a tag.
+	GetTagControls(ctx context.Context, commit, ref string) (*ControlStatus, error)
+
+	// Gets the git notes stored for the commit, "" if there are none.
+	GetNotesForCommit(ctx context.Context, commit string) (string, error)
+	// Gets all the parents of 'sha', in order. Merge commits have more than one.
+	GetParents(ctx context.Context, sha string) ([]string, error)
+	// Gets the commit at the tip of the branch.
+	GetLatestCommit(ctx context.Context, branch string) (string, error)
+}
+
+// Implemented by platforms that can tell when the rules enforcing controls were bypassed.
+type BypassDetector interface {
+	// Gets the times the rules enforcing controls on 'ref' were bypassed between 'from' and 'to'.
+	GetControlBypasses(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.ControlBypass, error)
+}
+
+// Implemented by platforms that can't tell when their rules were bypassed or a branch's history
+// was broken, to decide if start times are carried forward from the previous provenance anyway.
+// Platforms that implement none of these never carry them forward.
+type UncheckedCarryForward interface {
+	// Reports whether the start times of controls may be carried forward without checking
+	// for bypasses or breaks in the history, trusting the rules stayed in place in between.
+	CarryForwardUnchecked() bool
+}
+
+// Implemented by platforms that can tell which checks (e.g. CI) ran against a commit.
+type CheckResultsProvider interface {
+	// Gets the results of the checks that ran against 'commit', or against the changes it merged.
+	GetCheckResults(ctx context.Context, commit string) ([]slsa_types.CheckResult, error)
+}
+
+// Implemented by platforms that can verify commit signatures.
+type CommitSignatureVerifier interface {
+	// Gets the signature on 'commit' and whether it verified, nil if it isn't signed.
+	GetCommitSignature(ctx context.Context, commit string) (*slsa_types.CommitSignature, error)
+}
+
+// Implemented by platforms that can tell how the changes merged as a commit were reviewed.
+type ReviewEvidenceProvider interface {
+	// Gets how the pull request merged as 'commit' was reviewed, nil if it wasn't merged from one.
+	GetReviewEvidence(ctx context.Context, commit string) (*slsa_types.ReviewEvidence, error)
+}
+
+// Implemented by platforms that can tell when a branch was force pushed, deleted or created.
+type DiscontinuityDetector interface {
+	// Gets the times the history of 'ref' was broken between 'from' and 'to'.
+	GetDiscontinuities(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.Discontinuity, error)
+}
+
+// Returned by GetDiscontinuities when what the platform recorded of the branch doesn't go
+// back to 'from', e.g. because it's older than the platform keeps, so it can't tell if the
+// history was broken before that.
+var ErrHistoryIncomplete = errors.New("recorded activity doesn't go back far enough")
@@ -0,0 +1,111 @@
+package source_control
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/digitorus/pkcs7"
+	"golang.org/x/crypto/ssh"
+)
+
+// The kinds of commit signature.
+const (
+	SignatureTypeGpg = "gpg"
+	SignatureTypeSsh = "ssh"
+	// e.g. made with gitsign.
+	SignatureTypeX509 = "x509"
+)
+
+// Identifies the kind of an armored commit signature and the key or identity that made it:
+// the GPG key fingerprint (or id), the SSH key fingerprint, or the email (or URI) in the
+// x509 certificate. The signature isn't verified, so it's only who it claims to be from.
+func IdentifySigner(signature string) (string, string, error) {
+	signature = strings.TrimSpace(signature)
+	switch {
+	case strings.HasPrefix(signature, "-----BEGIN PGP SIGNATURE-----"):
+		signer, err := gpgSigner(signature)
+		return SignatureTypeGpg, signer, err
+	case strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----"):
+		signer, err := sshSigner(signature)
+		return SignatureTypeSsh, signer, err
+	case strings.HasPrefix(signature, "-----BEGIN SIGNED MESSAGE-----"):
+		signer, err := x509Signer(signature)
+		return SignatureTypeX509, signer, err
+	default:
+		return "", "", errors.New("unknown signature format")
+	}
+}
+
+func gpgSigner(signature string) (string, error) {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return "", fmt.Errorf("could not decode GPG signature: %w", err)
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not read GPG signature: %w", err)
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return "", fmt.Errorf("GPG signature contains a %T, not a signature", p)
+	}
+	if len(sig.IssuerFingerprint) > 0 {
+		return fmt.Sprintf("%X", sig.IssuerFingerprint), nil
+	}
+	if sig.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *sig.IssuerKeyId), nil
+	}
+	return "", errors.New("GPG signature doesn't say which key made it")
+}
+
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func sshSigner(signature string) (string, error) {
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil {
+		return "", errors.New("could not decode SSH signature")
+	}
+	const magic = "SSHSIG"
+	blob := block.Bytes
+	// The magic preamble and version come before the public key.
+	if !strings.HasPrefix(string(blob), magic) || len(blob) < len(magic)+8 {
+		return "", errors.New("not an SSH signature")
+	}
+	blob = blob[len(magic)+4:]
+	keyLen := binary.BigEndian.Uint32(blob)
+	if uint64(len(blob)-4) < uint64(keyLen) {
+		return "", errors.New("SSH signature is truncated")
+	}
+	key, err := ssh.ParsePublicKey(blob[4 : 4+keyLen])
+	if err != nil {
+		return "", fmt.Errorf("could not parse SSH signature's public key: %w", err)
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
+
+func x509Signer(signature string) (string, error) {
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil {
+		return "", errors.New("could not decode x509 signature")
+	}
+	p7, err := pkcs7.Parse(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse x509 signature: %w", err)
+	}
+	for _, cert := range p7.Certificates {
+		if cert.IsCA {
+			continue
+		}
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0], nil
+		}
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String(), nil
+		}
+	}
+	return "", errors.New("x509 signature doesn't include the signer's certificate")
+}
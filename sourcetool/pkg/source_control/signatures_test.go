@@ -0,0 +1,121 @@
+package source_control
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/digitorus/pkcs7"
+	"golang.org/x/crypto/ssh"
+)
+
+func gpgSignature(t *testing.T) (string, string) {
+	entity, err := openpgp.NewEntity("Dev", "", "dev@example.com", nil)
+	if err != nil {
+		t.Fatalf("could not create GPG key: %v", err)
+	}
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, strings.NewReader("tree abc"), nil); err != nil {
+		t.Fatalf("could not sign: %v", err)
+	}
+	return sig.String(), fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+// Builds an SSHSIG as 'ssh-keygen -Y sign' would, the signature itself isn't checked.
+func sshSignature(t *testing.T) (string, string) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not create SSH key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("could not create SSH signer: %v", err)
+	}
+	sshString := func(b []byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(b))), b...)
+	}
+	blob := []byte("SSHSIG")
+	blob = binary.BigEndian.AppendUint32(blob, 1)
+	blob = append(blob, sshString(signer.PublicKey().Marshal())...)
+	blob = append(blob, sshString([]byte("git"))...)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob})), ssh.FingerprintSHA256(signer.PublicKey())
+}
+
+func x509Signature(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not create key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "sigstore"},
+		EmailAddresses: []string{"dev@example.com"},
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(10 * time.Minute),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+	signedData, err := pkcs7.NewSignedData([]byte("tree abc"))
+	if err != nil {
+		t.Fatalf("could not create signed data: %v", err)
+	}
+	if err := signedData.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatalf("could not sign: %v", err)
+	}
+	signedData.Detach()
+	sig, err := signedData.Finish()
+	if err != nil {
+		t.Fatalf("could not finish signature: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SIGNED MESSAGE", Bytes: sig})), "dev@example.com"
+}
+
+func TestIdentifySigner(t *testing.T) {
+	gpgSig, gpgSigner := gpgSignature(t)
+	sshSig, sshSigner := sshSignature(t)
+	x509Sig, x509Signer := x509Signature(t)
+
+	tests := []struct {
+		name           string
+		signature      string
+		expectedType   string
+		expectedSigner string
+		expectError    bool
+	}{
+		{name: "gpg", signature: gpgSig, expectedType: SignatureTypeGpg, expectedSigner: gpgSigner},
+		{name: "ssh", signature: sshSig, expectedType: SignatureTypeSsh, expectedSigner: sshSigner},
+		{name: "gitsign", signature: x509Sig, expectedType: SignatureTypeX509, expectedSigner: x509Signer},
+		{name: "corrupt gpg", signature: "-----BEGIN PGP SIGNATURE-----\n\nnope\n-----END PGP SIGNATURE-----", expectedType: SignatureTypeGpg, expectError: true},
+		{name: "unknown", signature: "signed, me", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotSigner, err := IdentifySigner(tt.signature)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("IdentifySigner() error = %v, expectError %v", err, tt.expectError)
+			}
+			if gotType != tt.expectedType || gotSigner != tt.expectedSigner {
+				t.Errorf("IdentifySigner() = %q, %q, want %q, %q", gotType, gotSigner, tt.expectedType, tt.expectedSigner)
+			}
+		})
+	}
+}
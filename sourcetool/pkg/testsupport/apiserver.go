@@ -0,0 +1,56 @@
+package testsupport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// How a stand-in API started with NewApiServer looks up and serves responses.
+type ApiServerOptions struct {
+	// Removed from the escaped request path before looking up the response.
+	PathPrefix string
+	// Written before every JSON response, e.g. Gerrit's XSSI prefix.
+	JsonPrefix string
+	// If set, every request must satisfy this, e.g. by carrying the expected credentials.
+	Authenticated func(r *http.Request) bool
+}
+
+// Starts a stand-in for a REST API that serves 'responses' keyed by the escaped path,
+// a response keyed by the path and query takes priority over one keyed by just the path.
+//
+// Strings are served as-is, a func(http.ResponseWriter, *http.Request) any is called with
+// the request to get the response to serve, and everything else is served as JSON.
+// Paths without a response get a 404. The server is closed when the test finishes.
+func NewApiServer(t *testing.T, options ApiServerOptions, responses map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if options.Authenticated != nil && !options.Authenticated(r) {
+			t.Errorf("request to %s missing auth", r.URL.EscapedPath())
+		}
+		path := strings.TrimPrefix(r.URL.EscapedPath(), options.PathPrefix)
+		response, ok := responses[path+"?"+r.URL.RawQuery]
+		if !ok {
+			response, ok = responses[path]
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if handler, ok := response.(func(http.ResponseWriter, *http.Request) any); ok {
+			response = handler(w, r)
+		}
+		if raw, ok := response.(string); ok {
+			_, _ = w.Write([]byte(raw))
+			return
+		}
+		_, _ = w.Write([]byte(options.JsonPrefix))
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,100 @@
+package testsupport
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	rekorv1 "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/testing/ca"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signs 'payload' as gitsign does, with a certificate 'sigstore' issued to 'email'.
+// If 'logged' the signature's Rekor entry, signed by 'sigstore', is embedded in it.
+func GitsignSignature(t *testing.T, sigstore *ca.VirtualSigstore, email, payload string, logged bool) string {
+	t.Helper()
+	cert, key, err := sigstore.GenerateLeafCert(email, "https://github.com/login/oauth")
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	signedData, err := pkcs7.NewSignedData([]byte(payload))
+	if err != nil {
+		t.Fatalf("could not create signed data: %v", err)
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := signedData.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatalf("could not sign: %v", err)
+	}
+	signedData.Detach()
+
+	if logged {
+		signer := &signedData.GetSignedData().SignerInfos[0]
+		signedAttributes, err := asn1.MarshalWithParams(signer.AuthenticatedAttributes, "set")
+		if err != nil {
+			t.Fatalf("could not marshal signed attributes: %v", err)
+		}
+		entry := rekorEntry(t, sigstore, signedAttributes, signer.EncryptedDigest, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+		if err := signer.SetUnauthenticatedAttributes([]pkcs7.Attribute{{
+			Type:  asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 3, 1},
+			Value: entry,
+		}}); err != nil {
+			t.Fatalf("could not embed Rekor entry: %v", err)
+		}
+	}
+
+	sig, err := signedData.Finish()
+	if err != nil {
+		t.Fatalf("could not finish signature: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SIGNED MESSAGE", Bytes: sig}))
+}
+
+// Logs a hashedrekord of 'signature' over 'data' in 'sigstore', as a marshalled
+// TransparencyLogEntry with an inclusion promise.
+func rekorEntry(t *testing.T, sigstore *ca.VirtualSigstore, data, signature, certPem []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	body := []byte(fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"%s"}},"signature":{"content":"%s","publicKey":{"content":"%s"}}}}`,
+		hex.EncodeToString(digest[:]), base64.StdEncoding.EncodeToString(signature), base64.StdEncoding.EncodeToString(certPem)))
+	logId, err := sigstore.RekorLogID()
+	if err != nil {
+		t.Fatalf("could not get Rekor log id: %v", err)
+	}
+	rawLogId, err := hex.DecodeString(logId)
+	if err != nil {
+		t.Fatalf("could not decode Rekor log id: %v", err)
+	}
+	// The certificate is valid from now, for a few minutes.
+	integratedTime := time.Now().Add(time.Minute).Unix()
+	const logIndex = 1000
+	set, err := sigstore.RekorSignPayload(tlog.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: integratedTime,
+		LogIndex:       logIndex,
+		LogID:          logId,
+	})
+	if err != nil {
+		t.Fatalf("could not sign Rekor entry: %v", err)
+	}
+	entry, err := proto.Marshal(&rekorv1.TransparencyLogEntry{
+		LogIndex:          logIndex,
+		LogId:             &protocommon.LogId{KeyId: rawLogId},
+		KindVersion:       &rekorv1.KindVersion{Kind: "hashedrekord", Version: "0.0.1"},
+		IntegratedTime:    integratedTime,
+		InclusionPromise:  &rekorv1.InclusionPromise{SignedEntryTimestamp: set},
+		CanonicalizedBody: body,
+	})
+	if err != nil {
+		t.Fatalf("could not marshal Rekor entry: %v", err)
+	}
+	return entry
+}
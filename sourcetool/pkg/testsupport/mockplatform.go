@@ -0,0 +1,124 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/slsa_types"
+	"github.com/slsa-framework/slsa-source-poc/sourcetool/pkg/source_control"
+)
+
+// A SourceControlPlatform that returns canned data.
+type MockPlatform struct {
+	RepoUri       string
+	FullRef       string
+	ControlStatus *source_control.ControlStatus
+	// Notes keyed by commit.
+	Notes map[string]string
+	// Parent commits keyed by commit, the first parent first.
+	Parents map[string][]string
+	// Latest commits keyed by branch.
+	LatestCommits map[string]string
+	// Policy file contents keyed by path.
+	Policies map[string]string
+	// Times the rules enforcing controls were bypassed.
+	Bypasses []slsa_types.ControlBypass
+	// Check results keyed by commit.
+	CheckResults map[string][]slsa_types.CheckResult
+	// Commit signatures keyed by commit.
+	Signatures map[string]*slsa_types.CommitSignature
+	// Review evidence keyed by the commit the pull request was merged as.
+	Reviews map[string]*slsa_types.ReviewEvidence
+	// Times the branch was force pushed, deleted or created.
+	Discontinuities []slsa_types.Discontinuity
+	// If set, discontinuities before this weren't recorded.
+	HistoryStart time.Time
+}
+
+func NewMockPlatform(repoUri, fullRef string) *MockPlatform {
+	return &MockPlatform{
+		RepoUri:       repoUri,
+		FullRef:       fullRef,
+		ControlStatus: &source_control.ControlStatus{},
+		Notes:         map[string]string{},
+		Parents:       map[string][]string{},
+		LatestCommits: map[string]string{},
+		Policies:      map[string]string{},
+	}
+}
+
+func (mp *MockPlatform) GetRepoUri() string {
+	return mp.RepoUri
+}
+
+func (mp *MockPlatform) GetFullRef() string {
+	return mp.FullRef
+}
+
+func (mp *MockPlatform) GetBranchControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	return mp.ControlStatus, nil
+}
+
+func (mp *MockPlatform) GetTagControls(ctx context.Context, commit, ref string) (*source_control.ControlStatus, error) {
+	return mp.ControlStatus, nil
+}
+
+func (mp *MockPlatform) GetNotesForCommit(ctx context.Context, commit string) (string, error) {
+	return mp.Notes[commit], nil
+}
+
+func (mp *MockPlatform) GetParents(ctx context.Context, sha string) ([]string, error) {
+	return mp.Parents[sha], nil
+}
+
+func (mp *MockPlatform) GetLatestCommit(ctx context.Context, branch string) (string, error) {
+	commit, ok := mp.LatestCommits[branch]
+	if !ok {
+		return "", fmt.Errorf("could not get info on specified branch %s", branch)
+	}
+	return commit, nil
+}
+
+func (mp *MockPlatform) GetPolicyFile(ctx context.Context, path string) (string, string, error) {
+	contents, ok := mp.Policies[path]
+	if !ok {
+		return "", "", nil
+	}
+	return contents, path, nil
+}
+
+func (mp *MockPlatform) GetControlBypasses(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.ControlBypass, error) {
+	var bypasses []slsa_types.ControlBypass
+	for _, bypass := range mp.Bypasses {
+		if !bypass.Time.Before(from) && !bypass.Time.After(to) {
+			bypasses = append(bypasses, bypass)
+		}
+	}
+	return bypasses, nil
+}
+
+func (mp *MockPlatform) GetDiscontinuities(ctx context.Context, ref string, from, to time.Time) ([]slsa_types.Discontinuity, error) {
+	if from.Before(mp.HistoryStart) {
+		return nil, fmt.Errorf("history starts at %v: %w", mp.HistoryStart, source_control.ErrHistoryIncomplete)
+	}
+	var discontinuities []slsa_types.Discontinuity
+	for _, discontinuity := range mp.Discontinuities {
+		if !discontinuity.Time.Before(from) && !discontinuity.Time.After(to) {
+			discontinuities = append(discontinuities, discontinuity)
+		}
+	}
+	return discontinuities, nil
+}
+
+func (mp *MockPlatform) GetCheckResults(ctx context.Context, commit string) ([]slsa_types.CheckResult, error) {
+	return mp.CheckResults[commit], nil
+}
+
+func (mp *MockPlatform) GetCommitSignature(ctx context.Context, commit string) (*slsa_types.CommitSignature, error) {
+	return mp.Signatures[commit], nil
+}
+
+func (mp *MockPlatform) GetReviewEvidence(ctx context.Context, commit string) (*slsa_types.ReviewEvidence, error) {
+	return mp.Reviews[commit], nil
+}